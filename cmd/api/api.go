@@ -6,28 +6,106 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nb-samples/aws-serverless-go/internal/filter"
 	"github.com/nb-samples/aws-serverless-go/internal/sample"
 	"github.com/nb-samples/aws-serverless-go/response"
 )
 
 const (
-	envTableName = "DB_TABLE_NAME"
-	envTopicArn  = "SNS_TOPIC_ARN"
+	envTableName        = "DB_TABLE_NAME"
+	envAuditTableName   = "AUDIT_TABLE_NAME"
+	envVersionTableName = "VERSION_TABLE_NAME"
+	envReservationTable = "RESERVATION_TABLE_NAME"
+	envRetentionDays    = "DELETED_RETENTION_DAYS"
+	envWebhookTableName = "WEBHOOK_TABLE_NAME"
+	envTopicArn         = "SNS_TOPIC_ARN"
+	envPublisher        = "EVENT_PUBLISHER"
+	envEventBusName     = "EVENT_BUS_NAME"
+	envSearchBucket     = "SEARCH_BUCKET"
+	envSearchKey        = "SEARCH_KEY"
+	envAttachmentBucket = "ATTACHMENT_BUCKET"
+	envPayloadBucket    = "PAYLOAD_BUCKET"
+	envCacheTTL         = "ITEM_CACHE_TTL"
+	envEncryptionKey    = "ENCRYPTION_KEY_ID"
+	envAdminGroup       = "ADMIN_GROUP"
+	envLocationIndex    = "LOCATION_INDEX"
+)
+
+// Supported event publishers
+const (
+	publisherSNS         = "sns"
+	publisherEventBridge = "eventbridge"
 )
 
 type configuration struct {
-	dbTableName string
-	snsTopicArn string
+	dbTableName      string
+	auditTableName   string
+	versionTableName string
+	reservationTable string
+	retention        time.Duration
+	webhookTableName string
+	snsTopicArn      string
+	publisher        string
+	eventBusName     string
+	searchBucket     string
+	searchKey        string
+	attachmentBucket string
+	payloadBucket    string
+	cacheTTL         time.Duration
+	encryptionKey    string
+	adminGroup       string // authorizer group of callers reading soft deleted items (nobody if empty)
+	noLocationIndex  bool   // location queries filter the name index until the location index is created
 }
 
 func (c *configuration) incomplete() bool {
+	if c.publisher == publisherEventBridge {
+		return c.dbTableName == "" || c.eventBusName == ""
+	}
 	return c.dbTableName == "" || c.snsTopicArn == ""
 }
 
+// Returns the repository of items, its client is created once per function instance
+func repository(ctx context.Context) *sample.Repo {
+	repoOnce.Do(func() {
+		itemRepo = sample.Repository(config.dbTableName)
+		itemRepo.VersionTableName = config.versionTableName
+		itemRepo.ReservationTableName = config.reservationTable
+		itemRepo.Retention = config.retention
+		itemRepo.NoLocationIndex = config.noLocationIndex
+		itemRepo.Payloads = payloads(context.Background())
+		if config.encryptionKey != "" {
+			itemRepo.Encryption = sample.Encryption(config.encryptionKey)
+		}
+	})
+	return itemRepo.WithContext(ctx)
+}
+
+// Returns the configured publisher of item events
+func publisher(ctx context.Context) sample.Publisher {
+	if config.publisher == publisherEventBridge {
+		bus := sample.EventBus(config.eventBusName).WithContext(ctx)
+		bus.Payloads = payloads(ctx)
+		return bus
+	}
+	topic := sample.SnsTopic(config.snsTopicArn).WithContext(ctx)
+	topic.Payloads = payloads(ctx)
+	return topic
+}
+
+// Returns the claim-check store of large items and messages (nil if not configured)
+func payloads(ctx context.Context) *sample.PayloadStore {
+	if config.payloadBucket == "" {
+		return nil
+	}
+	return sample.Payloads(config.payloadBucket).WithContext(ctx)
+}
+
 var (
 	isTesting bool
 	config    configuration
@@ -37,37 +115,319 @@ type key int
 
 const (
 	keyRequestURI key = iota + 1
+	keyActor
+	keyRequestID
+)
+
+// Custom actions of API resources
+const (
+	actionUndelete = ":undelete"
+	actionRestore  = ":restore"
+	actionCommit   = ":commit"
+	actionRelease  = ":release"
+)
+
+// API resources
+const (
+	resourceItems       = "/items"
+	resourceItem        = "/items/{itemId}"
+	resourceSearch      = "/items/search"
+	resourceBatchCreate = "/items:batchCreate"
+	resourceBatchGet    = "/items:batchGet"
+	resourceBatchDelete = "/items:batchDelete"
+	resourceAudit       = "/items/{itemId}/audit"
+	resourceVersions    = "/items/{itemId}/versions"
+	resourceVersion     = "/items/{itemId}/versions/{version}"
+	resourceQuantity    = "/items/{itemId}/quantity:adjust"
+	resourceReserve     = "/items/{itemId}/reservations"
+	resourceReservation = "/reservations/{reservationId}"
+	resourceTransfers   = "/transfers"
+	resourceTags        = "/items/{itemId}/tags"
+	resourceAttachments = "/items/{itemId}/attachments"
+	resourceWebhooks    = "/webhooks"
+	resourceWebhook     = "/webhooks/{webhookId}"
 )
 
 // Request router
-func router(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+func router(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
 	var resp response.Response
-	ctx := context.WithValue(context.Background(), keyRequestURI, requestURI(req))
+	ctx = context.WithValue(ctx, keyRequestURI, requestURI(req))
+	ctx = context.WithValue(ctx, keyActor, actor(req))
+	ctx = context.WithValue(ctx, keyRequestID, req.RequestContext.RequestID)
 
-	itemID := req.PathParameters["itemId"]
-	if itemID == "" {
+	switch resource(req) {
+	case resourceItems:
 		// collection actions
 		switch req.HTTPMethod {
+		case "GET":
+			if query, err := queryOf(req); err != nil {
+				resp = response.BadRequest(err.Error())
+			} else if query.IncludeDeleted && !admin(req) {
+				resp = response.Forbidden(errAdminOnly)
+			} else {
+				resp = list(ctx, query, pageOf(req))
+			}
 		case "POST":
 			resp = createFrom(ctx, req.Body)
 		default:
+			resp = response.MethodNotAllowed("GET, POST")
+		}
+	case resourceBatchCreate, resourceBatchGet, resourceBatchDelete:
+		// batch actions with per-item results
+		switch {
+		case req.HTTPMethod != "POST":
 			resp = response.MethodNotAllowed("POST")
+		case req.Resource == resourceBatchCreate:
+			resp = batchCreateFrom(ctx, req.Body)
+		case req.Resource == resourceBatchGet:
+			resp = batchGetFrom(ctx, req.Body)
+		default:
+			resp = batchDeleteFrom(ctx, req.Body)
 		}
-	} else {
-		// resource actions
+	case resourceSearch:
+		// full-text search of resources
 		switch req.HTTPMethod {
 		case "GET":
-			resp = get(ctx, itemID)
-		case "DELETE":
-			resp = delete(ctx, itemID)
+			resp = searchItems(ctx, req.QueryStringParameters["q"], req.QueryStringParameters["limit"])
 		default:
+			resp = response.MethodNotAllowed("GET")
+		}
+	case resourceItem:
+		// resource actions, soft deleted resource is restored by POST with ":undelete" suffix
+		itemID, action := actionOf(req.PathParameters["itemId"])
+		switch {
+		case action == "" && req.HTTPMethod == "GET":
+			if includeDeleted, _ := strconv.ParseBool(req.QueryStringParameters["includeDeleted"]); includeDeleted && !admin(req) {
+				resp = response.Forbidden(errAdminOnly)
+			} else {
+				resp = get(ctx, itemID, includeDeleted)
+			}
+		case action == "" && req.HTTPMethod == "DELETE":
+			resp = delete(ctx, itemID)
+		case action == "":
 			resp = response.MethodNotAllowed("GET, DELETE")
+		case action == actionUndelete && req.HTTPMethod == "POST":
+			resp = undelete(ctx, itemID)
+		case action == actionUndelete:
+			resp = response.MethodNotAllowed("POST")
+		default:
+			resp = response.NotFound(response.DefaultStatusText)
+		}
+	case resourceAudit:
+		// audit trail of the resource
+		switch req.HTTPMethod {
+		case "GET":
+			resp = listAudit(ctx, req.PathParameters["itemId"], pageOf(req))
+		default:
+			resp = response.MethodNotAllowed("GET")
+		}
+	case resourceVersions:
+		// version history of the resource
+		switch req.HTTPMethod {
+		case "GET":
+			resp = listVersions(ctx, req.PathParameters["itemId"], pageOf(req))
+		default:
+			resp = response.MethodNotAllowed("GET")
+		}
+	case resourceVersion:
+		// single version of the resource, restored by POST with ":restore" suffix
+		itemID := req.PathParameters["itemId"]
+		version, action, err := versionOf(req.PathParameters["version"])
+		switch {
+		case err != nil:
+			resp = response.BadRequest(err.Error())
+		case action == "" && req.HTTPMethod == "GET":
+			resp = getVersion(ctx, itemID, version)
+		case action == "":
+			resp = response.MethodNotAllowed("GET")
+		case action == actionRestore && req.HTTPMethod == "POST":
+			resp = restoreVersion(ctx, itemID, version)
+		case action == actionRestore:
+			resp = response.MethodNotAllowed("POST")
+		default:
+			resp = response.NotFound(response.DefaultStatusText)
+		}
+	case resourceQuantity:
+		// atomic quantity adjustment of the resource
+		switch req.HTTPMethod {
+		case "POST":
+			resp = adjustQuantity(ctx, req.PathParameters["itemId"], req.Body)
+		default:
+			resp = response.MethodNotAllowed("POST")
+		}
+	case resourceReserve:
+		// reservations of the resource quantity
+		switch req.HTTPMethod {
+		case "POST":
+			resp = reserve(ctx, req.PathParameters["itemId"], req.Body)
+		default:
+			resp = response.MethodNotAllowed("POST")
+		}
+	case resourceReservation:
+		// reservation is settled by POST with ":commit" or ":release" suffix
+		reservationID, action := actionOf(req.PathParameters["reservationId"])
+		switch {
+		case action == "" && req.HTTPMethod == "GET":
+			resp = getReservation(ctx, reservationID)
+		case action == "":
+			resp = response.MethodNotAllowed("GET")
+		case (action == actionCommit || action == actionRelease) && req.HTTPMethod == "POST":
+			resp = settleReservation(ctx, reservationID, action)
+		case action == actionCommit || action == actionRelease:
+			resp = response.MethodNotAllowed("POST")
+		default:
+			resp = response.NotFound(response.DefaultStatusText)
+		}
+	case resourceTags:
+		// tags of the resource
+		switch req.HTTPMethod {
+		case "POST", "DELETE":
+			resp = tagFrom(ctx, req.PathParameters["itemId"], req.HTTPMethod == "POST", req.Body)
+		default:
+			resp = response.MethodNotAllowed("POST, DELETE")
+		}
+	case resourceAttachments:
+		// attachments of the resource, content is transferred by presigned S3 URLs
+		switch req.HTTPMethod {
+		case "GET":
+			resp = listAttachments(ctx, req.PathParameters["itemId"])
+		case "POST":
+			resp = attachFrom(ctx, req.PathParameters["itemId"], req.Body)
+		default:
+			resp = response.MethodNotAllowed("GET, POST")
+		}
+	case resourceTransfers:
+		// atomic quantity transfers between resources
+		switch req.HTTPMethod {
+		case "POST":
+			resp = transferFrom(ctx, req.Body)
+		default:
+			resp = response.MethodNotAllowed("POST")
 		}
+	case resourceWebhooks:
+		// webhook collection actions
+		switch req.HTTPMethod {
+		case "GET":
+			resp = listWebhooks(ctx)
+		case "POST":
+			resp = createWebhookFrom(ctx, req.Body)
+		default:
+			resp = response.MethodNotAllowed("GET, POST")
+		}
+	case resourceWebhook:
+		// webhook resource actions
+		webhookID := req.PathParameters["webhookId"]
+		switch req.HTTPMethod {
+		case "GET":
+			resp = getWebhook(ctx, webhookID)
+		case "PUT":
+			resp = updateWebhookFrom(ctx, webhookID, req.Body)
+		case "DELETE":
+			resp = deleteWebhook(ctx, webhookID)
+		default:
+			resp = response.MethodNotAllowed("GET, PUT, DELETE")
+		}
+	default:
+		resp = response.NotFound(response.DefaultStatusText)
 	}
 	log.Println("RESPONSE:", resp)
 	return response.Proxy(resp)
 }
 
+// Splits a path parameter into a value and an optional custom action suffix (e.g. ":restore")
+func actionOf(param string) (value, action string) {
+	if i := strings.LastIndex(param, ":"); i >= 0 {
+		return param[:i], param[i:]
+	}
+	return param, ""
+}
+
+// Returns API resource of the request (derived from path parameters if not provided)
+func resource(req events.APIGatewayProxyRequest) string {
+	if req.Resource != "" {
+		return req.Resource
+	}
+	if req.PathParameters["itemId"] != "" {
+		return resourceItem
+	}
+	return resourceItems
+}
+
+// Returns the caller identity: authorizer principal, API key or anonymous
+func actor(req events.APIGatewayProxyRequest) string {
+	if principal, ok := req.RequestContext.Authorizer["principalId"].(string); ok && principal != "" {
+		return principal
+	}
+	if req.RequestContext.Identity.APIKeyID != "" {
+		return "apikey:" + req.RequestContext.Identity.APIKeyID
+	}
+	return "anonymous"
+}
+
+// errAdminOnly is the message of admin queries by other callers
+const errAdminOnly = "Deleted items are available to administrators only."
+
+// Reports whether the caller is in the admin group claimed by the authorizer:
+// "cognito:groups" claim of Cognito user pools or "groups" context of Lambda authorizers
+func admin(req events.APIGatewayProxyRequest) bool {
+	if config.adminGroup == "" {
+		return false
+	}
+	groups, _ := req.RequestContext.Authorizer["groups"].(string)
+	if claims, ok := req.RequestContext.Authorizer["claims"].(map[string]interface{}); ok {
+		if s, ok := claims["cognito:groups"].(string); ok {
+			groups = s
+		}
+	}
+	for _, group := range strings.FieldsFunc(strings.Trim(groups, "[]"), func(r rune) bool { return r == ',' || r == ' ' }) {
+		if group == config.adminGroup {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the page requested by query parameters
+func pageOf(req events.APIGatewayProxyRequest) sample.Page {
+	limit, _ := strconv.Atoi(req.QueryStringParameters["limit"])
+	return sample.Page{Limit: limit, Next: req.QueryStringParameters["next"]}
+}
+
+// Returns the item query requested by query parameters.
+// Filter, sort and fields parameters are reported with a position of a syntax error.
+func queryOf(req events.APIGatewayProxyRequest) (sample.Query, error) {
+	params := req.QueryStringParameters
+	includeDeleted, _ := strconv.ParseBool(params["includeDeleted"])
+	query := sample.Query{
+		Location:       params["location"],
+		NamePrefix:     params["namePrefix"],
+		IncludeDeleted: includeDeleted,
+	}
+
+	var err error
+	if s, ok := params["tags"]; ok {
+		if query.Tags, err = sample.NormalizeTags(strings.Split(s, ",")); err != nil {
+			return query, fmt.Errorf("Invalid tags: %w", err)
+		}
+	}
+	if s, ok := params["filter"]; ok {
+		if query.Filter, err = sample.ParseFilter(s); err != nil {
+			return query, fmt.Errorf("Invalid filter: %w", err)
+		}
+	}
+	if s, ok := params["sort"]; ok {
+		if query.Sort, err = filter.ParseSort(s, sample.ItemField); err != nil {
+			return query, fmt.Errorf("Invalid sort: %w", err)
+		}
+	}
+	if s, ok := params["fields"]; ok {
+		if query.Fields, err = filter.ParseFields(s, sample.ItemField); err != nil {
+			return query, fmt.Errorf("Invalid fields: %w", err)
+		}
+	}
+	return query, nil
+}
+
 func requestURI(req events.APIGatewayProxyRequest) string {
 	proto := req.Headers["X-Forwarded-Proto"]
 	host := req.Headers["Host"]
@@ -91,6 +451,12 @@ func createFrom(ctx context.Context, body string) response.Response {
 	if item.ID != "" {
 		return response.BadRequest("Item ID is not allowed when creating a new resource.")
 	}
+	if expired(item) {
+		return response.BadRequest("Item expiry must be in the future.")
+	}
+	if err := item.Validate(); err != nil {
+		return response.BadRequest(err.Error())
+	}
 
 	if isTesting {
 		// FIXME unit tests exit here
@@ -100,29 +466,75 @@ func createFrom(ctx context.Context, body string) response.Response {
 		log.Fatalln("Service is not configured")
 	}
 
-	// publish item to SNS topic
-	if msgID, err := sample.SnsTopic(config.snsTopicArn).Publish(item); err == nil {
-		fmt.Println("SNS notification:", msgID)
-	}
-
 	// save item in DynamoDB
-	out, err := sample.Repository(config.dbTableName).Save(item)
+	out, err := repository(ctx).Save(item)
 	if err != nil {
 		// return 400 Bad Request for simplicity
 		return response.BadRequest(err.Error())
 	}
 	fmt.Println("DynamoDB persistence:", out.ID)
+	audit(ctx, sample.ItemCreated, nil, out)
+
+	// publish item event (with allocated ID) to SNS topic or EventBridge
+	if msgID, err := publisher(ctx).PublishEvent(sample.ItemCreated, *out); err == nil {
+		fmt.Println("Event notification:", msgID)
+	}
 	return response.Created(out, ctx.Value(keyRequestURI).(string)+"/"+out.ID)
 }
 
-// Gets a resource by ID
-func get(ctx context.Context, itemID string) response.Response {
+// Reports whether the item in a request already expired
+func expired(item sample.Item) bool {
+	return item.ExpiresAt != nil && !item.ExpiresAt.After(time.Now())
+}
+
+// ItemPage is a page of items
+type ItemPage struct {
+	Items interface{} `json:"items"` // items or their sparse fieldsets
+	Next  string      `json:"next,omitempty"`
+}
+
+// Lists a page of resources by location and/or name prefix ordered by name
+func list(ctx context.Context, query sample.Query, page sample.Page) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	items, next, err := repository(ctx).Query(query, page)
+	if err == sample.ErrInvalidPageToken {
+		return response.BadRequest(err.Error())
+	} else if err != nil {
+		return response.InternalServerError(err.Error())
+	}
+	if len(query.Fields) > 0 {
+		return response.OK(ItemPage{Items: sparse(items, query.Fields), Next: next}, nil)
+	}
+	if items == nil {
+		items = []sample.Item{}
+	}
+	return response.OK(ItemPage{Items: items, Next: next}, nil)
+}
+
+// Returns sparse fieldsets of items
+func sparse(items []sample.Item, fields []string) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		var doc map[string]interface{}
+		b, _ := json.Marshal(item)
+		_ = json.Unmarshal(b, &doc)
+		out[i] = filter.Select(doc, fields)
+	}
+	return out
+}
+
+// Gets a resource by ID, soft deleted resources are found on request only
+func get(ctx context.Context, itemID string, includeDeleted bool) response.Response {
 	if isTesting {
 		// unit tests exit here
 		return response.NoContent()
 	}
 
-	out, err := sample.Repository(config.dbTableName).Get(itemID)
+	out, err := items(ctx).Lookup(itemID, includeDeleted)
 	if err != nil {
 		// return 404 Not Found for simplicity
 		return response.NotFound(err.Error())
@@ -130,28 +542,121 @@ func get(ctx context.Context, itemID string) response.Response {
 	return response.OK(out, nil)
 }
 
-// Deletes a resource by ID.
+// Deletes a resource by ID. The resource can be restored until purged after the retention period.
 func delete(ctx context.Context, itemID string) response.Response {
 	if isTesting {
 		// unit tests exit here
 		return response.NoContent()
 	}
 
-	if err := sample.Repository(config.dbTableName).Delete(itemID); err != nil {
+	// read the item first to audit its last state
+	repo := repository(ctx)
+	item, err := repo.Get(itemID)
+	if err != nil {
 		// return 404 Not Found for simplicity
 		return response.NotFound(err.Error())
 	}
+	out, err := repo.Delete(itemID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	audit(ctx, sample.ItemDeleted, item, out)
+
+	// publish item event (with the tombstone) to SNS topic or EventBridge
+	if msgID, err := publisher(ctx).PublishEvent(sample.ItemDeleted, *out); err == nil {
+		fmt.Println("Event notification:", msgID)
+	}
 	return response.NoContent()
 }
 
+// Restores a soft deleted resource by ID
+func undelete(ctx context.Context, itemID string) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	repo := repository(ctx)
+	item, err := repo.Lookup(itemID, true)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	out, err := repo.Undelete(itemID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	audit(ctx, sample.ItemRestored, item, out)
+
+	// publish item event to SNS topic or EventBridge
+	if msgID, err := publisher(ctx).PublishEvent(sample.ItemRestored, *out); err == nil {
+		fmt.Println("Event notification:", msgID)
+	}
+	return response.OK(out, nil)
+}
+
 func init() {
 	var ok bool
 
 	if config.dbTableName, ok = os.LookupEnv(envTableName); !ok {
 		log.Println("Missing environment variable:", envTableName)
 	}
-	if config.snsTopicArn, ok = os.LookupEnv(envTopicArn); !ok {
-		log.Println("Missing environment variable:", envTopicArn)
+	if config.auditTableName, ok = os.LookupEnv(envAuditTableName); !ok {
+		log.Println("Missing environment variable:", envAuditTableName)
+	}
+	if config.versionTableName, ok = os.LookupEnv(envVersionTableName); !ok {
+		log.Println("Missing environment variable:", envVersionTableName)
+	}
+	if config.reservationTable, ok = os.LookupEnv(envReservationTable); !ok {
+		log.Println("Missing environment variable:", envReservationTable)
+	}
+	if days, ok := os.LookupEnv(envRetentionDays); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			config.retention = time.Duration(n) * 24 * time.Hour
+		} else {
+			log.Println("Invalid environment variable:", envRetentionDays, days)
+		}
+	}
+	if config.webhookTableName, ok = os.LookupEnv(envWebhookTableName); !ok {
+		log.Println("Missing environment variable:", envWebhookTableName)
+	}
+	if config.searchBucket, ok = os.LookupEnv(envSearchBucket); !ok {
+		log.Println("Missing environment variable:", envSearchBucket)
+	}
+	if config.searchKey, ok = os.LookupEnv(envSearchKey); !ok {
+		config.searchKey = "search/items.json"
+	}
+	config.payloadBucket = os.Getenv(envPayloadBucket) // large items fail to save if not set
+	config.encryptionKey = os.Getenv(envEncryptionKey) // sensitive fields are stored as plaintext if not set
+	config.adminGroup = os.Getenv(envAdminGroup)       // soft deleted items are hidden from everyone if not set
+	config.noLocationIndex = os.Getenv(envLocationIndex) == "disabled"
+	config.cacheTTL = sample.DefaultCacheTTL
+	if seconds, ok := os.LookupEnv(envCacheTTL); ok {
+		if n, err := strconv.Atoi(seconds); err == nil && n >= 0 {
+			config.cacheTTL = time.Duration(n) * time.Second
+		} else {
+			log.Println("Invalid environment variable:", envCacheTTL, seconds)
+		}
+	}
+	if config.attachmentBucket, ok = os.LookupEnv(envAttachmentBucket); !ok {
+		log.Println("Missing environment variable:", envAttachmentBucket)
+	}
+	if config.publisher, ok = os.LookupEnv(envPublisher); !ok {
+		config.publisher = publisherSNS
+	}
+	switch config.publisher {
+	case publisherSNS:
+		if config.snsTopicArn, ok = os.LookupEnv(envTopicArn); !ok {
+			log.Println("Missing environment variable:", envTopicArn)
+		}
+	case publisherEventBridge:
+		if config.eventBusName, ok = os.LookupEnv(envEventBusName); !ok {
+			log.Println("Missing environment variable:", envEventBusName)
+		}
+	default:
+		log.Println("Unsupported event publisher:", config.publisher)
 	}
 }
 
@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/nb-samples/aws-serverless-go/internal/filter"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
 	"github.com/nb-samples/aws-serverless-go/response"
 	"github.com/stretchr/testify/assert"
 )
@@ -44,6 +46,403 @@ func TestRouter(t *testing.T) {
 			},
 			expect: 405,
 		},
+		{
+			name: "Negative - POST audit",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceAudit,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+			},
+			expect: 405,
+		},
+		{
+			name: "Negative - PUT resource",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:     "PUT",
+				Body:           validItemWithID,
+				Headers:        map[string]string{"Content-Type": "application/json"},
+				PathParameters: map[string]string{"itemId": "test"},
+			},
+			expect: 405,
+		},
+		{
+			name: "Positive - GET audit",
+			request: events.APIGatewayProxyRequest{
+				Resource:              resourceAudit,
+				HTTPMethod:            "GET",
+				PathParameters:        map[string]string{"itemId": "test-id-value"},
+				QueryStringParameters: map[string]string{"limit": "10"},
+			},
+			expect: 204,
+		},
+		{
+			name: "Positive - GET collection",
+			request: events.APIGatewayProxyRequest{
+				Resource:              resourceItems,
+				HTTPMethod:            "GET",
+				QueryStringParameters: map[string]string{"location": "A1", "namePrefix": "test", "limit": "10"},
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - GET collection with invalid filter",
+			request: events.APIGatewayProxyRequest{
+				Resource:              resourceItems,
+				HTTPMethod:            "GET",
+				QueryStringParameters: map[string]string{"filter": "quantity >"},
+			},
+			expect: 400,
+		},
+		{
+			name: "Positive - GET search",
+			request: events.APIGatewayProxyRequest{
+				Resource:              resourceSearch,
+				HTTPMethod:            "GET",
+				QueryStringParameters: map[string]string{"q": "blue widget", "limit": "5"},
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - GET search without query",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceSearch,
+				HTTPMethod: "GET",
+			},
+			expect: 400,
+		},
+		{
+			name: "Negative - POST search",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceSearch,
+				HTTPMethod: "POST",
+			},
+			expect: 405,
+		},
+		{
+			name: "Positive - POST batch create",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceBatchCreate,
+				HTTPMethod: "POST",
+				Body:       `{"items": [` + validItemWithoutID + `, ` + validItemWithID + `]}`,
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - POST empty batch create",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceBatchCreate,
+				HTTPMethod: "POST",
+				Body:       `{"ids": ["test-id-value"]}`,
+			},
+			expect: 400,
+		},
+		{
+			name: "Positive - POST batch get",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceBatchGet,
+				HTTPMethod: "POST",
+				Body:       `{"ids": ["test-id-value", "other-id-value"]}`,
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - POST batch delete with invalid JSON",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceBatchDelete,
+				HTTPMethod: "POST",
+				Body:       invalidJSON,
+			},
+			expect: 400,
+		},
+		{
+			name: "Negative - GET batch get",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceBatchGet,
+				HTTPMethod: "GET",
+			},
+			expect: 405,
+		},
+		{
+			name: "Positive - POST quantity adjustment",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceQuantity,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+				Body:           `{"delta": -2}`,
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - POST zero quantity adjustment",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceQuantity,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+				Body:           `{"delta": 0}`,
+			},
+			expect: 400,
+		},
+		{
+			name: "Positive - POST reservation",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceReserve,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+				Body:           `{"quantity": 2, "expiresIn": 600}`,
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - POST reservation beyond max expiry",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceReserve,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+				Body:           `{"quantity": 2, "expiresIn": 86401}`,
+			},
+			expect: 400,
+		},
+		{
+			name: "Positive - POST reservation commit",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceReservation,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"reservationId": "test-reservation:commit"},
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - DELETE reservation release",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceReservation,
+				HTTPMethod:     "DELETE",
+				PathParameters: map[string]string{"reservationId": "test-reservation:release"},
+			},
+			expect: 405,
+		},
+		{
+			name: "Positive - POST tags",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceTags,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+				Body:           `{"tags": ["sale", "new"]}`,
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - DELETE invalid tags",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceTags,
+				HTTPMethod:     "DELETE",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+				Body:           `{"tags": ["on sale"]}`,
+			},
+			expect: 400,
+		},
+		{
+			name: "Negative - PUT tags",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceTags,
+				HTTPMethod:     "PUT",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+			},
+			expect: 405,
+		},
+		{
+			name: "Positive - POST attachment",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceAttachments,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+				Body:           `{"name": "spec.pdf", "contentType": "application/pdf"}`,
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - POST attachment without name",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceAttachments,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+				Body:           `{"contentType": "application/pdf"}`,
+			},
+			expect: 400,
+		},
+		{
+			name: "Negative - POST attachment with invalid content type",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceAttachments,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+				Body:           `{"name": "spec.pdf", "contentType": "pdf;"}`,
+			},
+			expect: 400,
+		},
+		{
+			name: "Positive - GET attachments",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceAttachments,
+				HTTPMethod:     "GET",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - DELETE attachments",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceAttachments,
+				HTTPMethod:     "DELETE",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+			},
+			expect: 405,
+		},
+		{
+			name: "Positive - POST transfer",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceTransfers,
+				HTTPMethod: "POST",
+				Body:       `{"from": "test-id-value", "to": "other-id-value", "quantity": 3}`,
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - POST transfer to the same resource",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceTransfers,
+				HTTPMethod: "POST",
+				Body:       `{"from": "test-id-value", "to": "test-id-value", "quantity": 3}`,
+			},
+			expect: 400,
+		},
+		{
+			name: "Negative - GET transfers",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceTransfers,
+				HTTPMethod: "GET",
+			},
+			expect: 405,
+		},
+		{
+			name: "Positive - GET deleted resource",
+			request: events.APIGatewayProxyRequest{
+				Resource:              resourceItem,
+				HTTPMethod:            "GET",
+				PathParameters:        map[string]string{"itemId": "test-id-value"},
+				QueryStringParameters: map[string]string{"includeDeleted": "true"},
+				RequestContext:        events.APIGatewayProxyRequestContext{Authorizer: map[string]interface{}{"groups": "test-admins"}},
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - GET deleted resource by non-admin",
+			request: events.APIGatewayProxyRequest{
+				Resource:              resourceItem,
+				HTTPMethod:            "GET",
+				PathParameters:        map[string]string{"itemId": "test-id-value"},
+				QueryStringParameters: map[string]string{"includeDeleted": "true"},
+			},
+			expect: 403,
+		},
+		{
+			name: "Negative - GET collection including deleted by non-admin",
+			request: events.APIGatewayProxyRequest{
+				Resource:              resourceItems,
+				HTTPMethod:            "GET",
+				QueryStringParameters: map[string]string{"includeDeleted": "true"},
+			},
+			expect: 403,
+		},
+		{
+			name: "Positive - POST resource undelete",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceItem,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value:undelete"},
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - DELETE resource undelete",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceItem,
+				HTTPMethod:     "DELETE",
+				PathParameters: map[string]string{"itemId": "test-id-value:undelete"},
+			},
+			expect: 405,
+		},
+		{
+			name: "Negative - unknown resource action",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceItem,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value:purge"},
+			},
+			expect: 404,
+		},
+		{
+			name: "Positive - GET versions",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceVersions,
+				HTTPMethod:     "GET",
+				PathParameters: map[string]string{"itemId": "test-id-value"},
+			},
+			expect: 204,
+		},
+		{
+			name: "Positive - GET version",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceVersion,
+				HTTPMethod:     "GET",
+				PathParameters: map[string]string{"itemId": "test-id-value", "version": "2"},
+			},
+			expect: 204,
+		},
+		{
+			name: "Positive - POST version restore",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceVersion,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value", "version": "2:restore"},
+			},
+			expect: 204,
+		},
+		{
+			name: "Negative - POST version",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceVersion,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value", "version": "2"},
+			},
+			expect: 405,
+		},
+		{
+			name: "Negative - GET version restore",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceVersion,
+				HTTPMethod:     "GET",
+				PathParameters: map[string]string{"itemId": "test-id-value", "version": "2:restore"},
+			},
+			expect: 405,
+		},
+		{
+			name: "Negative - invalid version",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceVersion,
+				HTTPMethod:     "GET",
+				PathParameters: map[string]string{"itemId": "test-id-value", "version": "latest"},
+			},
+			expect: 400,
+		},
+		{
+			name: "Negative - unknown version action",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceVersion,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"itemId": "test-id-value", "version": "2:purge"},
+			},
+			expect: 404,
+		},
 		{
 			name: "Positive - POST resource",
 			request: events.APIGatewayProxyRequest{
@@ -57,7 +456,7 @@ func TestRouter(t *testing.T) {
 
 	var assert = assert.New(t)
 	for _, test := range tests {
-		res, _ := router(test.request)
+		res, _ := router(context.Background(), test.request)
 		assert.Equal(test.expect, res.StatusCode, "Incorrect status code")
 		if res.StatusCode == 405 {
 			assert.Contains(res.Headers, "Allow", "Missing HTTP header")
@@ -65,6 +464,90 @@ func TestRouter(t *testing.T) {
 	}
 }
 
+func TestQueryOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]string
+		want    sample.Query
+		wantErr string
+	}{
+		{
+			name:   "no parameters",
+			params: nil,
+			want:   sample.Query{},
+		},
+		{
+			name:   "index parameters",
+			params: map[string]string{"location": "A1", "namePrefix": "test", "includeDeleted": "true"},
+			want:   sample.Query{Location: "A1", NamePrefix: "test", IncludeDeleted: true},
+		},
+		{
+			name:   "sort and fields",
+			params: map[string]string{"sort": "createdAt,-name", "fields": "id,name,quantity"},
+			want: sample.Query{
+				Sort:   []filter.SortKey{{Field: "createdAt"}, {Field: "name", Desc: true}},
+				Fields: []string{"id", "name", "details.quantity"},
+			},
+		},
+		{
+			name:   "tags and custom attributes",
+			params: map[string]string{"tags": "sale,new,sale", "fields": "id,attributes.color"},
+			want:   sample.Query{Tags: []string{"new", "sale"}, Fields: []string{"id", "attributes.color"}},
+		},
+		{
+			name:    "invalid tags",
+			params:  map[string]string{"tags": "sale,,new"},
+			wantErr: `Invalid tags: Invalid tag "", use up to 64 characters without spaces and commas`,
+		},
+		{
+			name:    "invalid filter",
+			params:  map[string]string{"filter": `quantity > 5 and location eq`},
+			wantErr: "Invalid filter: Syntax error at position 29: expected value",
+		},
+		{
+			name:    "unknown filter field",
+			params:  map[string]string{"filter": `quantity > 5 and color eq "red"`},
+			wantErr: "Invalid filter: Syntax error at position 18: unknown field color",
+		},
+		{
+			name:    "unknown sort field",
+			params:  map[string]string{"sort": "name,-color"},
+			wantErr: "Invalid sort: Syntax error at position 6: unknown field color",
+		},
+		{
+			name:    "empty field",
+			params:  map[string]string{"fields": "id,,name"},
+			wantErr: "Invalid fields: Syntax error at position 4: empty term",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := queryOf(events.APIGatewayProxyRequest{QueryStringParameters: tt.params})
+			assert := assert.New(t)
+			if tt.wantErr != "" {
+				assert.EqualError(err, tt.wantErr)
+				return
+			}
+			if assert.NoError(err) {
+				assert.Equal(tt.want, got)
+			}
+		})
+	}
+
+	got, err := queryOf(events.APIGatewayProxyRequest{QueryStringParameters: map[string]string{"filter": `quantity > 5`}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, &filter.Compare{Field: "details.quantity", Op: filter.OpGt, Value: float64(5), Pos: 1}, got.Filter)
+	}
+}
+
+func TestSparse(t *testing.T) {
+	items := []sample.Item{{ID: "test-item-id", Name: "test-item-name", Details: sample.Details{Location: "A1", Quantity: 5}}}
+	assert.Equal(t, []map[string]interface{}{
+		{"id": "test-item-id", "details": map[string]interface{}{"quantity": float64(5)}},
+	}, sparse(items, []string{"id", "details.quantity"}))
+}
+
 func TestCreateFrom(t *testing.T) {
 
 	tests := []struct {
@@ -87,6 +570,26 @@ func TestCreateFrom(t *testing.T) {
 			request: validItemWithID,
 			expect:  response.BadRequest(""),
 		},
+		{
+			name:    "Negative - invalid attribute",
+			request: `{"name": "unit test", "attributes": {"size": {"width": 1}}}`,
+			expect:  response.BadRequest(""),
+		},
+		{
+			name:    "Positive - attributes and tags",
+			request: `{"name": "unit test", "attributes": {"color": "blue", "weight": 1.5}, "tags": ["sale"]}`,
+			expect:  response.NoContent(),
+		},
+		{
+			name:    "Negative - expired",
+			request: `{"name": "unit test", "expiresAt": "2020-09-01T00:00:00Z"}`,
+			expect:  response.BadRequest(""),
+		},
+		{
+			name:    "Positive - expires",
+			request: `{"name": "unit test", "expiresAt": "2999-09-01T00:00:00Z"}`,
+			expect:  response.NoContent(),
+		},
 		{
 			name:    "Positive",
 			request: validItemWithoutID,
@@ -103,6 +606,44 @@ func TestCreateFrom(t *testing.T) {
 	}
 }
 
+func TestAdmin(t *testing.T) {
+	var assert = assert.New(t)
+
+	req := events.APIGatewayProxyRequest{}
+	assert.False(admin(req), "Anonymous")
+
+	req.RequestContext.Authorizer = map[string]interface{}{"groups": "users,test-admins"}
+	assert.True(admin(req), "Lambda authorizer group")
+
+	req.RequestContext.Authorizer = map[string]interface{}{
+		"claims": map[string]interface{}{"cognito:groups": "[users test-admins]"},
+	}
+	assert.True(admin(req), "Cognito group")
+
+	req.RequestContext.Authorizer = map[string]interface{}{"groups": "users,test-admins-2"}
+	assert.False(admin(req), "Other groups")
+
+	group := config.adminGroup
+	config.adminGroup = ""
+	req.RequestContext.Authorizer = map[string]interface{}{"groups": "test-admins"}
+	assert.False(admin(req), "Admin group not configured")
+	config.adminGroup = group
+}
+
+func TestActor(t *testing.T) {
+	var assert = assert.New(t)
+
+	req := events.APIGatewayProxyRequest{}
+	assert.Equal("anonymous", actor(req), "Anonymous")
+
+	req.RequestContext.Identity.APIKeyID = "test-key-id"
+	assert.Equal("apikey:test-key-id", actor(req), "API key")
+
+	req.RequestContext.Authorizer = map[string]interface{}{"principalId": "test-user"}
+	assert.Equal("test-user", actor(req), "Authorizer principal")
+}
+
 func init() {
 	isTesting = true
+	config.adminGroup = "test-admins"
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/nb-samples/aws-serverless-go/response"
+)
+
+type (
+	// AttachmentRequest describes a file to be uploaded
+	AttachmentRequest struct {
+		Name        string `json:"name"`
+		ContentType string `json:"contentType,omitempty"`
+	}
+
+	// AttachmentUpload is a new attachment with the presigned URL of its content upload (HTTP PUT)
+	AttachmentUpload struct {
+		Attachment sample.Attachment `json:"attachment"`
+		UploadURL  string            `json:"uploadUrl"`
+		ExpiresAt  time.Time         `json:"expiresAt"`
+	}
+
+	// AttachmentLink is an attachment with the presigned URL of its content download
+	AttachmentLink struct {
+		sample.Attachment
+		DownloadURL string    `json:"downloadUrl"`
+		ExpiresAt   time.Time `json:"expiresAt"`
+	}
+
+	// AttachmentList lists attachments of an item
+	AttachmentList struct {
+		Attachments []AttachmentLink `json:"attachments"`
+	}
+)
+
+// Returns the configured store of attachment content
+func attachments(ctx context.Context) *sample.AttachmentStore {
+	return sample.Attachments(config.attachmentBucket).WithContext(ctx)
+}
+
+// Adds an attachment to a resource, the content is uploaded directly to S3 by the returned URL
+func attachFrom(ctx context.Context, itemID string, body string) response.Response {
+	var req AttachmentRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return response.BadRequest(err.Error())
+	}
+	a, err := sample.NewAttachment(itemID, req.Name, req.ContentType)
+	if err != nil {
+		return response.BadRequest(err.Error())
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	repo := repository(ctx)
+	before, err := repo.Get(itemID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	store := attachments(ctx)
+	url, err := store.UploadURL(*a)
+	if err != nil {
+		return response.InternalServerError(err.Error())
+	}
+	out, err := repo.AddAttachment(itemID, *a)
+	if err == sample.ErrNotFound {
+		return response.NotFound(err.Error())
+	} else if err != nil {
+		// return 400 Bad Request for simplicity
+		return response.BadRequest(err.Error())
+	}
+	audit(ctx, sample.ItemUpdated, before, out)
+
+	// publish item event to SNS topic or EventBridge
+	if msgID, err := publisher(ctx).PublishEvent(sample.ItemUpdated, *out); err == nil {
+		fmt.Println("Event notification:", msgID)
+	}
+	upload := AttachmentUpload{Attachment: *a, UploadURL: url, ExpiresAt: time.Now().Add(store.Expiry)}
+	return response.Created(upload, attachmentsURI(ctx))
+}
+
+// Returns URI of the attachment list of the requested resource
+func attachmentsURI(ctx context.Context) string {
+	return strings.TrimSuffix(ctx.Value(keyRequestURI).(string), "/")
+}
+
+// Lists attachments of a resource with presigned download URLs
+func listAttachments(ctx context.Context, itemID string) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	item, err := repository(ctx).Get(itemID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	store := attachments(ctx)
+	list := AttachmentList{Attachments: []AttachmentLink{}}
+	for _, a := range item.Attachments {
+		url, err := store.DownloadURL(a)
+		if err != nil {
+			return response.InternalServerError(err.Error())
+		}
+		list.Attachments = append(list.Attachments, AttachmentLink{Attachment: a, DownloadURL: url, ExpiresAt: time.Now().Add(store.Expiry)})
+	}
+	return response.OK(list, nil)
+}
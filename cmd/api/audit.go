@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/nb-samples/aws-serverless-go/response"
+)
+
+// AuditPage is a page of audit entries
+type AuditPage struct {
+	Entries []sample.AuditEntry `json:"entries"`
+	Next    string              `json:"next,omitempty"`
+}
+
+// Records an audit entry of the item mutation (failures don't affect the mutation).
+// Every mutation is audited, so the item is evicted from the cache here too.
+func audit(ctx context.Context, action sample.Event, before, after *sample.Item) {
+	if after != nil {
+		invalidate(after.ID)
+	} else if before != nil {
+		invalidate(before.ID)
+	}
+
+	actor, _ := ctx.Value(keyActor).(string)
+	requestID, _ := ctx.Value(keyRequestID).(string)
+
+	entry, err := sample.AuditTrail(config.auditTableName).WithContext(ctx).Record(action, actor, requestID, before, after)
+	if err != nil {
+		log.Println("AUDIT FAILURE:", action, actor, requestID, err.Error())
+		return
+	}
+	log.Println("Audit entry:", entry.ItemID, entry.ID)
+}
+
+// Lists a page of audit entries of the resource
+func listAudit(ctx context.Context, itemID string, page sample.Page) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	entries, next, err := sample.AuditTrail(config.auditTableName).WithContext(ctx).List(itemID, page)
+	if err == sample.ErrInvalidPageToken {
+		return response.BadRequest(err.Error())
+	} else if err != nil {
+		return response.InternalServerError(err.Error())
+	}
+	if entries == nil {
+		entries = []sample.AuditEntry{}
+	}
+	return response.OK(AuditPage{Entries: entries, Next: next}, nil)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/nb-samples/aws-serverless-go/response"
+)
+
+// Maximum number of items of a batch request
+const maxBatchItems = 100
+
+type (
+	// BatchRequest lists items to create or IDs of items to get or delete
+	BatchRequest struct {
+		Items []sample.Item `json:"items,omitempty"`
+		IDs   []string      `json:"ids,omitempty"`
+	}
+
+	// BatchItemResult is an outcome of a batch request for a single item
+	BatchItemResult struct {
+		Index  int             `json:"index"`
+		ID     string          `json:"id,omitempty"`
+		Status int             `json:"status"`
+		Item   *sample.Item    `json:"item,omitempty"`
+		Error  *response.Error `json:"error,omitempty"`
+	}
+
+	// BatchResults are per-item outcomes in the order of the request
+	BatchResults struct {
+		Results []BatchItemResult `json:"results"`
+	}
+)
+
+// Parses and validates a batch request
+func batchOf(body string, items bool) (*BatchRequest, error) {
+	var req BatchRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return nil, err
+	}
+	n := len(req.IDs)
+	if items {
+		n = len(req.Items)
+	}
+	if n == 0 {
+		return nil, errors.New("Batch is empty.")
+	}
+	if n > maxBatchItems {
+		return nil, fmt.Errorf("Batch exceeds %d items.", maxBatchItems)
+	}
+	return &req, nil
+}
+
+// Returns a per-item result of the repository outcome
+func batchResult(index int, result sample.ItemResult, status int) BatchItemResult {
+	out := BatchItemResult{Index: index, ID: result.ID, Status: status, Item: result.Item}
+	switch result.Err {
+	case nil:
+		return out
+	case sample.ErrNotFound:
+		out.Status = http.StatusNotFound
+	case sample.ErrDuplicateID:
+		out.Status = http.StatusConflict
+	case sample.ErrUnprocessed:
+		out.Status = http.StatusServiceUnavailable
+	default:
+		out.Status = http.StatusInternalServerError
+	}
+	out.Error = &response.Error{Code: out.Status, Message: result.Err.Error()}
+	return out
+}
+
+// Publishes item events of the batch, SNS topic receives them in batches
+func publishBatch(ctx context.Context, event sample.Event, items []sample.Item) {
+	if config.publisher != publisherEventBridge {
+		topic := sample.SnsTopic(config.snsTopicArn).WithContext(ctx)
+		topic.Payloads = payloads(ctx)
+		result := topic.PublishEventBatch(event, items)
+		if err := result.Err(); err != nil {
+			log.Println(err.Error())
+		}
+		return
+	}
+	for _, item := range items {
+		if _, err := publisher(ctx).PublishEvent(event, item); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+// Creates new resources. IDs are auto allocated and not allowed in the messages.
+func batchCreateFrom(ctx context.Context, body string) response.Response {
+	req, err := batchOf(body, true)
+	if err != nil {
+		return response.BadRequest(err.Error())
+	}
+
+	// items with IDs are rejected, the rest is saved
+	results := make([]BatchItemResult, len(req.Items))
+	var items []sample.Item
+	var indexes []int
+	for i, item := range req.Items {
+		var invalid string
+		switch {
+		case item.ID != "":
+			invalid = "Item ID is not allowed when creating a new resource."
+		case expired(item):
+			invalid = "Item expiry must be in the future."
+		default:
+			if err := req.Items[i].Validate(); err != nil {
+				invalid = err.Error()
+			}
+		}
+		if invalid != "" {
+			results[i] = BatchItemResult{Index: i, ID: item.ID, Status: http.StatusBadRequest, Error: &response.Error{
+				Code:    http.StatusBadRequest,
+				Message: invalid,
+			}}
+			continue
+		}
+		items = append(items, req.Items[i])
+		indexes = append(indexes, i)
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	var created []sample.Item
+	for j, result := range repository(ctx).BatchSave(items) {
+		results[indexes[j]] = batchResult(indexes[j], result, http.StatusCreated)
+		if result.Err == nil {
+			audit(ctx, sample.ItemCreated, nil, result.Item)
+			created = append(created, *result.Item)
+		}
+	}
+	publishBatch(ctx, sample.ItemCreated, created)
+	return response.MultiStatus(BatchResults{Results: results})
+}
+
+// Gets resources by IDs
+func batchGetFrom(ctx context.Context, body string) response.Response {
+	req, err := batchOf(body, false)
+	if err != nil {
+		return response.BadRequest(err.Error())
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	results := make([]BatchItemResult, len(req.IDs))
+	for i, result := range repository(ctx).BatchGet(req.IDs) {
+		results[i] = batchResult(i, result, http.StatusOK)
+	}
+	return response.MultiStatus(BatchResults{Results: results})
+}
+
+// Deletes resources by IDs. Resources can be restored until purged after the retention period.
+func batchDeleteFrom(ctx context.Context, body string) response.Response {
+	req, err := batchOf(body, false)
+	if err != nil {
+		return response.BadRequest(err.Error())
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	results := make([]BatchItemResult, len(req.IDs))
+	deleted := make(map[string]bool)
+	var items []sample.Item
+	for i, result := range repository(ctx).BatchDelete(req.IDs) {
+		results[i] = batchResult(i, result, http.StatusOK)
+		if result.Err == nil && !deleted[result.ID] {
+			deleted[result.ID] = true
+			before := *result.Item
+			before.DeletedAt, before.TTL = nil, 0
+			audit(ctx, sample.ItemDeleted, &before, result.Item)
+			items = append(items, *result.Item)
+		}
+	}
+	publishBatch(ctx, sample.ItemDeleted, items)
+	return response.MultiStatus(BatchResults{Results: results})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+)
+
+// Capacity of the in-process item cache
+const itemCacheSize = 1000
+
+var (
+	// repository client and item cache survive across warm invocations
+	itemRepo  *sample.Repo
+	repoOnce  sync.Once
+	itemCache = sample.NewLRU(itemCacheSize)
+)
+
+// Returns the item store reading through the in-process cache (disabled if the cache TTL is zero)
+func items(ctx context.Context) *sample.CachedStore {
+	store := &sample.CachedStore{Store: repository(ctx), TTL: config.cacheTTL}
+	if config.cacheTTL > 0 {
+		store.Local = itemCache
+	}
+	return store
+}
+
+// Evicts changed items from the in-process cache, other function instances serve them until their cache TTL
+func invalidate(itemIDs ...string) {
+	store := sample.CachedStore{Local: itemCache}
+	for _, id := range itemIDs {
+		store.Invalidate(id)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/nb-samples/aws-serverless-go/response"
+)
+
+// Reservation expiry limits
+const (
+	defaultReservationTTL = 15 * time.Minute
+	maxReservationTTL     = 24 * time.Hour
+)
+
+type (
+	// Adjustment is a signed change of the item quantity
+	Adjustment struct {
+		Delta int `json:"delta"`
+	}
+
+	// ReservationRequest holds a quantity of the item for a number of seconds
+	ReservationRequest struct {
+		Quantity  int `json:"quantity"`
+		ExpiresIn int `json:"expiresIn,omitempty"`
+	}
+)
+
+// Parses and validates an adjustment
+func adjustmentOf(body string) (*Adjustment, error) {
+	var adj Adjustment
+	if err := json.Unmarshal([]byte(body), &adj); err != nil {
+		return nil, err
+	}
+	if adj.Delta == 0 {
+		return nil, errors.New("Delta must not be zero.")
+	}
+	return &adj, nil
+}
+
+// Parses and validates a reservation request, returns the quantity and expiry
+func reservationOf(body string) (int, time.Duration, error) {
+	var req ReservationRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return 0, 0, err
+	}
+	if req.Quantity <= 0 {
+		return 0, 0, errors.New("Quantity must be positive.")
+	}
+	ttl := time.Duration(req.ExpiresIn) * time.Second
+	switch {
+	case req.ExpiresIn == 0:
+		ttl = defaultReservationTTL
+	case req.ExpiresIn < 0 || ttl > maxReservationTTL:
+		return 0, 0, fmt.Errorf("Expiry must be between 1 and %d seconds.", int(maxReservationTTL.Seconds()))
+	}
+	return req.Quantity, ttl, nil
+}
+
+// Atomically adjusts the quantity of a resource, which never goes negative
+func adjustQuantity(ctx context.Context, itemID string, body string) response.Response {
+	adj, err := adjustmentOf(body)
+	if err != nil {
+		return response.BadRequest(err.Error())
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	repo := repository(ctx)
+	before, err := repo.Get(itemID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	out, err := repo.AdjustQuantity(itemID, adj.Delta)
+	switch err {
+	case nil:
+	case sample.ErrNotFound:
+		return response.NotFound(err.Error())
+	case sample.ErrInsufficientQuantity, sample.ErrConflict:
+		return response.Conflict(err.Error())
+	default:
+		return response.InternalServerError(err.Error())
+	}
+	audit(ctx, sample.ItemUpdated, before, out)
+
+	// publish item event to SNS topic or EventBridge
+	if msgID, err := publisher(ctx).PublishEvent(sample.ItemUpdated, *out); err == nil {
+		fmt.Println("Event notification:", msgID)
+	}
+	return response.OK(out, nil)
+}
+
+// Reserves a quantity of a resource for a pending order
+func reserve(ctx context.Context, itemID string, body string) response.Response {
+	quantity, ttl, err := reservationOf(body)
+	if err != nil {
+		return response.BadRequest(err.Error())
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	repo := repository(ctx)
+	before, err := repo.Get(itemID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	out, err := repo.Reserve(itemID, quantity, ttl)
+	switch err {
+	case nil:
+	case sample.ErrNotFound:
+		return response.NotFound(err.Error())
+	case sample.ErrInsufficientQuantity, sample.ErrConflict:
+		return response.Conflict(err.Error())
+	default:
+		return response.InternalServerError(err.Error())
+	}
+	log.Println("Reservation:", out.ID, out.ItemID, out.Quantity)
+	reserved(ctx, before)
+	return response.Created(out, reservationURI(ctx, out.ID))
+}
+
+// Audits and publishes the new state of an item after its reserved quantity changed
+func reserved(ctx context.Context, before *sample.Item) {
+	after, err := repository(ctx).Lookup(before.ID, true)
+	if err != nil {
+		log.Println("Reserved item not readable:", before.ID, err.Error())
+		invalidate(before.ID)
+		return
+	}
+	audit(ctx, sample.ItemUpdated, before, after)
+
+	// publish item event to SNS topic or EventBridge
+	if msgID, err := publisher(ctx).PublishEvent(sample.ItemUpdated, *after); err == nil {
+		fmt.Println("Event notification:", msgID)
+	}
+}
+
+// Returns URI of the reservation resource relative to the requested item resource
+func reservationURI(ctx context.Context, reservationID string) string {
+	uri := ctx.Value(keyRequestURI).(string)
+	if i := strings.LastIndex(uri, resourceItems+"/"); i >= 0 {
+		uri = uri[:i]
+	}
+	return uri + "/reservations/" + reservationID
+}
+
+// Gets a reservation by ID
+func getReservation(ctx context.Context, reservationID string) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	out, err := repository(ctx).Reservation(reservationID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	return response.OK(out, nil)
+}
+
+// Commits or releases a pending reservation
+func settleReservation(ctx context.Context, reservationID string, action string) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	// read the reserved item first to audit its last state (soft deleted items are settled too)
+	repo := repository(ctx)
+	res, err := repo.Reservation(reservationID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	before, err := repo.Lookup(res.ItemID, true)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+
+	settle := repo.Release
+	if action == actionCommit {
+		settle = repo.Commit
+	}
+	out, err := settle(reservationID)
+	switch err {
+	case nil:
+	case sample.ErrNotFound:
+		return response.NotFound(err.Error())
+	case sample.ErrReservationSettled, sample.ErrReservationExpired, sample.ErrConflict:
+		return response.Conflict(err.Error())
+	default:
+		return response.InternalServerError(err.Error())
+	}
+	reserved(ctx, before)
+	return response.OK(out, nil)
+}
+
+// TransferResult is a completed transfer with new states of both resources
+type TransferResult struct {
+	sample.Transfer
+	Source      *sample.Item `json:"source"`
+	Destination *sample.Item `json:"destination"`
+}
+
+// Parses and validates a transfer
+func transferOf(body string) (*sample.Transfer, error) {
+	var t sample.Transfer
+	if err := json.Unmarshal([]byte(body), &t); err != nil {
+		return nil, err
+	}
+	switch {
+	case t.From == "" || t.To == "":
+		return nil, errors.New("Transfer source and destination are required.")
+	case t.From == t.To:
+		return nil, errors.New("Transfer source and destination must differ.")
+	case t.Quantity <= 0:
+		return nil, errors.New("Quantity must be positive.")
+	}
+	return &t, nil
+}
+
+// Atomically moves quantity from one resource to another
+func transferFrom(ctx context.Context, body string) response.Response {
+	t, err := transferOf(body)
+	if err != nil {
+		return response.BadRequest(err.Error())
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	// read both items first to audit their last states
+	repo := repository(ctx)
+	var before [2]*sample.Item
+	for i, itemID := range []string{t.From, t.To} {
+		if before[i], err = repo.Get(itemID); err != nil {
+			// return 404 Not Found for simplicity
+			return response.NotFound(err.Error())
+		}
+	}
+	switch err = repo.Transfer(*t); err {
+	case nil:
+	case sample.ErrNotFound, sample.ErrTransferTarget:
+		return response.NotFound(err.Error())
+	case sample.ErrInsufficientQuantity, sample.ErrConflict:
+		return response.Conflict(err.Error())
+	default:
+		return response.InternalServerError(err.Error())
+	}
+
+	// audit and publish new states of both items
+	var after [2]*sample.Item
+	for i, item := range before {
+		if after[i], err = repo.Get(item.ID); err != nil {
+			log.Println("Transferred item not readable:", item.ID, err.Error())
+			continue
+		}
+		audit(ctx, sample.ItemUpdated, item, after[i])
+		if msgID, err := publisher(ctx).PublishEvent(sample.ItemUpdated, *after[i]); err == nil {
+			fmt.Println("Event notification:", msgID)
+		}
+	}
+	return response.OK(TransferResult{Transfer: *t, Source: after[0], Destination: after[1]}, nil)
+}
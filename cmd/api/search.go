@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/nb-samples/aws-serverless-go/internal/search"
+	"github.com/nb-samples/aws-serverless-go/response"
+)
+
+// Search index snapshot is reloaded by warm containers after the period
+const searchRefreshPeriod = time.Minute
+
+// SearchResults are search hits, the most relevant first
+type SearchResults struct {
+	Query string       `json:"query"`
+	Hits  []search.Hit `json:"hits"`
+}
+
+// Search index cached by the container
+var searchCache struct {
+	index    *search.Index
+	loadedAt time.Time
+}
+
+// Returns the search index loaded from its snapshot (cached for the refresh period)
+func searchIndex(ctx context.Context) (*search.Index, error) {
+	if searchCache.index != nil && time.Since(searchCache.loadedAt) < searchRefreshPeriod {
+		return searchCache.index, nil
+	}
+	index, err := sample.Snapshot(config.searchBucket, config.searchKey).WithContext(ctx).Load()
+	if err != nil {
+		return nil, err
+	}
+	searchCache.index, searchCache.loadedAt = index, time.Now()
+	return index, nil
+}
+
+// Searches resources by words of their name and description
+func searchItems(ctx context.Context, query string, limit string) response.Response {
+	if query == "" {
+		return response.BadRequest("Missing search query.")
+	}
+	n, _ := strconv.Atoi(limit)
+	if n <= 0 || n > sample.MaxPageLimit {
+		n = sample.DefaultPageLimit
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	index, err := searchIndex(ctx)
+	if err != nil {
+		return response.InternalServerError(err.Error())
+	}
+	hits := index.Search(query, n)
+	if hits == nil {
+		hits = []search.Hit{}
+	}
+	return response.OK(SearchResults{Query: query, Hits: hits}, nil)
+}
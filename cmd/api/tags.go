@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/nb-samples/aws-serverless-go/response"
+)
+
+// TagRequest lists tags to add or remove
+type TagRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// Adds or removes tags of a resource
+func tagFrom(ctx context.Context, itemID string, add bool, body string) response.Response {
+	var req TagRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return response.BadRequest(err.Error())
+	}
+	tags, err := sample.NormalizeTags(req.Tags)
+	if err != nil {
+		return response.BadRequest(err.Error())
+	}
+	if len(tags) == 0 {
+		return response.BadRequest("Tags are required.")
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	repo := repository(ctx)
+	before, err := repo.Get(itemID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	var out *sample.Item
+	if add {
+		out, err = repo.AddTags(itemID, tags)
+	} else {
+		out, err = repo.RemoveTags(itemID, tags)
+	}
+	if err == sample.ErrNotFound {
+		return response.NotFound(err.Error())
+	} else if err != nil {
+		// return 400 Bad Request for simplicity
+		return response.BadRequest(err.Error())
+	}
+	audit(ctx, sample.ItemUpdated, before, out)
+
+	// publish item event to SNS topic or EventBridge
+	if msgID, err := publisher(ctx).PublishEvent(sample.ItemUpdated, *out); err == nil {
+		fmt.Println("Event notification:", msgID)
+	}
+	return response.OK(out, nil)
+}
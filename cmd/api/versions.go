@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/nb-samples/aws-serverless-go/response"
+)
+
+// VersionPage is a page of item versions
+type VersionPage struct {
+	Versions []sample.ItemVersion `json:"versions"`
+	Next     string               `json:"next,omitempty"`
+}
+
+// Parses the version path parameter with an optional action suffix
+func versionOf(param string) (version int, action string, err error) {
+	param, action = actionOf(param)
+	if version, err = strconv.Atoi(param); err != nil || version < 1 {
+		return 0, "", fmt.Errorf("Invalid version: %v", param)
+	}
+	return version, action, nil
+}
+
+// Lists a page of versions of the resource, the newest first
+func listVersions(ctx context.Context, itemID string, page sample.Page) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	versions, next, err := repository(ctx).Versions(itemID, page)
+	if err == sample.ErrInvalidPageToken {
+		return response.BadRequest(err.Error())
+	} else if err != nil {
+		return response.InternalServerError(err.Error())
+	}
+	if versions == nil {
+		versions = []sample.ItemVersion{}
+	}
+	return response.OK(VersionPage{Versions: versions, Next: next}, nil)
+}
+
+// Gets a single version of the resource
+func getVersion(ctx context.Context, itemID string, version int) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	out, err := repository(ctx).Version(itemID, version)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	return response.OK(out, nil)
+}
+
+// Rolls the resource back to a previous version, stored as a new version
+func restoreVersion(ctx context.Context, itemID string, version int) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	repo := repository(ctx)
+	before, err := repo.Get(itemID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	out, err := repo.Restore(*before, version)
+	if err == sample.ErrNotFound {
+		return response.NotFound(err.Error())
+	} else if err == sample.ErrConflict {
+		return response.Conflict(err.Error())
+	} else if err != nil {
+		return response.BadRequest(err.Error())
+	}
+	audit(ctx, sample.ItemUpdated, before, out)
+
+	// publish item event to SNS topic or EventBridge
+	if msgID, err := publisher(ctx).PublishEvent(sample.ItemUpdated, *out); err == nil {
+		fmt.Println("Event notification:", msgID)
+	}
+	return response.OK(out, nil)
+}
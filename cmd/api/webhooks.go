@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/nb-samples/aws-serverless-go/response"
+)
+
+// Validates webhook registration data
+func validateWebhook(hook *sample.Webhook) error {
+	u, err := url.Parse(hook.URL)
+	if err != nil || u.Host == "" {
+		return errors.New("Webhook URL is invalid.")
+	}
+	if u.Scheme != "https" {
+		return errors.New("Webhook URL must use HTTPS.")
+	}
+	for _, event := range hook.Events {
+		switch event {
+		case sample.ItemCreated, sample.ItemUpdated, sample.ItemDeleted, sample.ItemRestored, sample.ItemPurged, sample.ItemExpired:
+		default:
+			return errors.New("Unsupported webhook event: " + string(event))
+		}
+	}
+	return nil
+}
+
+// Registers a new webhook. ID is auto allocated and not allowed in the message.
+// Signing secret is generated if not provided and returned only once.
+func createWebhookFrom(ctx context.Context, body string) response.Response {
+	var hook sample.Webhook
+
+	if err := json.Unmarshal([]byte(body), &hook); err != nil {
+		log.Println(err.Error())
+		return response.BadRequest(err.Error())
+	}
+	if hook.ID != "" {
+		return response.BadRequest("Webhook ID is not allowed when creating a new resource.")
+	}
+	if err := validateWebhook(&hook); err != nil {
+		return response.BadRequest(err.Error())
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	out, err := sample.WebhookRepository(config.webhookTableName).WithContext(ctx).Save(hook)
+	if err != nil {
+		// return 400 Bad Request for simplicity
+		return response.BadRequest(err.Error())
+	}
+	return response.Created(out, ctx.Value(keyRequestURI).(string)+"/"+out.ID)
+}
+
+// Lists webhook registrations without signing secrets
+func listWebhooks(ctx context.Context) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	hooks, err := sample.WebhookRepository(config.webhookTableName).WithContext(ctx).List()
+	if err != nil {
+		return response.InternalServerError(err.Error())
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return response.OK(hooks, nil)
+}
+
+// Gets a webhook registration by ID without signing secret
+func getWebhook(ctx context.Context, webhookID string) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	out, err := sample.WebhookRepository(config.webhookTableName).WithContext(ctx).Get(webhookID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	out.Secret = ""
+	return response.OK(out, nil)
+}
+
+// Updates a webhook registration. Signing secret is kept unless provided.
+func updateWebhookFrom(ctx context.Context, webhookID string, body string) response.Response {
+	var hook sample.Webhook
+
+	if err := json.Unmarshal([]byte(body), &hook); err != nil {
+		log.Println(err.Error())
+		return response.BadRequest(err.Error())
+	}
+	if hook.ID != "" && hook.ID != webhookID {
+		return response.BadRequest("Webhook ID doesn't match the resource.")
+	}
+	if err := validateWebhook(&hook); err != nil {
+		return response.BadRequest(err.Error())
+	}
+
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	repo := sample.WebhookRepository(config.webhookTableName).WithContext(ctx)
+	existing, err := repo.Get(webhookID)
+	if err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	hook.ID = existing.ID
+	hook.CreatedAt = existing.CreatedAt
+	if hook.Secret == "" {
+		hook.Secret = existing.Secret
+	}
+
+	out, err := repo.Save(hook)
+	if err != nil {
+		// return 400 Bad Request for simplicity
+		return response.BadRequest(err.Error())
+	}
+	out.Secret = ""
+	return response.OK(out, nil)
+}
+
+// Deletes a webhook registration by ID
+func deleteWebhook(ctx context.Context, webhookID string) response.Response {
+	if isTesting {
+		// unit tests exit here
+		return response.NoContent()
+	}
+
+	if err := sample.WebhookRepository(config.webhookTableName).WithContext(ctx).Delete(webhookID); err != nil {
+		// return 404 Not Found for simplicity
+		return response.NotFound(err.Error())
+	}
+	return response.NoContent()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nb-samples/aws-serverless-go/response"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	validWebhook        = `{"url": "https://example.com/hook", "events": ["item.created"]}`
+	validWebhookWithID  = `{"id": "test", "url": "https://example.com/hook"}`
+	insecureWebhook     = `{"url": "http://example.com/hook"}`
+	invalidWebhookEvent = `{"url": "https://example.com/hook", "events": ["item.unknown"]}`
+)
+
+func TestWebhookRouter(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		request events.APIGatewayProxyRequest
+		expect  int
+	}{
+		{
+			name: "Negative - DELETE collection",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceWebhooks,
+				HTTPMethod: "DELETE",
+			},
+			expect: 405,
+		},
+		{
+			name: "Negative - POST resource",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceWebhook,
+				HTTPMethod:     "POST",
+				PathParameters: map[string]string{"webhookId": "test-id-value"},
+			},
+			expect: 405,
+		},
+		{
+			name: "Negative - unknown resource",
+			request: events.APIGatewayProxyRequest{
+				Resource:   "/unknown",
+				HTTPMethod: "GET",
+			},
+			expect: 404,
+		},
+		{
+			name: "Positive - POST collection",
+			request: events.APIGatewayProxyRequest{
+				Resource:   resourceWebhooks,
+				HTTPMethod: "POST",
+				Body:       validWebhook,
+			},
+			expect: 204,
+		},
+		{
+			name: "Positive - PUT resource",
+			request: events.APIGatewayProxyRequest{
+				Resource:       resourceWebhook,
+				HTTPMethod:     "PUT",
+				Body:           validWebhook,
+				PathParameters: map[string]string{"webhookId": "test-id-value"},
+			},
+			expect: 204,
+		},
+	}
+
+	var assert = assert.New(t)
+	for _, test := range tests {
+		res, _ := router(context.Background(), test.request)
+		assert.Equal(test.expect, res.StatusCode, "Incorrect status code: %v", test.name)
+		if res.StatusCode == 405 {
+			assert.Contains(res.Headers, "Allow", "Missing HTTP header")
+		}
+	}
+}
+
+func TestCreateWebhookFrom(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		request string
+		expect  response.Response
+	}{
+		{
+			name:    "Negative - bad request",
+			request: invalidJSON,
+			expect:  response.BadRequest(""),
+		},
+		{
+			name:    "Negative - has ID",
+			request: validWebhookWithID,
+			expect:  response.BadRequest(""),
+		},
+		{
+			name:    "Negative - not HTTPS",
+			request: insecureWebhook,
+			expect:  response.BadRequest(""),
+		},
+		{
+			name:    "Negative - unknown event",
+			request: invalidWebhookEvent,
+			expect:  response.BadRequest(""),
+		},
+		{
+			name:    "Positive",
+			request: validWebhook,
+			expect:  response.NoContent(),
+		},
+	}
+
+	var assert = assert.New(t)
+	for _, test := range tests {
+		ctx := context.WithValue(context.Background(), keyRequestURI, uri)
+		res := createWebhookFrom(ctx, test.request)
+		assert.Equal(test.expect.StatusCode, res.StatusCode, "Incorrect status code: %v", test.name)
+		assert.IsType(res.Body, test.expect.Body, "Incorrect body type")
+	}
+}
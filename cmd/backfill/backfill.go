@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+)
+
+const envTableName = "DB_TABLE_NAME"
+
+// Limits of parallel table segments
+const (
+	defaultSegments = 4
+	maxSegments     = 64
+)
+
+// stopMargin is the time before the Lambda deadline reserved to report the checkpoint
+const stopMargin = 10 * time.Second
+
+type configuration struct {
+	dbTableName string
+}
+
+func (c *configuration) incomplete() bool {
+	return c.dbTableName == ""
+}
+
+var config configuration
+
+type (
+	// Request of a backfill, a checkpoint of a previous result resumes it
+	Request struct {
+		DryRun       bool                   `json:"dryRun,omitempty"`       // count items to migrate without writing them
+		Segments     int                    `json:"segments,omitempty"`     // parallel table segments (4 if zero)
+		ReadCapacity float64                `json:"readCapacity,omitempty"` // read capacity units per second (unlimited if zero)
+		Checkpoint   *sample.ScanCheckpoint `json:"checkpoint,omitempty"`   // progress of a previous run
+	}
+
+	// Result of a backfill, incomplete if the checkpoint is not done
+	Result struct {
+		DryRun        bool `json:"dryRun,omitempty"`
+		SchemaVersion int  `json:"schemaVersion"`
+		sample.BackfillProgress
+	}
+)
+
+// backfill migrates stored items to the latest schema version (replaced in unit tests)
+var backfill = func(ctx context.Context, opts sample.BackfillOptions) (sample.BackfillProgress, error) {
+	if config.incomplete() {
+		log.Fatalln("Service is not configured")
+	}
+	return sample.Repository(config.dbTableName).WithContext(ctx).Backfill(opts)
+}
+
+// Migrates stored items to the latest schema version, invoked on demand after migrations are added.
+// Runs stopped by the timeout return their checkpoint to resume from.
+func handler(ctx context.Context, req Request) (Result, error) {
+	if req.Checkpoint != nil && req.Segments <= 0 {
+		req.Segments = len(req.Checkpoint.Segments)
+	} else if req.Segments <= 0 {
+		req.Segments = defaultSegments
+	} else if req.Segments > maxSegments {
+		req.Segments = maxSegments
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-stopMargin))
+		defer cancel()
+	}
+
+	progress, err := backfill(ctx, sample.BackfillOptions{
+		Scan:   sample.ScanOptions{Segments: req.Segments, ReadCapacity: req.ReadCapacity, Checkpoint: req.Checkpoint},
+		DryRun: req.DryRun,
+		OnProgress: func(p sample.BackfillProgress) error {
+			log.Printf("Backfill progress: scanned %d, migrated %d, skipped %d", p.Scanned, p.Migrated, p.Skipped)
+			return nil
+		},
+	})
+	res := Result{DryRun: req.DryRun, SchemaVersion: sample.ItemMigrations.Latest(), BackfillProgress: progress}
+	if err != nil && ctx.Err() == nil { // runs stopped before the deadline are resumed by their result
+		if b, jsonErr := json.Marshal(progress.Checkpoint); jsonErr == nil {
+			log.Println("Backfill failed, resume from checkpoint:", string(b))
+		}
+		return res, err
+	}
+	status := "completed"
+	if !progress.Checkpoint.Done() {
+		status = "stopped"
+	}
+	log.Printf("Backfill %s: scanned %d, migrated %d, skipped %d", status, progress.Scanned, progress.Migrated, progress.Skipped)
+	return res, nil
+}
+
+func init() {
+	var ok bool
+	if config.dbTableName, ok = os.LookupEnv(envTableName); !ok {
+		log.Println("Missing environment variable:", envTableName)
+	}
+}
+
+func main() {
+	// Make the handler available for RPC by AWS Lambda
+	lambda.Start(handler)
+}
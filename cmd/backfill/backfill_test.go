@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	var gotOpts sample.BackfillOptions
+	backfill = func(ctx context.Context, opts sample.BackfillOptions) (sample.BackfillProgress, error) {
+		gotOpts = opts
+		progress := sample.BackfillProgress{Scanned: 3, Migrated: 2, Checkpoint: sample.ScanCheckpoint{
+			Segments: []sample.SegmentPosition{{Done: true}},
+		}}
+		return progress, opts.OnProgress(progress)
+	}
+
+	assert := assert.New(t)
+	res, err := handler(context.Background(), Request{DryRun: true})
+	if assert.NoError(err) {
+		assert.True(res.DryRun)
+		assert.Equal(2, res.Migrated)
+		assert.Equal(sample.ItemMigrations.Latest(), res.SchemaVersion)
+		assert.True(gotOpts.DryRun, "Dry run")
+		assert.Equal(defaultSegments, gotOpts.Scan.Segments, "Default segments")
+	}
+
+	checkpoint := &sample.ScanCheckpoint{Segments: make([]sample.SegmentPosition, 8)}
+	if _, err = handler(context.Background(), Request{Checkpoint: checkpoint, ReadCapacity: 50}); assert.NoError(err) {
+		assert.Equal(8, gotOpts.Scan.Segments, "Segments of checkpoint")
+		assert.Equal(checkpoint, gotOpts.Scan.Checkpoint, "Resumed")
+		assert.Equal(50.0, gotOpts.Scan.ReadCapacity, "Rate limit")
+	}
+	if _, err = handler(context.Background(), Request{Segments: 1000}); assert.NoError(err) {
+		assert.Equal(maxSegments, gotOpts.Scan.Segments, "Limited segments")
+	}
+
+	backfill = func(ctx context.Context, opts sample.BackfillOptions) (sample.BackfillProgress, error) {
+		<-ctx.Done()
+		return sample.BackfillProgress{Scanned: 1, Checkpoint: sample.ScanCheckpoint{
+			Segments: []sample.SegmentPosition{{Next: "next"}},
+		}}, ctx.Err()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stopMargin+10*time.Millisecond)
+	defer cancel()
+	res, err = handler(ctx, Request{})
+	if assert.NoError(err, "Stopped before the deadline") {
+		assert.False(res.Checkpoint.Done(), "Incomplete")
+		assert.Equal("next", res.Checkpoint.Segments[0].Next, "Checkpoint")
+	}
+
+	backfill = func(ctx context.Context, opts sample.BackfillOptions) (sample.BackfillProgress, error) {
+		return sample.BackfillProgress{}, errors.New("Mock DynamoDB error")
+	}
+	_, err = handler(context.Background(), Request{})
+	assert.Error(err, "Failed backfill")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+)
+
+const (
+	envTableName     = "DB_TABLE_NAME"
+	envExportBucket  = "EXPORT_BUCKET"
+	envPayloadBucket = "PAYLOAD_BUCKET"
+	envEncryptionKey = "ENCRYPTION_KEY_ID"
+)
+
+// Limits of parallel table segments
+const (
+	defaultSegments = 4
+	maxSegments     = 64
+)
+
+type configuration struct {
+	dbTableName   string
+	exportBucket  string
+	payloadBucket string
+	encryptionKey string
+}
+
+func (c *configuration) incomplete() bool {
+	return c.dbTableName == "" || c.exportBucket == ""
+}
+
+var config configuration
+
+type (
+	// Request of an export, scheduled events export with defaults
+	Request struct {
+		Format       string  `json:"format,omitempty"`       // ndjson (default) or csv
+		Key          string  `json:"key,omitempty"`          // object key (exports/items-{time}.{format} if empty)
+		Segments     int     `json:"segments,omitempty"`     // parallel table segments (4 if zero)
+		ReadCapacity float64 `json:"readCapacity,omitempty"` // read capacity units per second (unlimited if zero)
+	}
+
+	// Result of an export
+	Result struct {
+		Bucket string `json:"bucket"`
+		Key    string `json:"key"`
+		Items  int    `json:"items"`
+	}
+)
+
+// export writes items to the object and returns their number (replaced in unit tests)
+var export = func(ctx context.Context, key, format string, opts sample.ScanOptions) (int, error) {
+	if config.incomplete() {
+		log.Fatalln("Service is not configured")
+	}
+	repo := sample.Repository(config.dbTableName)
+	if config.payloadBucket != "" {
+		repo.Payloads = sample.Payloads(config.payloadBucket)
+	}
+	if config.encryptionKey != "" {
+		repo.Encryption = sample.Encryption(config.encryptionKey)
+	}
+	return sample.Exports(config.exportBucket).WithContext(ctx).Export(repo, key, format, opts)
+}
+
+// Exports live items to the export bucket, invoked on demand or on schedule (backups)
+func handler(ctx context.Context, req Request) (Result, error) {
+	if req.Format == "" {
+		req.Format = sample.FormatNDJSON
+	} else if req.Format != sample.FormatNDJSON && req.Format != sample.FormatCSV {
+		return Result{}, sample.ErrFormat
+	}
+	if req.Key == "" {
+		req.Key = fmt.Sprintf("exports/items-%s.%s", time.Now().UTC().Format("20060102T150405Z"), req.Format)
+	}
+	if req.Segments <= 0 {
+		req.Segments = defaultSegments
+	} else if req.Segments > maxSegments {
+		req.Segments = maxSegments
+	}
+
+	n, err := export(ctx, req.Key, req.Format, sample.ScanOptions{Segments: req.Segments, ReadCapacity: req.ReadCapacity})
+	log.Println("Exported items:", n, req.Key)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Bucket: config.exportBucket, Key: req.Key, Items: n}, nil
+}
+
+func init() {
+	var ok bool
+	if config.dbTableName, ok = os.LookupEnv(envTableName); !ok {
+		log.Println("Missing environment variable:", envTableName)
+	}
+	if config.exportBucket, ok = os.LookupEnv(envExportBucket); !ok {
+		log.Println("Missing environment variable:", envExportBucket)
+	}
+	config.payloadBucket = os.Getenv(envPayloadBucket)
+	config.encryptionKey = os.Getenv(envEncryptionKey)
+}
+
+func main() {
+	// Make the handler available for RPC by AWS Lambda
+	lambda.Start(handler)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	var gotKey, gotFormat string
+	var gotOpts sample.ScanOptions
+	export = func(ctx context.Context, key, format string, opts sample.ScanOptions) (int, error) {
+		gotKey, gotFormat, gotOpts = key, format, opts
+		return 2, nil
+	}
+
+	assert := assert.New(t)
+	res, err := handler(context.Background(), Request{})
+	if assert.NoError(err, "Scheduled export") {
+		assert.Equal(2, res.Items)
+		assert.Regexp(`^exports/items-\d{8}T\d{6}Z\.ndjson$`, gotKey, "Default key")
+		assert.Equal(sample.FormatNDJSON, gotFormat, "Default format")
+		assert.Equal(defaultSegments, gotOpts.Segments, "Default segments")
+	}
+
+	if _, err = handler(context.Background(), Request{Format: "csv", Key: "exports/all.csv", Segments: 1000, ReadCapacity: 50}); assert.NoError(err) {
+		assert.Equal("exports/all.csv", gotKey)
+		assert.Equal(sample.FormatCSV, gotFormat)
+		assert.Equal(maxSegments, gotOpts.Segments, "Limited segments")
+		assert.Equal(50.0, gotOpts.ReadCapacity, "Rate limit")
+	}
+
+	_, err = handler(context.Background(), Request{Format: "xml"})
+	assert.Equal(sample.ErrFormat, err)
+
+	export = func(ctx context.Context, key, format string, opts sample.ScanOptions) (int, error) {
+		return 0, errors.New("Mock DynamoDB error")
+	}
+	_, err = handler(context.Background(), Request{})
+	assert.Error(err, "Failed export")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+)
+
+const (
+	envTableName        = "DB_TABLE_NAME"
+	envVersionTableName = "VERSION_TABLE_NAME"
+	envPayloadBucket    = "PAYLOAD_BUCKET"
+	envEncryptionKey    = "ENCRYPTION_KEY_ID"
+)
+
+type configuration struct {
+	dbTableName      string
+	versionTableName string
+	payloadBucket    string
+	encryptionKey    string
+}
+
+func (c *configuration) incomplete() bool {
+	return c.dbTableName == ""
+}
+
+var config configuration
+
+// importFile imports items of the object and stores its report (replaced in unit tests)
+var importFile = func(ctx context.Context, bucket, key string) (*sample.ImportReport, error) {
+	if config.incomplete() {
+		log.Fatalln("Service is not configured")
+	}
+	repo := sample.Repository(config.dbTableName)
+	repo.VersionTableName = config.versionTableName
+	if config.payloadBucket != "" {
+		repo.Payloads = sample.Payloads(config.payloadBucket)
+	}
+	if config.encryptionKey != "" {
+		repo.Encryption = sample.Encryption(config.encryptionKey)
+	}
+	return sample.Exports(bucket).WithContext(ctx).Import(repo, key)
+}
+
+// Imports NDJSON or CSV files uploaded to the import prefix of the bucket.
+// Files failed to open are retried by the asynchronous invocation, invalid rows are reported only.
+func handler(ctx context.Context, e events.S3Event) error {
+	for _, record := range e.Records {
+		key, err := url.QueryUnescape(record.S3.Object.Key) // keys of S3 events are URL encoded
+		if err != nil {
+			log.Println("Invalid object key:", record.S3.Object.Key)
+			continue
+		}
+		report, err := importFile(ctx, record.S3.Bucket.Name, key)
+		if err != nil {
+			return err
+		}
+		log.Printf("Imported %s: %d items, %d failed, report %s\n", key, report.Imported, report.Failed, sample.ReportKey(key))
+	}
+	return nil
+}
+
+func init() {
+	var ok bool
+	if config.dbTableName, ok = os.LookupEnv(envTableName); !ok {
+		log.Println("Missing environment variable:", envTableName)
+	}
+	config.versionTableName = os.Getenv(envVersionTableName)
+	config.payloadBucket = os.Getenv(envPayloadBucket)
+	config.encryptionKey = os.Getenv(envEncryptionKey)
+}
+
+func main() {
+	// Make the handler available for RPC by AWS Lambda
+	lambda.Start(handler)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns an S3 event of an uploaded object
+func uploaded(bucket, key string) events.S3Event {
+	var record events.S3EventRecord
+	record.S3.Bucket.Name = bucket
+	record.S3.Object.Key = key
+	return events.S3Event{Records: []events.S3EventRecord{record}}
+}
+
+func TestHandler(t *testing.T) {
+	var gotBucket, gotKey string
+	importFile = func(ctx context.Context, bucket, key string) (*sample.ImportReport, error) {
+		gotBucket, gotKey = bucket, key
+		return &sample.ImportReport{Source: key, Imported: 1}, nil
+	}
+
+	assert := assert.New(t)
+	if assert.NoError(handler(context.Background(), uploaded("mock-bucket", "imports/new+items%282%29.csv"))) {
+		assert.Equal("mock-bucket", gotBucket)
+		assert.Equal("imports/new items(2).csv", gotKey, "Decoded key")
+	}
+
+	importFile = func(ctx context.Context, bucket, key string) (*sample.ImportReport, error) {
+		return nil, errors.New("Mock S3 error")
+	}
+	assert.Error(handler(context.Background(), uploaded("mock-bucket", "imports/items.csv")), "Retried import")
+}
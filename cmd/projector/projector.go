@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/nb-samples/aws-serverless-go/snshttp"
+)
+
+const (
+	envReadModelTableName = "READ_MODEL_TABLE_NAME"
+	envPayloadBucket      = "PAYLOAD_BUCKET"
+)
+
+type configuration struct {
+	readModelTableName string
+	payloadBucket      string
+}
+
+func (c *configuration) incomplete() bool {
+	return c.readModelTableName == ""
+}
+
+var config configuration
+
+// apply projects an item event into the read model (replaced in unit tests)
+var apply = func(ctx context.Context, event sample.Event, item sample.Item) (bool, error) {
+	if config.incomplete() {
+		log.Fatalln("Service is not configured")
+	}
+	return sample.ReadModel(config.readModelTableName).WithContext(ctx).Apply(event, item)
+}
+
+// Returns the claim-check store of messages over the SNS limit (nil if not configured)
+func payloads(ctx context.Context) *sample.PayloadStore {
+	if config.payloadBucket == "" {
+		return nil
+	}
+	return sample.Payloads(config.payloadBucket).WithContext(ctx)
+}
+
+// Projects SNS notifications delivered via SQS into the read model.
+// Failed messages are reported individually, so only they return to the queue.
+func handler(ctx context.Context, e events.SQSEvent) (events.SQSEventResponse, error) {
+	var resp events.SQSEventResponse
+	for _, record := range e.Records {
+		if err := process(ctx, record); err != nil {
+			log.Println("Failed to process message:", record.MessageId, err.Error())
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+		}
+	}
+	return resp, nil
+}
+
+// Processes a single SQS message wrapping an SNS notification
+func process(ctx context.Context, record events.SQSMessage) error {
+	msg, err := snshttp.Parse([]byte(record.Body))
+	if err != nil {
+		return err
+	}
+	if err = msg.Resolve(payloads(ctx)); err != nil {
+		return err
+	}
+	item, err := msg.Item()
+	if err != nil {
+		return err
+	}
+
+	applied, err := apply(ctx, msg.Event(), *item)
+	if err != nil {
+		return err
+	}
+	log.Println("Projection:", msg.MessageID, msg.Event(), item.ID, applied)
+	return nil
+}
+
+func init() {
+	var ok bool
+	if config.readModelTableName, ok = os.LookupEnv(envReadModelTableName); !ok {
+		log.Println("Missing environment variable:", envReadModelTableName)
+	}
+	config.payloadBucket = os.Getenv(envPayloadBucket)
+}
+
+func main() {
+	// Make the handler available for RPC by AWS Lambda
+	lambda.Start(handler)
+}
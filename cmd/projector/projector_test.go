@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	validNotification = `{
+		"Type": "Notification",
+		"MessageId": "test-message-id",
+		"TopicArn": "arn:aws:sns:us-east-1:123456789012:test-topic",
+		"Message": "{\"id\":\"test-item-id\",\"details\":{\"location\":\"A1\",\"quantity\":5}}",
+		"Timestamp": "2020-09-01T00:00:00.000Z",
+		"SignatureVersion": "1",
+		"Signature": "dGVzdA==",
+		"SigningCertURL": "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem",
+		"MessageAttributes": {"event": {"Type": "String", "Value": "item.deleted"}}
+	}`
+	failingNotification = `{
+		"Type": "Notification",
+		"MessageId": "test-message-id",
+		"TopicArn": "arn:aws:sns:us-east-1:123456789012:test-topic",
+		"Message": "{\"id\":\"failing-item-id\"}",
+		"Timestamp": "2020-09-01T00:00:00.000Z",
+		"SignatureVersion": "1",
+		"Signature": "dGVzdA==",
+		"SigningCertURL": "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+	}`
+)
+
+func TestHandler(t *testing.T) {
+	var applied []sample.Event
+	apply = func(ctx context.Context, event sample.Event, item sample.Item) (bool, error) {
+		if item.ID == "failing-item-id" {
+			return false, errors.New("Mock projection error")
+		}
+		applied = append(applied, event)
+		return true, nil
+	}
+
+	e := events.SQSEvent{Records: []events.SQSMessage{
+		{MessageId: "valid", Body: validNotification},
+		{MessageId: "malformed", Body: `"test":"test message"`},
+		{MessageId: "failing", Body: failingNotification},
+	}}
+	resp, err := handler(context.Background(), e)
+
+	var assert = assert.New(t)
+	assert.NoError(err)
+	assert.Equal([]sample.Event{sample.ItemDeleted}, applied, "Applied events")
+	assert.Equal([]events.SQSBatchItemFailure{
+		{ItemIdentifier: "malformed"},
+		{ItemIdentifier: "failing"},
+	}, resp.BatchItemFailures, "Batch item failures")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+)
+
+const (
+	envTableName        = "DB_TABLE_NAME"
+	envVersionTableName = "VERSION_TABLE_NAME"
+	envEncryptionKey    = "ENCRYPTION_KEY_ID"
+)
+
+type configuration struct {
+	dbTableName      string
+	versionTableName string
+	encryptionKey    string
+}
+
+func (c *configuration) incomplete() bool {
+	return c.dbTableName == "" || c.encryptionKey == ""
+}
+
+var config configuration
+
+// Result of a re-encryption run
+type Result struct {
+	Reencrypted int `json:"reencrypted"`
+}
+
+// reencrypt re-encrypts items by the current key material rotated at the time (if not zero)
+// and returns their number (replaced in unit tests)
+var reencrypt = func(ctx context.Context, rotatedAt time.Time) (int, error) {
+	if config.incomplete() {
+		log.Fatalln("Service is not configured")
+	}
+	repo := sample.Repository(config.dbTableName).WithContext(ctx)
+	repo.VersionTableName = config.versionTableName
+	repo.Encryption = sample.Encryption(config.encryptionKey)
+	repo.Encryption.RotatedAt = rotatedAt
+	return repo.Reencrypt()
+}
+
+// Re-encrypts sensitive item fields after the encryption key is changed (invoked on demand) or its key material
+// is rotated (invoked by the KMS rotation event, whose time is the rotation). On-demand invocations may pass
+// the rotation time as {"time": "..."} too. Failed or timed out runs are completed by the next one with the same
+// time as re-encrypted items are skipped.
+func handler(ctx context.Context, e events.CloudWatchEvent) (Result, error) {
+	n, err := reencrypt(ctx, e.Time)
+	log.Println("Re-encrypted items:", n)
+	return Result{Reencrypted: n}, err
+}
+
+func init() {
+	var ok bool
+	if config.dbTableName, ok = os.LookupEnv(envTableName); !ok {
+		log.Println("Missing environment variable:", envTableName)
+	}
+	config.versionTableName = os.Getenv(envVersionTableName)
+	if config.encryptionKey, ok = os.LookupEnv(envEncryptionKey); !ok {
+		log.Println("Missing environment variable:", envEncryptionKey)
+	}
+}
+
+func main() {
+	// Make the handler available for RPC by AWS Lambda
+	lambda.Start(handler)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	var rotated time.Time
+	reencrypt = func(ctx context.Context, rotatedAt time.Time) (int, error) {
+		rotated = rotatedAt
+		return 3, nil
+	}
+
+	assert := assert.New(t)
+	res, err := handler(context.Background(), events.CloudWatchEvent{})
+	if assert.NoError(err) {
+		assert.Equal(3, res.Reencrypted)
+		assert.True(rotated.IsZero(), "Key change")
+	}
+
+	rotation := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	_, err = handler(context.Background(), events.CloudWatchEvent{Source: "aws.kms", DetailType: "KMS CMK Rotation", Time: rotation})
+	if assert.NoError(err) {
+		assert.Equal(rotation, rotated, "Key material rotation")
+	}
+
+	reencrypt = func(ctx context.Context, rotatedAt time.Time) (int, error) {
+		return 1, errors.New("Mock DynamoDB error")
+	}
+	res, err = handler(context.Background(), events.CloudWatchEvent{})
+	assert.Error(err, "Failed run")
+	assert.Equal(1, res.Reencrypted, "Progress of failed run")
+}
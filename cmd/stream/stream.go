@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+)
+
+const (
+	envSinks        = "STREAM_SINKS"
+	envTopicArn     = "SNS_TOPIC_ARN"
+	envPublisher    = "EVENT_PUBLISHER"
+	envEventBusName = "EVENT_BUS_NAME"
+	envSearchBucket = "SEARCH_BUCKET"
+	envSearchKey    = "SEARCH_KEY"
+	envAttachments  = "ATTACHMENT_BUCKET"
+	envPayloads     = "PAYLOAD_BUCKET"
+	envEncryption   = "ENCRYPTION_KEY_ID"
+	envVersionTable = "VERSION_TABLE_NAME"
+)
+
+// Default object key of the search index snapshot
+const defaultSearchKey = "search/items.json"
+
+// Supported change sinks
+const (
+	sinkAudit       = "audit"
+	sinkPublisher   = "publisher"
+	sinkSearch      = "search"
+	sinkTTL         = "ttl" // publisher of removals by DynamoDB TTL only, other events are published by the API
+	sinkAttachments = "attachments"
+	sinkPayloads    = "payloads" // expiry of offloaded payloads no longer referenced by items
+)
+
+// Supported event publishers
+const (
+	publisherSNS         = "sns"
+	publisherEventBridge = "eventbridge"
+)
+
+type configuration struct {
+	sinks        []string
+	snsTopicArn  string
+	publisher    string
+	eventBusName string
+	searchBucket string
+	searchKey    string
+	attachments  string
+	payloads     string
+	encryption   string
+	versioned    bool
+}
+
+// Reports whether the sink is configured
+func (c *configuration) has(sink string) bool {
+	for _, name := range c.sinks {
+		if name == sink {
+			return true
+		}
+	}
+	return false
+}
+
+var config configuration
+
+// Returns the configured publisher of item events
+func publisher(ctx context.Context) sample.Publisher {
+	if config.publisher == publisherEventBridge {
+		bus := sample.EventBus(config.eventBusName).WithContext(ctx)
+		bus.Payloads = payloads()
+		return bus
+	}
+	topic := sample.SnsTopic(config.snsTopicArn).WithContext(ctx)
+	topic.Payloads = payloads()
+	return topic
+}
+
+// Returns the claim-check store of large items and messages (nil if not configured)
+func payloads() *sample.PayloadStore {
+	if config.payloads == "" {
+		return nil
+	}
+	return sample.Payloads(config.payloads)
+}
+
+// Returns decryption of sensitive item fields (nil if not configured)
+func encryption() *sample.FieldEncryption {
+	if config.encryption == "" {
+		return nil
+	}
+	return sample.Encryption(config.encryption)
+}
+
+// Returns sinks configured for the invocation
+func sinks(ctx context.Context) []sample.ChangeSink {
+	var sinks []sample.ChangeSink
+	for _, name := range config.sinks {
+		switch name {
+		case sinkAudit:
+			sinks = append(sinks, sample.AuditLogSink{})
+		case sinkPublisher:
+			sinks = append(sinks, sample.PublisherSink{Publisher: publisher(ctx)})
+		case sinkTTL:
+			sinks = append(sinks, sample.PublisherSink{Publisher: publisher(ctx), Events: []sample.Event{sample.ItemExpired, sample.ItemPurged}})
+		case sinkAttachments:
+			sinks = append(sinks, sample.AttachmentSink{Store: sample.Attachments(config.attachments)})
+		case sinkPayloads:
+			sinks = append(sinks, sample.PayloadSink{Store: sample.Payloads(config.payloads), Versioned: config.versioned})
+		}
+	}
+	return sinks
+}
+
+// Dispatches table changes to the configured sinks.
+// Search index is loaded from its snapshot and saved after the batch, so the function must not
+// process stream shards concurrently (parallelization factor 1).
+func handler(ctx context.Context, e events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	sinks := sinks(ctx)
+	if !config.has(sinkSearch) {
+		return sample.ChangeDispatcher{Sinks: sinks, Payloads: payloads(), Encryption: encryption()}.Dispatch(ctx, e), nil
+	}
+
+	snapshot := sample.Snapshot(config.searchBucket, config.searchKey).WithContext(ctx)
+	index, err := snapshot.Load()
+	if err != nil {
+		// the whole batch is retried
+		return events.DynamoDBEventResponse{}, err
+	}
+	sinks = append(sinks, sample.SearchIndexSink{Index: sample.TextIndex{Documents: index}})
+	resp := sample.ChangeDispatcher{Sinks: sinks, Payloads: payloads(), Encryption: encryption()}.Dispatch(ctx, e)
+
+	// changes applied before a failed record are kept
+	if err := snapshot.Save(index); err != nil {
+		return events.DynamoDBEventResponse{}, err
+	}
+	return resp, nil
+}
+
+// Reads the configuration from environment variables
+func configure() {
+	config = configuration{}
+	value, ok := os.LookupEnv(envSinks)
+	if !ok {
+		value = sinkAudit
+	}
+	for _, name := range strings.Split(value, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case sinkAudit, sinkPublisher, sinkSearch, sinkTTL, sinkAttachments, sinkPayloads:
+			config.sinks = append(config.sinks, name)
+		case "":
+		default:
+			log.Println("Unsupported change sink:", name)
+		}
+	}
+
+	if config.publisher, ok = os.LookupEnv(envPublisher); !ok {
+		config.publisher = publisherSNS
+	}
+	config.snsTopicArn = os.Getenv(envTopicArn)
+	config.eventBusName = os.Getenv(envEventBusName)
+	if config.searchKey, ok = os.LookupEnv(envSearchKey); !ok {
+		config.searchKey = defaultSearchKey
+	}
+	if config.searchBucket, ok = os.LookupEnv(envSearchBucket); !ok && config.has(sinkSearch) {
+		log.Println("Missing environment variable:", envSearchBucket)
+	}
+	if config.payloads, ok = os.LookupEnv(envPayloads); !ok && config.has(sinkPayloads) {
+		log.Println("Missing environment variable:", envPayloads)
+	}
+	config.versioned = os.Getenv(envVersionTable) != "" // version records refer to replaced payloads
+	config.encryption = os.Getenv(envEncryption)
+	if config.attachments, ok = os.LookupEnv(envAttachments); !ok && config.has(sinkAttachments) {
+		log.Println("Missing environment variable:", envAttachments)
+	}
+}
+
+func init() {
+	configure()
+}
+
+func main() {
+	// Make the handler available for RPC by AWS Lambda
+	lambda.Start(handler)
+}
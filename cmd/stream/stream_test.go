@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	image := map[string]events.DynamoDBAttributeValue{
+		"id": events.NewStringAttribute("test-item-id"),
+	}
+	e := events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		{EventName: "INSERT", Change: events.DynamoDBStreamRecord{SequenceNumber: "1", NewImage: image}},
+		{EventName: "REMOVE", Change: events.DynamoDBStreamRecord{SequenceNumber: "2", OldImage: image}},
+		{EventName: "INSERT", Change: events.DynamoDBStreamRecord{SequenceNumber: "3", Keys: image}},
+	}}
+
+	resp, err := handler(context.Background(), e)
+
+	var assert = assert.New(t)
+	assert.NoError(err)
+	assert.Equal([]events.DynamoDBBatchItemFailure{{ItemIdentifier: "3"}}, resp.BatchItemFailures, "Keys only record")
+}
+
+func TestSinks(t *testing.T) {
+	config = configuration{sinks: []string{sinkAudit, sinkPublisher, sinkTTL, sinkAttachments}, publisher: publisherEventBridge}
+
+	assert.Len(t, sinks(context.Background()), 4, "Configured sinks")
+	assert.True(t, config.has(sinkAudit), "Audit sink")
+	assert.False(t, config.has(sinkSearch), "Search sink")
+}
+
+func TestConfigure(t *testing.T) {
+	defer os.Unsetenv(envSinks)
+	defer os.Unsetenv(envPublisher)
+	os.Setenv(envPublisher, publisherEventBridge)
+
+	os.Unsetenv(envSinks)
+	configure()
+	assert.Equal(t, []string{sinkAudit}, config.sinks, "Default sinks")
+
+	os.Setenv(envSinks, "audit, publisher,ttl,attachments,search,payloads,unknown,")
+	configure()
+	assert.Equal(t, []string{sinkAudit, sinkPublisher, sinkTTL, sinkAttachments, sinkSearch, sinkPayloads}, config.sinks, "Supported sinks")
+	assert.Len(t, sinks(context.Background()), 5, "Sinks of the batch, the search index is loaded by the handler")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+)
+
+const (
+	envTableName        = "DB_TABLE_NAME"
+	envReservationTable = "RESERVATION_TABLE_NAME"
+)
+
+type configuration struct {
+	dbTableName      string
+	reservationTable string
+}
+
+func (c *configuration) incomplete() bool {
+	return c.dbTableName == "" || c.reservationTable == ""
+}
+
+var config configuration
+
+// release returns the stock of reservations expired before the time (replaced in unit tests)
+var release = func(ctx context.Context, now time.Time) (int, error) {
+	if config.incomplete() {
+		log.Fatalln("Service is not configured")
+	}
+	repo := sample.Repository(config.dbTableName).WithContext(ctx)
+	repo.ReservationTableName = config.reservationTable
+	return repo.ReleaseExpired(now)
+}
+
+// Releases expired reservations on schedule. Failed runs are completed by the next one.
+func handler(ctx context.Context, e events.CloudWatchEvent) error {
+	now := e.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	n, err := release(ctx, now)
+	log.Println("Released reservations:", n)
+	return err
+}
+
+func init() {
+	var ok bool
+	if config.dbTableName, ok = os.LookupEnv(envTableName); !ok {
+		log.Println("Missing environment variable:", envTableName)
+	}
+	if config.reservationTable, ok = os.LookupEnv(envReservationTable); !ok {
+		log.Println("Missing environment variable:", envReservationTable)
+	}
+}
+
+func main() {
+	// Make the handler available for RPC by AWS Lambda
+	lambda.Start(handler)
+}
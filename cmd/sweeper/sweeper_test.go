@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	scheduled := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	var released time.Time
+	release = func(ctx context.Context, now time.Time) (int, error) {
+		released = now
+		return 2, nil
+	}
+
+	assert := assert.New(t)
+	assert.NoError(handler(context.Background(), events.CloudWatchEvent{Time: scheduled}))
+	assert.Equal(scheduled, released, "Scheduled time")
+
+	release = func(ctx context.Context, now time.Time) (int, error) {
+		return 1, errors.New("Mock DynamoDB error")
+	}
+	assert.Error(handler(context.Background(), events.CloudWatchEvent{}), "Failed run")
+}
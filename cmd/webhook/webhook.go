@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+)
+
+const (
+	envWebhookTableName  = "WEBHOOK_TABLE_NAME"
+	envDeliveryTableName = "DELIVERY_TABLE_NAME"
+	envPayloadBucket     = "PAYLOAD_BUCKET"
+)
+
+type configuration struct {
+	webhookTableName  string
+	deliveryTableName string
+	payloadBucket     string
+}
+
+func (c *configuration) incomplete() bool {
+	return c.webhookTableName == "" || c.deliveryTableName == ""
+}
+
+var (
+	isTesting bool
+	config    configuration
+)
+
+// Notification of an item event received from SNS topic or EventBridge
+type notification struct {
+	id    string
+	event sample.Event
+	item  sample.Item
+}
+
+// Returns the claim-check store of messages over the SNS limit (nil if not configured)
+func payloads(ctx context.Context) *sample.PayloadStore {
+	if config.payloadBucket == "" {
+		return nil
+	}
+	return sample.Payloads(config.payloadBucket).WithContext(ctx)
+}
+
+// Fans out item events to registered webhooks, received from SNS topic or an EventBridge rule
+// depending on the event publisher of the API.
+func handler(ctx context.Context, payload json.RawMessage) error {
+	var shape struct {
+		DetailType string `json:"detail-type"`
+	}
+	if err := json.Unmarshal(payload, &shape); err != nil {
+		return err
+	}
+	if shape.DetailType != "" {
+		var e events.CloudWatchEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return err
+		}
+		return handleBusEvent(ctx, e)
+	}
+	var e events.SNSEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return err
+	}
+	return handleNotifications(ctx, e)
+}
+
+// Fans out an EventBridge event, failed deliveries are retried and recorded by the deliverer
+func handleBusEvent(ctx context.Context, e events.CloudWatchEvent) error {
+	detail, err := payloads(ctx).Resolve(string(e.Detail))
+	if err != nil {
+		// the event is retried by the asynchronous invocation
+		return err
+	}
+	e.Detail = json.RawMessage(detail)
+	n, err := parseBusEvent(e)
+	if err != nil {
+		log.Println("Skipping malformed event:", e.ID, err.Error())
+		return nil
+	}
+	if isTesting {
+		// unit tests exit here
+		return nil
+	} else if config.incomplete() {
+		log.Fatalln("Service is not configured")
+	}
+	fanOut(ctx, n)
+	return nil
+}
+
+// Fans out SNS notifications to registered webhooks.
+// Failed deliveries are retried and recorded by the deliverer, so the handler doesn't fail the invocation
+// to avoid duplicate callbacks to the webhooks which succeeded.
+func handleNotifications(ctx context.Context, e events.SNSEvent) error {
+	for _, record := range e.Records {
+		message, err := payloads(ctx).Resolve(record.SNS.Message)
+		if err != nil {
+			// the notification is retried by the asynchronous invocation
+			return err
+		}
+		record.SNS.Message = message
+		n, err := parse(record.SNS)
+		if err != nil {
+			log.Println("Skipping malformed notification:", record.SNS.MessageID, err.Error())
+			continue
+		}
+		if isTesting {
+			// unit tests exit here
+			continue
+		} else if config.incomplete() {
+			log.Fatalln("Service is not configured")
+		}
+		fanOut(ctx, n)
+	}
+	return nil
+}
+
+// Parses an SNS notification carrying an item
+func parse(msg events.SNSEntity) (*notification, error) {
+	n := notification{id: msg.MessageID, event: sample.ItemCreated}
+	if sample.IsClaimCheck(msg.Message) {
+		return nil, sample.ErrClaimCheck
+	}
+	if err := json.Unmarshal([]byte(msg.Message), &n.item); err != nil {
+		return nil, err
+	}
+	if attr, ok := msg.MessageAttributes["event"].(map[string]interface{}); ok {
+		if value, ok := attr["Value"].(string); ok && value != "" {
+			n.event = sample.Event(value)
+		}
+	}
+	return &n, nil
+}
+
+// Parses an EventBridge event carrying an item
+func parseBusEvent(e events.CloudWatchEvent) (*notification, error) {
+	if e.Source != sample.EventSource {
+		return nil, fmt.Errorf("Unexpected event source: %v", e.Source)
+	}
+	n := notification{id: e.ID, event: sample.Event(e.DetailType)}
+	if sample.IsClaimCheck(string(e.Detail)) {
+		return nil, sample.ErrClaimCheck
+	}
+	if err := json.Unmarshal(e.Detail, &n.item); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// Delivers the notification to every subscribed webhook
+func fanOut(ctx context.Context, n *notification) {
+	hooks, err := sample.WebhookRepository(config.webhookTableName).WithContext(ctx).Subscribers(n.event)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	deliverer := sample.Deliverer(config.deliveryTableName).WithContext(ctx)
+	for _, hook := range hooks {
+		if err := deliverer.Deliver(hook, n.id, n.event, n.item); err != nil {
+			log.Println(err.Error())
+		} else {
+			log.Println("Webhook delivery:", n.id, hook.ID)
+		}
+	}
+}
+
+func init() {
+	var ok bool
+	if config.webhookTableName, ok = os.LookupEnv(envWebhookTableName); !ok {
+		log.Println("Missing environment variable:", envWebhookTableName)
+	}
+	if config.deliveryTableName, ok = os.LookupEnv(envDeliveryTableName); !ok {
+		log.Println("Missing environment variable:", envDeliveryTableName)
+	}
+	config.payloadBucket = os.Getenv(envPayloadBucket)
+}
+
+func main() {
+	// Make the handler available for RPC by AWS Lambda
+	lambda.Start(handler)
+}
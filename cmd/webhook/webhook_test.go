@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		message events.SNSEntity
+		event   sample.Event
+		itemID  string
+		wantErr bool
+	}{
+		{
+			name: "Positive - event attribute",
+			message: events.SNSEntity{
+				MessageID: "test-message-id",
+				Message:   `{"id": "test-item-id", "name": "unit test"}`,
+				MessageAttributes: map[string]interface{}{
+					"event": map[string]interface{}{"Type": "String", "Value": "item.deleted"},
+				},
+			},
+			event:  sample.ItemDeleted,
+			itemID: "test-item-id",
+		},
+		{
+			name: "Positive - default event",
+			message: events.SNSEntity{
+				MessageID: "test-message-id",
+				Message:   `{"id": "test-item-id"}`,
+			},
+			event:  sample.ItemCreated,
+			itemID: "test-item-id",
+		},
+		{
+			name: "Negative - malformed item",
+			message: events.SNSEntity{
+				MessageID: "test-message-id",
+				Message:   `"test":"test message"`,
+			},
+			wantErr: true,
+		},
+	}
+
+	var assert = assert.New(t)
+	for _, test := range tests {
+		n, err := parse(test.message)
+		if test.wantErr {
+			assert.Error(err, test.name)
+		} else if assert.NoError(err, test.name) {
+			assert.Equal(test.message.MessageID, n.id, "Incorrect message ID")
+			assert.Equal(test.event, n.event, "Incorrect event")
+			assert.Equal(test.itemID, n.item.ID, "Incorrect item ID")
+		}
+	}
+}
+
+func TestParseBusEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	n, err := parseBusEvent(events.CloudWatchEvent{
+		ID:         "test-event-id",
+		Source:     sample.EventSource,
+		DetailType: string(sample.ItemUpdated),
+		Detail:     json.RawMessage(`{"id": "test-item-id"}`),
+	})
+	if assert.NoError(err) {
+		assert.Equal("test-event-id", n.id, "Incorrect event ID")
+		assert.Equal(sample.ItemUpdated, n.event, "Incorrect event")
+		assert.Equal("test-item-id", n.item.ID, "Incorrect item ID")
+	}
+
+	_, err = parseBusEvent(events.CloudWatchEvent{Source: "other", DetailType: "other", Detail: json.RawMessage(`{}`)})
+	assert.Error(err, "Foreign source")
+
+	_, err = parseBusEvent(events.CloudWatchEvent{
+		Source:     sample.EventSource,
+		DetailType: string(sample.ItemUpdated),
+		Detail:     json.RawMessage(`{"id":"test-item-id","claimCheck":{"bucket":"test-bucket","key":"messages/test.json.gz"}}`),
+	})
+	assert.Equal(sample.ErrClaimCheck, err, "Unresolved claim check")
+}
+
+func TestHandler(t *testing.T) {
+	e, _ := json.Marshal(events.SNSEvent{Records: []events.SNSEventRecord{
+		{SNS: events.SNSEntity{MessageID: "malformed", Message: "test"}},
+		{SNS: events.SNSEntity{MessageID: "valid", Message: `{"id": "test-item-id"}`}},
+	}})
+	assert.NoError(t, handler(context.Background(), e), "SNS notifications")
+
+	e = []byte(`{"id": "test-event-id", "source": "sample.items", "detail-type": "item.created", "detail": {"id": "test-item-id"}}`)
+	assert.NoError(t, handler(context.Background(), e), "EventBridge event")
+
+	assert.Error(t, handler(context.Background(), []byte(`[]`)), "Unknown event")
+}
+
+func init() {
+	isTesting = true
+}
@@ -3,8 +3,8 @@ module github.com/nb-samples/aws-serverless-go
 go 1.15
 
 require (
-	github.com/aws/aws-lambda-go v1.19.1
-	github.com/aws/aws-sdk-go v1.34.19
+	github.com/aws/aws-lambda-go v1.28.0
+	github.com/aws/aws-sdk-go v1.44.100
 	github.com/google/uuid v1.1.2
 	github.com/stretchr/testify v1.6.1
 )
@@ -0,0 +1,120 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Builder translates expressions into DynamoDB condition and projection expressions.
+// Attribute names and values are added to the provided maps of the request.
+type Builder struct {
+	Names  map[string]*string
+	Values map[string]*dynamodb.AttributeValue
+	names  map[string]string
+}
+
+// Supported reports whether the expression can be translated into a DynamoDB condition
+func Supported(e Expr) bool {
+	supported := true
+	_ = Walk(e, func(c *Compare) error {
+		if c.Op == OpEndsWith {
+			supported = false
+		}
+		return nil
+	})
+	return supported
+}
+
+// Condition translates a supported expression into a DynamoDB condition expression
+func (b *Builder) Condition(e Expr) string {
+	switch e := e.(type) {
+	case *And:
+		return "(" + b.Condition(e.Left) + " AND " + b.Condition(e.Right) + ")"
+	case *Or:
+		return "(" + b.Condition(e.Left) + " OR " + b.Condition(e.Right) + ")"
+	case *Not:
+		return "(NOT " + b.Condition(e.Expr) + ")"
+	case *Compare:
+		return b.compare(e)
+	}
+	return ""
+}
+
+// Translates a comparison, ne is a negated eq to match in-memory evaluation of missing fields
+func (b *Builder) compare(c *Compare) string {
+	path := b.Path(c.Field)
+	if c.Value == nil {
+		if c.Op == OpEq {
+			return "attribute_not_exists(" + path + ")"
+		}
+		return "attribute_exists(" + path + ")"
+	}
+	value := b.value(c.Value)
+	switch c.Op {
+	case OpEq:
+		return path + " = " + value
+	case OpNe:
+		return "(NOT " + path + " = " + value + ")"
+	case OpGt:
+		return path + " > " + value
+	case OpGe:
+		return path + " >= " + value
+	case OpLt:
+		return path + " < " + value
+	case OpLe:
+		return path + " <= " + value
+	case OpContains:
+		return "contains(" + path + ", " + value + ")"
+	case OpStartsWith:
+		return "begins_with(" + path + ", " + value + ")"
+	}
+	return ""
+}
+
+// Path returns a placeholder of the dotted attribute path
+func (b *Builder) Path(path string) string {
+	if b.names == nil {
+		b.names = make(map[string]string)
+	}
+	parts := strings.Split(path, ".")
+	for i, name := range parts {
+		placeholder, ok := b.names[name]
+		if !ok {
+			placeholder = "#f" + strconv.Itoa(len(b.names))
+			b.names[name] = placeholder
+			b.Names[placeholder] = aws.String(name)
+		}
+		parts[i] = placeholder
+	}
+	return strings.Join(parts, ".")
+}
+
+// Returns a placeholder of the value
+func (b *Builder) value(v interface{}) string {
+	placeholder := ":f" + strconv.Itoa(len(b.Values))
+	switch v := v.(type) {
+	case string:
+		b.Values[placeholder] = &dynamodb.AttributeValue{S: aws.String(v)}
+	case float64:
+		b.Values[placeholder] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(v, 'f', -1, 64))}
+	case bool:
+		b.Values[placeholder] = &dynamodb.AttributeValue{BOOL: aws.Bool(v)}
+	}
+	return placeholder
+}
+
+// Projection returns a projection expression of the dotted attribute paths (repeated paths once)
+func (b *Builder) Projection(paths []string) string {
+	var placeholders []string
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if !seen[path] {
+			seen[path] = true
+			placeholders = append(placeholders, b.Path(path))
+		}
+	}
+	return strings.Join(placeholders, ", ")
+}
@@ -0,0 +1,77 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		input     string
+		supported bool
+		want      string
+		values    map[string]*dynamodb.AttributeValue
+	}{
+		{
+			input:     `details.quantity > 5 and name eq "A"`,
+			supported: true,
+			want:      "(#f0.#f1 > :f0 AND #f2 = :f1)",
+			values: map[string]*dynamodb.AttributeValue{
+				":f0": {N: aws.String("5")},
+				":f1": {S: aws.String("A")},
+			},
+		},
+		{
+			input:     `name ne "A" or not name startswith "B"`,
+			supported: true,
+			want:      "((NOT #f0 = :f0) OR (NOT begins_with(#f0, :f1)))",
+			values: map[string]*dynamodb.AttributeValue{
+				":f0": {S: aws.String("A")},
+				":f1": {S: aws.String("B")},
+			},
+		},
+		{
+			input:     `deletedAt eq null and active ne null and name contains "x" and active eq false`,
+			supported: true,
+			want:      "(((attribute_not_exists(#f0) AND attribute_exists(#f1)) AND contains(#f2, :f0)) AND #f1 = :f1)",
+			values: map[string]*dynamodb.AttributeValue{
+				":f0": {S: aws.String("x")},
+				":f1": {BOOL: aws.Bool(false)},
+			},
+		},
+		{
+			input:     `quantity ge 1 and name endswith "x"`,
+			supported: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.supported, Supported(e), "Supported")
+			if !tt.supported {
+				return
+			}
+			b := &Builder{Names: map[string]*string{}, Values: map[string]*dynamodb.AttributeValue{}}
+			assert.Equal(t, tt.want, b.Condition(e), "Condition")
+			assert.Equal(t, tt.values, b.Values, "Values")
+		})
+	}
+}
+
+func TestBuilder_Projection(t *testing.T) {
+	b := &Builder{Names: map[string]*string{}, Values: map[string]*dynamodb.AttributeValue{}}
+	assert.Equal(t, "#f0, #f1.#f2, #f1", b.Projection([]string{"id", "details.quantity", "details"}))
+	assert.Equal(t, map[string]*string{
+		"#f0": aws.String("id"),
+		"#f1": aws.String("details"),
+		"#f2": aws.String("quantity"),
+	}, b.Names)
+	assert.Equal(t, "#f0, #f3", b.Projection([]string{"id", "name", "id"}), "Repeated path")
+}
@@ -0,0 +1,195 @@
+// Package filter parses listing expressions: filters, sort keys and sparse fieldsets.
+// Parsed filters are evaluated in memory or translated into DynamoDB expressions.
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is a comparison operator
+type Op string
+
+// Comparison operators
+const (
+	OpEq         Op = "eq"
+	OpNe         Op = "ne"
+	OpGt         Op = "gt"
+	OpGe         Op = "ge"
+	OpLt         Op = "lt"
+	OpLe         Op = "le"
+	OpContains   Op = "contains"
+	OpStartsWith Op = "startswith"
+	OpEndsWith   Op = "endswith"
+)
+
+// SyntaxError is a parse error at a position (1-based) of the expression
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("Syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// Expr is a node of a parsed filter expression
+type Expr interface {
+	// Eval reports whether the document (dotted field paths to JSON values) matches
+	Eval(doc map[string]interface{}) bool
+}
+
+type (
+	// And matches documents matching both expressions
+	And struct{ Left, Right Expr }
+
+	// Or matches documents matching any of expressions
+	Or struct{ Left, Right Expr }
+
+	// Not matches documents not matching the expression
+	Not struct{ Expr Expr }
+
+	// Compare matches documents by a field value.
+	// Value is a string, float64, bool or nil (null).
+	Compare struct {
+		Field string
+		Op    Op
+		Value interface{}
+		Pos   int // position of the field in the expression
+	}
+)
+
+// Eval of the conjunction
+func (e *And) Eval(doc map[string]interface{}) bool {
+	return e.Left.Eval(doc) && e.Right.Eval(doc)
+}
+
+// Eval of the disjunction
+func (e *Or) Eval(doc map[string]interface{}) bool {
+	return e.Left.Eval(doc) || e.Right.Eval(doc)
+}
+
+// Eval of the negation
+func (e *Not) Eval(doc map[string]interface{}) bool {
+	return !e.Expr.Eval(doc)
+}
+
+// Eval of the comparison, missing fields and values of different types never match (except ne)
+func (e *Compare) Eval(doc map[string]interface{}) bool {
+	value, ok := doc[e.Field]
+	if !ok {
+		value = nil
+	}
+	switch e.Op {
+	case OpEq:
+		return equal(value, e.Value)
+	case OpNe:
+		return !equal(value, e.Value)
+	case OpGt:
+		c, ok := compare(value, e.Value)
+		return ok && c > 0
+	case OpGe:
+		c, ok := compare(value, e.Value)
+		return ok && c >= 0
+	case OpLt:
+		c, ok := compare(value, e.Value)
+		return ok && c < 0
+	case OpLe:
+		c, ok := compare(value, e.Value)
+		return ok && c <= 0
+	}
+
+	// lists contain equal elements like DynamoDB sets and lists
+	if list, ok := value.([]interface{}); ok && e.Op == OpContains {
+		for _, v := range list {
+			if equal(v, e.Value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	s, ok := value.(string)
+	sub, _ := e.Value.(string)
+	if !ok {
+		return false
+	}
+	switch e.Op {
+	case OpContains:
+		return strings.Contains(s, sub)
+	case OpStartsWith:
+		return strings.HasPrefix(s, sub)
+	case OpEndsWith:
+		return strings.HasSuffix(s, sub)
+	}
+	return false
+}
+
+// Reports whether values are equal (numbers, strings, booleans or nulls)
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	x, ok := a.(bool)
+	y, ok2 := b.(bool)
+	return ok && ok2 && x == y
+}
+
+// Compares numbers or strings, ok is false for other or mismatching types
+func compare(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		switch {
+		case !ok:
+			return 0, false
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+	return 0, false
+}
+
+// Walk calls the function for every comparison of the expression until it fails
+func Walk(e Expr, fn func(c *Compare) error) error {
+	switch e := e.(type) {
+	case *And:
+		if err := Walk(e.Left, fn); err != nil {
+			return err
+		}
+		return Walk(e.Right, fn)
+	case *Or:
+		if err := Walk(e.Left, fn); err != nil {
+			return err
+		}
+		return Walk(e.Right, fn)
+	case *Not:
+		return Walk(e.Expr, fn)
+	case *Compare:
+		return fn(e)
+	}
+	return nil
+}
+
+// Conjuncts splits the expression into terms of its top-level conjunction
+func Conjuncts(e Expr) []Expr {
+	if and, ok := e.(*And); ok {
+		return append(Conjuncts(and.Left), Conjuncts(and.Right)...)
+	}
+	if e == nil {
+		return nil
+	}
+	return []Expr{e}
+}
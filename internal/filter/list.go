@@ -0,0 +1,139 @@
+package filter
+
+import (
+	"sort"
+	"strings"
+)
+
+// SortKey orders documents by a field
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Splits a comma separated list into trimmed terms and their positions
+func terms(s string) (list []string, positions []int, err error) {
+	pos := 1
+	for _, term := range strings.Split(s, ",") {
+		trimmed := strings.TrimSpace(term)
+		start := pos + strings.Index(term, trimmed)
+		if trimmed == "" {
+			return nil, nil, &SyntaxError{Pos: pos, Msg: "empty term"}
+		}
+		if i := strings.IndexFunc(trimmed, func(r rune) bool {
+			return !(r == '_' || r == '.' || r == '-' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+		}); i >= 0 {
+			return nil, nil, &SyntaxError{Pos: start + i, Msg: "unexpected character " + string(trimmed[i])}
+		}
+		list = append(list, trimmed)
+		positions = append(positions, start)
+		pos += len(term) + 1
+	}
+	return list, positions, nil
+}
+
+// ParseSort parses comma separated sort keys, descending ones prefixed by "-" (e.g. "createdAt,-name").
+// The function maps every field to its path or reports it as unknown.
+func ParseSort(s string, field func(name string) (string, bool)) ([]SortKey, error) {
+	list, positions, err := terms(s)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]SortKey, len(list))
+	for i, term := range list {
+		if keys[i].Desc = strings.HasPrefix(term, "-"); keys[i].Desc {
+			term = term[1:]
+		}
+		path, ok := field(term)
+		if !ok {
+			return nil, &SyntaxError{Pos: positions[i], Msg: "unknown field " + term}
+		}
+		keys[i].Field = path
+	}
+	return keys, nil
+}
+
+// ParseFields parses a comma separated sparse fieldset (e.g. "id,name,details.quantity").
+// The function maps every field to its path or reports it as unknown.
+func ParseFields(s string, field func(name string) (string, bool)) ([]string, error) {
+	list, positions, err := terms(s)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(list))
+	for i, term := range list {
+		path, ok := field(term)
+		if !ok {
+			return nil, &SyntaxError{Pos: positions[i], Msg: "unknown field " + term}
+		}
+		paths[i] = path
+	}
+	return paths, nil
+}
+
+// Sort reorders documents (dotted field paths to JSON values) by the keys, missing values last.
+// Swap is called to reorder the sorted collection along with documents.
+func Sort(docs []map[string]interface{}, keys []SortKey, swap func(i, j int)) {
+	sort.Stable(&sorter{docs: docs, keys: keys, swap: swap})
+}
+
+type sorter struct {
+	docs []map[string]interface{}
+	keys []SortKey
+	swap func(i, j int)
+}
+
+func (s *sorter) Len() int {
+	return len(s.docs)
+}
+
+func (s *sorter) Swap(i, j int) {
+	s.docs[i], s.docs[j] = s.docs[j], s.docs[i]
+	s.swap(i, j)
+}
+
+func (s *sorter) Less(i, j int) bool {
+	for _, key := range s.keys {
+		a, b := s.docs[i][key.Field], s.docs[j][key.Field]
+		if a == nil || b == nil {
+			if (a == nil) != (b == nil) {
+				return b == nil
+			}
+			continue
+		}
+		c, ok := compare(a, b)
+		if !ok || c == 0 {
+			continue
+		}
+		return (c < 0) != key.Desc
+	}
+	return false
+}
+
+// Select returns a sparse copy of the (nested) JSON document with the dotted field paths only
+func Select(doc map[string]interface{}, paths []string) map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, path := range paths {
+		src, dst := doc, out
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			value, ok := src[part]
+			if !ok {
+				break
+			}
+			if i == len(parts)-1 {
+				dst[part] = value
+				break
+			}
+			next, ok := value.(map[string]interface{})
+			if !ok {
+				break
+			}
+			if _, ok := dst[part].(map[string]interface{}); !ok {
+				dst[part] = make(map[string]interface{})
+			}
+			src, dst = next, dst[part].(map[string]interface{})
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Resolves known test fields
+func testField(name string) (string, bool) {
+	switch name {
+	case "id", "name", "createdAt", "details.quantity":
+		return name, true
+	case "quantity":
+		return "details.quantity", true
+	}
+	return "", false
+}
+
+func TestParseSort(t *testing.T) {
+	assert := assert.New(t)
+
+	keys, err := ParseSort("createdAt, -quantity", testField)
+	if assert.NoError(err) {
+		assert.Equal([]SortKey{{Field: "createdAt"}, {Field: "details.quantity", Desc: true}}, keys)
+	}
+
+	_, err = ParseSort("name,-color", testField)
+	assert.Equal(&SyntaxError{Pos: 6, Msg: "unknown field color"}, err, "Unknown field")
+	_, err = ParseSort("name,", testField)
+	assert.Equal(&SyntaxError{Pos: 6, Msg: "empty term"}, err, "Empty term")
+	_, err = ParseSort("name desc", testField)
+	assert.Equal(&SyntaxError{Pos: 5, Msg: "unexpected character  "}, err, "Unexpected character")
+}
+
+func TestParseFields(t *testing.T) {
+	assert := assert.New(t)
+
+	fields, err := ParseFields("id,name,quantity", testField)
+	if assert.NoError(err) {
+		assert.Equal([]string{"id", "name", "details.quantity"}, fields)
+	}
+
+	_, err = ParseFields("id, color", testField)
+	assert.Equal(&SyntaxError{Pos: 5, Msg: "unknown field color"}, err, "Unknown field")
+}
+
+func TestSort(t *testing.T) {
+	docs := []map[string]interface{}{
+		{"id": "a", "name": "x", "details.quantity": float64(1)},
+		{"id": "b", "name": "y"},
+		{"id": "c", "name": "x", "details.quantity": float64(3)},
+		{"id": "d", "name": "z", "details.quantity": float64(2)},
+	}
+	ids := []string{"a", "b", "c", "d"}
+
+	Sort(docs, []SortKey{{Field: "name"}, {Field: "details.quantity", Desc: true}}, func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+	assert.Equal(t, []string{"c", "a", "b", "d"}, ids, "By name, then quantity descending")
+
+	Sort(docs, []SortKey{{Field: "details.quantity"}}, func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+	assert.Equal(t, []string{"a", "d", "c", "b"}, ids, "Missing values last")
+}
+
+func TestSelect(t *testing.T) {
+	doc := map[string]interface{}{
+		"id":   "a",
+		"name": "x",
+		"details": map[string]interface{}{
+			"quantity": float64(1),
+			"location": "A1",
+		},
+	}
+	assert.Equal(t, map[string]interface{}{
+		"id":      "a",
+		"details": map[string]interface{}{"quantity": float64(1)},
+	}, Select(doc, []string{"id", "details.quantity", "missing", "name.first"}))
+}
@@ -0,0 +1,244 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Token kinds
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind  int
+	text  string // identifier, symbol or raw number
+	value string // unquoted string literal
+	pos   int
+}
+
+// Symbolic operators
+var symbols = map[string]Op{
+	"=": OpEq, "==": OpEq, "!=": OpNe, ">": OpGt, ">=": OpGe, "<": OpLt, "<=": OpLe,
+}
+
+// Word operators
+var words = map[string]Op{
+	"eq": OpEq, "ne": OpNe, "gt": OpGt, "ge": OpGe, "lt": OpLt, "le": OpLe,
+	"contains": OpContains, "startswith": OpStartsWith, "endswith": OpEndsWith,
+}
+
+// Splits the expression into tokens
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(r), pos: i + 1})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			j := i + 1
+			if j < len(runes) && runes[j] == '=' {
+				j++
+			}
+			text := string(runes[i:j])
+			if _, ok := symbols[text]; !ok {
+				return nil, &SyntaxError{Pos: i + 1, Msg: "unknown operator " + strconv.Quote(text)}
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: text, pos: i + 1})
+			i = j
+		case r == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				b.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, &SyntaxError{Pos: i + 1, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, token{kind: tokenString, value: b.String(), pos: i + 1})
+			i = j + 1
+		case r == '-' || unicode.IsDigit(r):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			text := string(runes[i:j])
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return nil, &SyntaxError{Pos: i + 1, Msg: "invalid number " + strconv.Quote(text)}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, pos: i + 1})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[i:j]), pos: i + 1})
+			i = j
+		default:
+			return nil, &SyntaxError{Pos: i + 1, Msg: "unexpected character " + strconv.QuoteRune(r)}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes) + 1}), nil
+}
+
+// Recursive descent parser of filter expressions
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+// Reports whether the next token is the keyword and consumes it
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(); t.kind == tokenIdent && strings.EqualFold(t.text, word) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// Parse a filter expression, e.g. `quantity > 5 and location eq "A1"`.
+// Terms are combined by and, or, not and parentheses; values are strings, numbers, true, false or null.
+func Parse(s string) (Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, &SyntaxError{Pos: 1, Msg: "empty expression"}
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &SyntaxError{Pos: t.pos, Msg: "expected and, or or end of expression"}
+	}
+	return e, nil
+}
+
+// or := and ("or" and)*
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	for err == nil && p.keyword("or") {
+		var right Expr
+		if right, err = p.and(); err == nil {
+			left = &Or{Left: left, Right: right}
+		}
+	}
+	return left, err
+}
+
+// and := unary ("and" unary)*
+func (p *parser) and() (Expr, error) {
+	left, err := p.unary()
+	for err == nil && p.keyword("and") {
+		var right Expr
+		if right, err = p.unary(); err == nil {
+			left = &And{Left: left, Right: right}
+		}
+	}
+	return left, err
+}
+
+// unary := "not" unary | "(" or ")" | comparison
+func (p *parser) unary() (Expr, error) {
+	if p.keyword("not") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Expr: e}, nil
+	}
+	if t := p.peek(); t.kind == tokenSymbol && t.text == "(" {
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenSymbol || t.text != ")" {
+			return nil, &SyntaxError{Pos: t.pos, Msg: "expected )"}
+		}
+		return e, nil
+	}
+	return p.comparison()
+}
+
+// comparison := field operator value
+func (p *parser) comparison() (Expr, error) {
+	field := p.next()
+	if field.kind != tokenIdent {
+		return nil, &SyntaxError{Pos: field.pos, Msg: "expected field name"}
+	}
+
+	t := p.next()
+	var op Op
+	var ok bool
+	switch t.kind {
+	case tokenSymbol:
+		op, ok = symbols[t.text]
+	case tokenIdent:
+		op, ok = words[strings.ToLower(t.text)]
+	}
+	if !ok {
+		return nil, &SyntaxError{Pos: t.pos, Msg: "expected operator"}
+	}
+
+	t = p.next()
+	var value interface{}
+	switch {
+	case t.kind == tokenString:
+		value = t.value
+	case t.kind == tokenNumber:
+		value, _ = strconv.ParseFloat(t.text, 64)
+	case t.kind == tokenIdent && strings.EqualFold(t.text, "true"):
+		value = true
+	case t.kind == tokenIdent && strings.EqualFold(t.text, "false"):
+		value = false
+	case t.kind == tokenIdent && strings.EqualFold(t.text, "null"):
+		value = nil
+	default:
+		return nil, &SyntaxError{Pos: t.pos, Msg: "expected value"}
+	}
+
+	// operators accept only comparable values
+	switch op {
+	case OpGt, OpGe, OpLt, OpLe:
+		switch value.(type) {
+		case string, float64:
+		default:
+			return nil, &SyntaxError{Pos: t.pos, Msg: "operator " + string(op) + " expects a string or number"}
+		}
+	case OpContains, OpStartsWith, OpEndsWith:
+		if _, ok := value.(string); !ok {
+			return nil, &SyntaxError{Pos: t.pos, Msg: "operator " + string(op) + " expects a string"}
+		}
+	}
+	return &Compare{Field: field.text, Op: op, Value: value, Pos: field.pos}, nil
+}
@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	doc := map[string]interface{}{
+		"name":             "Blue widget",
+		"details.location": "A1",
+		"details.quantity": float64(7),
+		"active":           true,
+		"tags":             []interface{}{"new", "sale"},
+	}
+	tests := []struct {
+		name  string
+		input string
+		match bool
+	}{
+		{name: "symbolic operator", input: `quantity>5`, match: true},
+		{name: "word operator", input: `location eq "A1"`, match: true},
+		{name: "conjunction", input: `quantity > 5 and location eq "B2"`, match: false},
+		{name: "disjunction", input: `quantity gt 10 or location = "A1"`, match: true},
+		{name: "precedence", input: `location eq "B2" and quantity > 5 or name startswith "Blue"`, match: true},
+		{name: "parentheses", input: `location eq "B2" and (quantity > 5 or name startswith "Blue")`, match: false},
+		{name: "negation", input: `not location eq "B2"`, match: true},
+		{name: "keywords ignore case", input: `quantity GE 7 AND name CONTAINS "widget"`, match: true},
+		{name: "suffix", input: `name endswith "widget"`, match: true},
+		{name: "escaped string", input: `name eq "Blue \"widget\""`, match: false},
+		{name: "boolean", input: `active eq true`, match: true},
+		{name: "missing field is null", input: `description eq null`, match: true},
+		{name: "missing field never compares", input: `description < "z"`, match: false},
+		{name: "missing field is not equal", input: `description ne "z"`, match: true},
+		{name: "mismatching types", input: `quantity eq "7"`, match: false},
+		{name: "negative number", input: `quantity > -1.5`, match: true},
+		{name: "list element", input: `tags contains "sale"`, match: true},
+		{name: "list element is whole", input: `tags contains "sal"`, match: false},
+	}
+
+	aliases := map[string]string{"quantity": "details.quantity", "location": "details.location", "description": "details.description"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.input)
+			if assert.NoError(t, err) {
+				_ = Walk(e, func(c *Compare) error {
+					if path, ok := aliases[c.Field]; ok {
+						c.Field = path
+					}
+					return nil
+				})
+				assert.Equal(t, tt.match, e.Eval(doc))
+			}
+		})
+	}
+}
+
+func TestParse_SyntaxError(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{input: ``, pos: 1, msg: "empty expression"},
+		{input: `quantity >`, pos: 11, msg: "expected value"},
+		{input: `quantity 5`, pos: 10, msg: "expected operator"},
+		{input: `quantity > 5 location eq "A1"`, pos: 14, msg: "expected and, or or end of expression"},
+		{input: `(quantity > 5`, pos: 14, msg: "expected )"},
+		{input: `quantity > 5 and > 1`, pos: 18, msg: "expected field name"},
+		{input: `name eq "open`, pos: 9, msg: "unterminated string"},
+		{input: `quantity => 5`, pos: 11, msg: "expected value"},
+		{input: `quantity ! 5`, pos: 10, msg: `unknown operator "!"`},
+		{input: `quantity > 1.2.3`, pos: 12, msg: `invalid number "1.2.3"`},
+		{input: `name contains 5`, pos: 15, msg: "operator contains expects a string"},
+		{input: `active gt true`, pos: 11, msg: "operator gt expects a string or number"},
+		{input: `name eq 'A1'`, pos: 9, msg: `unexpected character '\''`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if assert.IsType(t, &SyntaxError{}, err) {
+				assert.Equal(t, tt.pos, err.(*SyntaxError).Pos, "Position")
+				assert.Equal(t, tt.msg, err.(*SyntaxError).Msg, "Message")
+			}
+		})
+	}
+}
+
+func TestConjuncts(t *testing.T) {
+	e, _ := Parse(`a = 1 and (b = 2 or c = 3) and not d = 4`)
+	terms := Conjuncts(e)
+	if assert.Len(t, terms, 3) {
+		assert.IsType(t, &Compare{}, terms[0])
+		assert.IsType(t, &Or{}, terms[1])
+		assert.IsType(t, &Not{}, terms[2])
+	}
+	assert.Empty(t, Conjuncts(nil))
+}
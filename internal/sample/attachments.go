@@ -0,0 +1,252 @@
+package sample
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/google/uuid"
+)
+
+// Limits of item attachments
+const (
+	MaxAttachments       = 20  // attachments per item
+	MaxAttachmentName    = 255 // characters of a file name
+	DefaultPresignExpiry = 15 * time.Minute
+)
+
+// Attachment is metadata of a file stored in S3, the content is uploaded and downloaded by presigned URLs
+type Attachment struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	ContentType string     `json:"contentType"`
+	Key         string     `json:"-" dynamodbav:"key"` // object key in the attachment bucket
+	CreatedAt   *time.Time `json:"createdAt,omitempty"`
+}
+
+// NewAttachment returns validated metadata of a new attachment of the item
+func NewAttachment(itemID, name, contentType string) (*Attachment, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("Missing attachment name")
+	}
+	if len([]rune(name)) > MaxAttachmentName || strings.ContainsAny(name, "/\\\"") {
+		return nil, fmt.Errorf("Invalid attachment name %q", name)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	} else if t, _, err := mime.ParseMediaType(contentType); err != nil || !strings.Contains(t, "/") {
+		return nil, fmt.Errorf("Invalid content type %q", contentType)
+	}
+	id := uuid.New().String()
+	now := time.Now()
+	return &Attachment{
+		ID:          id,
+		Name:        name,
+		ContentType: contentType,
+		Key:         AttachmentPrefix(itemID) + id,
+		CreatedAt:   &now,
+	}, nil
+}
+
+// AttachmentPrefix returns the common key prefix of objects attached to the item
+func AttachmentPrefix(itemID string) string {
+	return "items/" + itemID + "/"
+}
+
+// AddAttachment appends attachment metadata to an existing resource and increments its version.
+// Versioned resources are changed by Update to record the attachments in the version history.
+func (r *Repo) AddAttachment(itemID string, a Attachment) (*Item, error) {
+	if itemID == "" {
+		return nil, errors.New("Missing resource ID")
+	}
+	if r.VersionTableName != "" {
+		return r.modify(itemID, func(item *Item) error {
+			if len(item.Attachments) >= MaxAttachments {
+				return fmt.Errorf("Too many attachments, at most %d are allowed", MaxAttachments)
+			}
+			item.Attachments = append(item.Attachments, a)
+			return nil
+		})
+	}
+	list, err := dynamodbattribute.Marshal([]Attachment{a})
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	updatedAt, err := dynamodbattribute.Marshal(time.Now())
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	update := "SET updatedAt = :updatedAt, #attachments = list_append(if_not_exists(#attachments, :empty), :attachment) ADD #version :one"
+	condition := "attribute_exists(id) AND attribute_not_exists(deletedAt) AND (attribute_not_exists(#attachments) OR size(#attachments) < :max)"
+	names := map[string]*string{
+		"#attachments": aws.String("attachments"),
+		"#version":     aws.String("version"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":attachment": list,
+		":empty":      {L: []*dynamodb.AttributeValue{}},
+		":updatedAt":  updatedAt,
+		":one":        {N: aws.String("1")},
+		":max":        {N: aws.String(strconv.Itoa(MaxAttachments))},
+	}
+
+	// execute query
+	item, err := r.update(itemID, update, condition, names, values)
+	if err == ErrNotFound {
+		if _, err := r.Get(itemID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Too many attachments, at most %d are allowed", MaxAttachments)
+	}
+	return item, err
+}
+
+// AttachmentStore provides S3 client capabilities for attachment content
+type AttachmentStore struct {
+	Client s3iface.S3API
+	Bucket string
+	Expiry time.Duration // validity of presigned URLs
+	Retry  RetryPolicy   // retries of throttled operations (single attempt if zero)
+	ctx    context.Context
+}
+
+// Attachments returns a configured S3 client for attachments
+func Attachments(bucket string) *AttachmentStore {
+
+	// retries are controlled by the store policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &AttachmentStore{
+		Client: s3.New(sess),
+		Bucket: bucket,
+		Expiry: DefaultPresignExpiry,
+		Retry:  DefaultRetryPolicy,
+	}
+}
+
+// WithContext returns a shallow copy of the store bound to the context (e.g. Lambda deadline)
+func (s *AttachmentStore) WithContext(ctx context.Context) *AttachmentStore {
+	store := *s
+	store.ctx = ctx
+	return &store
+}
+
+// Returns the bound context or a background one
+func (s *AttachmentStore) context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
+// UploadURL returns a presigned URL to PUT the attachment content, the upload must send the same content type
+func (s *AttachmentStore) UploadURL(a Attachment) (string, error) {
+	req, _ := s.Client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:      &s.Bucket,
+		Key:         aws.String(a.Key),
+		ContentType: aws.String(a.ContentType),
+	})
+	url, err := req.Presign(s.Expiry)
+	if err != nil {
+		log.Println(err.Error())
+		return "", errors.New("Failed to presign attachment upload")
+	}
+	return url, nil
+}
+
+// DownloadURL returns a presigned URL to GET the attachment content under its file name
+func (s *AttachmentStore) DownloadURL(a Attachment) (string, error) {
+	req, _ := s.Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     &s.Bucket,
+		Key:                        aws.String(a.Key),
+		ResponseContentType:        aws.String(a.ContentType),
+		ResponseContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})),
+	})
+	url, err := req.Presign(s.Expiry)
+	if err != nil {
+		log.Println(err.Error())
+		return "", errors.New("Failed to presign attachment download")
+	}
+	return url, nil
+}
+
+// DeletePrefix deletes all objects under the key prefix (e.g. attachments of an item)
+func (s *AttachmentStore) DeletePrefix(prefix string) error {
+	if prefix == "" {
+		return errors.New("Missing key prefix")
+	}
+	input := &s3.ListObjectsV2Input{Bucket: &s.Bucket, Prefix: &prefix}
+	for {
+		// execute query
+		var res *s3.ListObjectsV2Output
+		err := s.Retry.Do(s.context(), func() (err error) {
+			res, err = s.Client.ListObjectsV2(input)
+			return
+		})
+		if err != nil {
+			log.Println(err.Error())
+			return errors.New("Failed to list attachments")
+		}
+		if len(res.Contents) > 0 {
+			if err := s.delete(res.Contents); err != nil {
+				return err
+			}
+		}
+		if !aws.BoolValue(res.IsTruncated) {
+			return nil
+		}
+		input.ContinuationToken = res.NextContinuationToken
+	}
+}
+
+// Deletes a page of listed objects
+func (s *AttachmentStore) delete(objects []*s3.Object) error {
+	del := &s3.Delete{Quiet: aws.Bool(true)}
+	for _, o := range objects {
+		del.Objects = append(del.Objects, &s3.ObjectIdentifier{Key: o.Key})
+	}
+	input := &s3.DeleteObjectsInput{Bucket: &s.Bucket, Delete: del}
+
+	// execute query
+	var res *s3.DeleteObjectsOutput
+	err := s.Retry.Do(s.context(), func() (err error) {
+		res, err = s.Client.DeleteObjects(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return errors.New("Failed to delete attachments")
+	}
+	if len(res.Errors) > 0 {
+		log.Println("Failed to delete:", aws.StringValue(res.Errors[0].Key), aws.StringValue(res.Errors[0].Message))
+		return errors.New("Failed to delete attachments")
+	}
+	return nil
+}
+
+// AttachmentSink deletes attachment content of items removed from the table
+type AttachmentSink struct {
+	Store *AttachmentStore
+}
+
+// Handle deletes objects of purged, expired and hard deleted items, soft deleted items keep them to be restorable
+func (s AttachmentSink) Handle(ctx context.Context, change Change) error {
+	if change.Type != ChangeRemove || change.Old == nil || len(change.Old.Attachments) == 0 {
+		return nil
+	}
+	return s.Store.WithContext(ctx).DeletePrefix(AttachmentPrefix(change.Old.ID))
+}
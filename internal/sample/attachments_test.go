@@ -0,0 +1,266 @@
+package sample
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+// Local S3-compatible stand-in serving a single bucket with path-style addressing.
+// Signatures are not verified, presigned requests only need to carry one.
+type localS3 struct {
+	bucket  string
+	mu      sync.Mutex
+	objects map[string]localObject
+}
+
+type localObject struct {
+	body        []byte
+	contentType string
+	tags        map[string]string
+}
+
+func (s *localS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	key := strings.TrimPrefix(strings.TrimPrefix(path, s.bucket), "/")
+	if !strings.HasPrefix(path, s.bucket) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	query := r.URL.Query()
+	switch {
+	case r.Method == "PUT" && key != "" && query["tagging"] != nil:
+		var req struct {
+			Tags []struct{ Key, Value string } `xml:"TagSet>Tag"`
+		}
+		o, ok := s.objects[key]
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || !ok {
+			http.Error(w, "MalformedXML", http.StatusBadRequest)
+			return
+		}
+		o.tags = map[string]string{}
+		for _, tag := range req.Tags {
+			o.tags[tag.Key] = tag.Value
+		}
+		s.objects[key] = o
+	case r.Method == "PUT" && key != "":
+		if query.Get("X-Amz-Signature") == "" && r.Header.Get("Authorization") == "" {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.objects[key] = localObject{body: body, contentType: r.Header.Get("Content-Type")}
+	case r.Method == "GET" && key == "" && query.Get("list-type") == "2":
+		type content struct {
+			Key string
+		}
+		var res struct {
+			XMLName     xml.Name `xml:"ListBucketResult"`
+			Name        string
+			Prefix      string
+			IsTruncated bool
+			Contents    []content
+		}
+		res.Name, res.Prefix = s.bucket, query.Get("prefix")
+		for k := range s.objects {
+			if strings.HasPrefix(k, res.Prefix) {
+				res.Contents = append(res.Contents, content{Key: k})
+			}
+		}
+		sort.Slice(res.Contents, func(i, j int) bool { return res.Contents[i].Key < res.Contents[j].Key })
+		_ = xml.NewEncoder(w).Encode(res)
+	case r.Method == "GET" && key != "":
+		o, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("<Error><Code>NoSuchKey</Code></Error>"))
+			return
+		}
+		w.Header().Set("Content-Type", query.Get("response-content-type"))
+		w.Header().Set("Content-Disposition", query.Get("response-content-disposition"))
+		_, _ = w.Write(o.body)
+	case r.Method == "DELETE" && key != "":
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "POST" && key == "" && query["delete"] != nil:
+		var req struct {
+			Objects []struct{ Key string } `xml:"Object"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "MalformedXML", http.StatusBadRequest)
+			return
+		}
+		for _, o := range req.Objects {
+			delete(s.objects, o.Key)
+		}
+		_, _ = w.Write([]byte("<DeleteResult></DeleteResult>"))
+	default:
+		http.Error(w, "NotImplemented", http.StatusNotImplemented)
+	}
+}
+
+// Returns an S3 client of a local S3 stand-in
+func localClient(t *testing.T) (*s3.S3, *localS3) {
+	local := &localS3{bucket: "mock-bucket", objects: map[string]localObject{}}
+	srv := httptest.NewServer(local)
+	t.Cleanup(srv.Close)
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithEndpoint(srv.URL).
+		WithRegion("us-east-1").
+		WithS3ForcePathStyle(true).
+		WithCredentials(credentials.NewStaticCredentials("mock-id", "mock-secret", "")).
+		WithMaxRetries(0)))
+	return s3.New(sess), local
+}
+
+// Returns an attachment store backed by a local S3 stand-in
+func localAttachments(t *testing.T) (*AttachmentStore, *localS3) {
+	client, local := localClient(t)
+	return &AttachmentStore{Client: client, Bucket: local.bucket, Expiry: DefaultPresignExpiry}, local
+}
+
+func TestAttachmentStore(t *testing.T) {
+	store, local := localAttachments(t)
+	assert := assert.New(t)
+
+	a, err := NewAttachment("test-item-id", "spec sheet.pdf", "application/pdf")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("items/test-item-id/"+a.ID, a.Key, "Object key")
+
+	// upload by the presigned URL
+	upload, err := store.UploadURL(*a)
+	if !assert.NoError(err, "Upload URL") {
+		return
+	}
+	req, _ := http.NewRequest("PUT", upload, bytes.NewReader([]byte("%PDF-1.4")))
+	req.Header.Set("Content-Type", a.ContentType)
+	if res, err := http.DefaultClient.Do(req); assert.NoError(err) {
+		res.Body.Close()
+		assert.Equal(http.StatusOK, res.StatusCode, "Uploaded")
+	}
+	assert.Equal("application/pdf", local.objects[a.Key].contentType, "Stored content type")
+
+	// download by the presigned URL
+	download, err := store.DownloadURL(*a)
+	if !assert.NoError(err, "Download URL") {
+		return
+	}
+	if res, err := http.Get(download); assert.NoError(err) {
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal("%PDF-1.4", string(body), "Downloaded")
+		assert.Equal(`attachment; filename="spec sheet.pdf"`, res.Header.Get("Content-Disposition"), "File name")
+	}
+
+	// cleanup of the item
+	local.objects["items/other-item-id/1"] = localObject{}
+	assert.NoError(store.WithContext(context.Background()).DeletePrefix(AttachmentPrefix("test-item-id")))
+	assert.NotContains(local.objects, a.Key, "Deleted")
+	assert.Contains(local.objects, "items/other-item-id/1", "Other items kept")
+	assert.Error(store.DeletePrefix(""), "Missing prefix")
+}
+
+func TestNewAttachment(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileName    string
+		contentType string
+		wantType    string
+		wantErr     bool
+	}{
+		{name: "with content type", fileName: "photo.jpg", contentType: "image/jpeg", wantType: "image/jpeg"},
+		{name: "default content type", fileName: "data.bin", wantType: "application/octet-stream"},
+		{name: "missing name", fileName: " ", wantErr: true},
+		{name: "path in name", fileName: "../photo.jpg", wantErr: true},
+		{name: "invalid content type", fileName: "photo.jpg", contentType: "jpeg", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewAttachment("test-item-id", tt.fileName, tt.contentType)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantType, got.ContentType)
+				assert.NotEmpty(t, got.ID)
+			}
+		})
+	}
+}
+
+func TestRepo_AddAttachment(t *testing.T) {
+	assert := assert.New(t)
+	client := &mockDdbUpdates{mockDdb: mockDdb{item: &Item{ID: "test-item-id"}}}
+	r := &Repo{Client: client, TableName: "mock-table"}
+
+	a, _ := NewAttachment("test-item-id", "photo.jpg", "image/jpeg")
+	if _, err := r.AddAttachment("test-item-id", *a); assert.NoError(err) {
+		assert.Contains(*client.input.UpdateExpression, "list_append(if_not_exists(#attachments, :empty), :attachment)")
+		assert.Contains(*client.input.ConditionExpression, "size(#attachments) < :max", "Limited attachments")
+		if list := client.input.ExpressionAttributeValues[":attachment"].L; assert.Len(list, 1) {
+			assert.Equal(a.Key, aws.StringValue(list[0].M["key"].S), "Stored object key")
+		}
+	}
+	_, err := r.AddAttachment("", *a)
+	assert.Error(err, "Missing ID")
+}
+
+func TestRepo_AddAttachmentVersioned(t *testing.T) {
+	client := newMockVersionedDdb()
+	r := &Repo{Client: client, TableName: "mock-table", VersionTableName: "mock-version-table"}
+
+	assert := assert.New(t)
+	item, err := r.Save(Item{Name: "test-item-name"})
+	if !assert.NoError(err, "Save") {
+		return
+	}
+
+	a, _ := NewAttachment(item.ID, "photo.jpg", "image/jpeg")
+	if got, err := r.AddAttachment(item.ID, *a); assert.NoError(err, "Add") && assert.Len(got.Attachments, 1) {
+		assert.Equal(a.Key, got.Attachments[0].Key, "Stored object key")
+		assert.Equal(2, got.Version, "Next version")
+	}
+	assert.Len(client.versions[item.ID], 2, "Attachment recorded in the version history")
+	if v, err := r.Version(item.ID, 2); assert.NoError(err, "Current version") {
+		assert.Len(v.Item.Attachments, 1, "Recorded attachments")
+	}
+}
+
+func TestAttachmentSink(t *testing.T) {
+	store, local := localAttachments(t)
+	local.objects["items/a/1"] = localObject{}
+	local.objects["items/b/1"] = localObject{}
+	sink := AttachmentSink{Store: store}
+	withFiles := func(id string) *Item {
+		return &Item{ID: id, Attachments: []Attachment{{ID: "1", Key: "items/" + id + "/1"}}}
+	}
+
+	assert := assert.New(t)
+	assert.NoError(sink.Handle(context.Background(), Change{Type: ChangeModify, Old: withFiles("a"), New: &Item{ID: "a"}}))
+	assert.Contains(local.objects, "items/a/1", "Kept on modify")
+
+	assert.NoError(sink.Handle(context.Background(), Change{Type: ChangeRemove, Old: withFiles("a")}))
+	assert.NotContains(local.objects, "items/a/1", "Deleted on remove")
+	assert.Contains(local.objects, "items/b/1", "Other item kept")
+}
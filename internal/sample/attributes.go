@@ -0,0 +1,191 @@
+package sample
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Limits of custom attributes and tags
+const (
+	MaxAttributes      = 50   // attributes per item
+	MaxAttributeString = 1024 // characters of a string value
+	MaxAttributeList   = 100  // elements of a list value
+	MaxTags            = 50   // tags per item
+	MaxTagLength       = 64   // characters of a tag
+)
+
+var (
+	// attribute keys are usable as filter fields (e.g. attributes.color)
+	attributeKey = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+	// tags are listed comma separated in queries
+	tagPattern = regexp.MustCompile(`^[^\s,]+$`)
+)
+
+// Validate checks custom attributes and tags of the item, tags are deduplicated and sorted
+func (item *Item) Validate() error {
+	if err := ValidateAttributes(item.Attributes); err != nil {
+		return err
+	}
+	tags, err := NormalizeTags(item.Tags)
+	if err != nil {
+		return err
+	}
+	item.Tags = tags
+	return nil
+}
+
+// ValidateAttributes checks keys and values of custom attributes.
+// Values are strings, numbers, booleans or lists of them.
+func ValidateAttributes(attrs map[string]interface{}) error {
+	if len(attrs) > MaxAttributes {
+		return fmt.Errorf("Too many attributes, at most %d are allowed", MaxAttributes)
+	}
+	for key, value := range attrs {
+		if !attributeKey.MatchString(key) {
+			return fmt.Errorf("Invalid attribute name %q, use letters, digits and underscores", key)
+		}
+		if list, ok := value.([]interface{}); ok {
+			if len(list) > MaxAttributeList {
+				return fmt.Errorf("Attribute %s exceeds %d elements", key, MaxAttributeList)
+			}
+			for _, v := range list {
+				if err := validateScalar(key, v); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := validateScalar(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checks a scalar attribute value
+func validateScalar(key string, value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		if len([]rune(v)) > MaxAttributeString {
+			return fmt.Errorf("Attribute %s exceeds %d characters", key, MaxAttributeString)
+		}
+	case float64, bool:
+	default:
+		return fmt.Errorf("Unsupported value of attribute %s, use a string, number, boolean or list", key)
+	}
+	return nil
+}
+
+// NormalizeTags checks tags and returns them deduplicated and sorted (nil if empty)
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	var out []string
+	for _, tag := range tags {
+		if !tagPattern.MatchString(tag) || len([]rune(tag)) > MaxTagLength {
+			return nil, fmt.Errorf("Invalid tag %q, use up to %d characters without spaces and commas", tag, MaxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	if len(out) > MaxTags {
+		return nil, fmt.Errorf("Too many tags, at most %d are allowed", MaxTags)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// AddTags adds tags to an existing resource, the number of tags stays within the limit.
+// Versioned resources are changed by Update to record the tag set in the version history.
+func (r *Repo) AddTags(itemID string, tags []string) (*Item, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("Missing tags")
+	}
+	if r.VersionTableName != "" {
+		return r.modify(itemID, func(item *Item) (err error) {
+			item.Tags, err = NormalizeTags(append(item.Tags, tags...))
+			return
+		})
+	}
+
+	// existing tags may overlap, so the room is checked conservatively
+	condition := "attribute_exists(id) AND attribute_not_exists(deletedAt) AND (attribute_not_exists(#tags) OR size(#tags) <= :room)"
+	item, err := r.tag(itemID, "SET updatedAt = :updatedAt ADD #tags :tags, #version :one", condition, tags, map[string]*dynamodb.AttributeValue{
+		":room": {N: aws.String(strconv.Itoa(MaxTags - len(tags)))},
+	})
+	if err == ErrNotFound {
+		if _, err := r.Get(itemID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("Too many tags, at most %d are allowed", MaxTags)
+	}
+	return item, err
+}
+
+// RemoveTags removes tags from an existing resource, missing tags are ignored.
+// Versioned resources are changed by Update to record the tag set in the version history.
+func (r *Repo) RemoveTags(itemID string, tags []string) (*Item, error) {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("Missing tags")
+	}
+	if r.VersionTableName != "" {
+		return r.modify(itemID, func(item *Item) error {
+			removed := make(map[string]bool, len(tags))
+			for _, tag := range tags {
+				removed[tag] = true
+			}
+			var kept []string
+			for _, tag := range item.Tags {
+				if !removed[tag] {
+					kept = append(kept, tag)
+				}
+			}
+			item.Tags = kept
+			return nil
+		})
+	}
+	return r.tag(itemID, "SET updatedAt = :updatedAt ADD #version :one DELETE #tags :tags", "attribute_exists(id) AND attribute_not_exists(deletedAt)", tags, nil)
+}
+
+// Changes the tag set of an existing resource and increments its version
+func (r *Repo) tag(itemID, update, condition string, tags []string, values map[string]*dynamodb.AttributeValue) (*Item, error) {
+	if itemID == "" {
+		return nil, errors.New("Missing resource ID")
+	}
+	updatedAt, err := dynamodbattribute.Marshal(time.Now())
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	if values == nil {
+		values = map[string]*dynamodb.AttributeValue{}
+	}
+	values[":tags"] = &dynamodb.AttributeValue{SS: aws.StringSlice(tags)}
+	values[":updatedAt"] = updatedAt
+	values[":one"] = &dynamodb.AttributeValue{N: aws.String("1")}
+	names := map[string]*string{
+		"#tags":    aws.String("tags"),
+		"#version": aws.String("version"),
+	}
+
+	// execute query
+	return r.update(itemID, update, condition, names, values)
+}
@@ -0,0 +1,135 @@
+package sample
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItem_Validate(t *testing.T) {
+	tooMany := make(map[string]interface{}, MaxAttributes+1)
+	for i := 0; i <= MaxAttributes; i++ {
+		tooMany["a"+strings.Repeat("b", i)] = true
+	}
+
+	tests := []struct {
+		name     string
+		item     Item
+		wantTags []string
+		wantErr  bool
+	}{
+		{
+			name: "typed attributes",
+			item: Item{Attributes: map[string]interface{}{
+				"color": "blue", "weight": 1.5, "fragile": true, "sizes": []interface{}{"S", 2.0, false},
+			}},
+		},
+		{name: "tags deduplicated and sorted", item: Item{Tags: []string{"sale", "new", "sale"}}, wantTags: []string{"new", "sale"}},
+		{name: "invalid attribute name", item: Item{Attributes: map[string]interface{}{"color.name": "blue"}}, wantErr: true},
+		{name: "nested object", item: Item{Attributes: map[string]interface{}{"size": map[string]interface{}{"w": 1.0}}}, wantErr: true},
+		{name: "nested list", item: Item{Attributes: map[string]interface{}{"sizes": []interface{}{[]interface{}{"S"}}}}, wantErr: true},
+		{name: "null value", item: Item{Attributes: map[string]interface{}{"color": nil}}, wantErr: true},
+		{name: "long string", item: Item{Attributes: map[string]interface{}{"note": strings.Repeat("x", MaxAttributeString+1)}}, wantErr: true},
+		{name: "too many attributes", item: Item{Attributes: tooMany}, wantErr: true},
+		{name: "tag with comma", item: Item{Tags: []string{"a,b"}}, wantErr: true},
+		{name: "empty tag", item: Item{Tags: []string{""}}, wantErr: true},
+		{name: "long tag", item: Item{Tags: []string{strings.Repeat("x", MaxTagLength+1)}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.item.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantTags, tt.item.Tags, "Tags")
+			}
+		})
+	}
+}
+
+func TestItem_MarshalTags(t *testing.T) {
+	assert := assert.New(t)
+	item := Item{ID: "test-item-id", Tags: []string{"new", "sale"}, Attributes: map[string]interface{}{"weight": 1.5}}
+
+	av, err := dynamodbattribute.MarshalMap(item)
+	if assert.NoError(err) {
+		assert.Equal([]string{"new", "sale"}, aws.StringValueSlice(av["tags"].SS), "String set")
+		assert.Equal("1.5", aws.StringValue(av["attributes"].M["weight"].N), "Number attribute")
+	}
+	var got Item
+	if assert.NoError(dynamodbattribute.UnmarshalMap(av, &got)) {
+		assert.Equal(item.Tags, got.Tags, "Tags")
+		assert.Equal(item.Attributes, got.Attributes, "Attributes")
+	}
+
+	av, _ = dynamodbattribute.MarshalMap(Item{ID: "test-item-id"})
+	assert.NotContains(av, "tags", "Empty set is omitted")
+}
+
+func TestRepo_Tags(t *testing.T) {
+	assert := assert.New(t)
+	client := &mockDdbUpdates{mockDdb: mockDdb{item: &Item{ID: "test-item-id", Tags: []string{"new"}}}}
+	r := &Repo{Client: client, TableName: "mock-table"}
+
+	if _, err := r.AddTags("test-item-id", []string{"sale", "new", "sale"}); assert.NoError(err, "Add") {
+		assert.Equal("SET updatedAt = :updatedAt ADD #tags :tags, #version :one", *client.input.UpdateExpression)
+		assert.Equal([]string{"new", "sale"}, aws.StringValueSlice(client.input.ExpressionAttributeValues[":tags"].SS))
+		assert.Equal("48", *client.input.ExpressionAttributeValues[":room"].N, "Room for new tags")
+	}
+	if _, err := r.RemoveTags("test-item-id", []string{"sale"}); assert.NoError(err, "Remove") {
+		assert.Contains(*client.input.UpdateExpression, "DELETE #tags :tags")
+	}
+	_, err := r.AddTags("test-item-id", nil)
+	assert.Error(err, "Missing tags")
+	_, err = r.RemoveTags("test-item-id", []string{"a b"})
+	assert.Error(err, "Invalid tag")
+}
+
+func TestRepo_TagsVersioned(t *testing.T) {
+	client := newMockVersionedDdb()
+	r := &Repo{Client: client, TableName: "mock-table", VersionTableName: "mock-version-table"}
+
+	assert := assert.New(t)
+	item, err := r.Save(Item{Name: "test-item-name", Tags: []string{"new"}})
+	if !assert.NoError(err, "Save") {
+		return
+	}
+
+	if got, err := r.AddTags(item.ID, []string{"sale", "new"}); assert.NoError(err, "Add") {
+		assert.Equal([]string{"new", "sale"}, got.Tags)
+		assert.Equal(2, got.Version, "Next version")
+	}
+	if got, err := r.RemoveTags(item.ID, []string{"new", "missing"}); assert.NoError(err, "Remove") {
+		assert.Equal([]string{"sale"}, got.Tags)
+		assert.Equal(3, got.Version, "Next version")
+	}
+	assert.Len(client.versions[item.ID], 3, "Tag changes recorded in the version history")
+
+	many := make([]string, MaxTags)
+	for i := range many {
+		many[i] = strconv.Itoa(i)
+	}
+	_, err = r.AddTags(item.ID, many)
+	assert.Error(err, "Too many tags")
+	assert.Len(client.versions[item.ID], 3, "No version of a rejected change")
+}
+
+func TestItemField_Attributes(t *testing.T) {
+	assert := assert.New(t)
+
+	path, ok := ItemField("attributes.color")
+	assert.True(ok, "Custom attribute")
+	assert.Equal("attributes.color", path)
+	_, ok = ItemField("attributes.color-name")
+	assert.False(ok, "Invalid attribute name")
+
+	e, err := ParseFilter(`attributes.weight > 1 and tags contains "sale"`)
+	if assert.NoError(err) {
+		assert.True(e.Eval(flatten(&Item{Tags: []string{"sale"}, Attributes: map[string]interface{}{"weight": 1.5}})), "Evaluated in memory")
+	}
+}
@@ -0,0 +1,226 @@
+package sample
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+type (
+	// FieldChange is a difference of a single item field
+	FieldChange struct {
+		Field  string      `json:"field"`
+		Before interface{} `json:"before,omitempty"`
+		After  interface{} `json:"after,omitempty"`
+	}
+
+	// AuditEntry is an immutable record of an item mutation
+	AuditEntry struct {
+		ItemID    string        `json:"itemId"`
+		ID        string        `json:"id"` // timestamp and request ID
+		Action    Event         `json:"action"`
+		Actor     string        `json:"actor"`
+		RequestID string        `json:"requestId,omitempty"`
+		Timestamp time.Time     `json:"timestamp"`
+		Diff      []FieldChange `json:"diff,omitempty"`
+	}
+)
+
+// Diff returns changed fields between two item states (either may be nil).
+// Values of sensitive fields are redacted, only their change is recorded.
+func Diff(before, after *Item) []FieldChange {
+	prev, next := flatten(before), flatten(after)
+
+	fields := make(map[string]bool)
+	for field := range prev {
+		fields[field] = true
+	}
+	for field := range next {
+		fields[field] = true
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	var changes []FieldChange
+	for _, field := range names {
+		if reflect.DeepEqual(prev[field], next[field]) {
+			continue
+		}
+		change := FieldChange{Field: field, Before: prev[field], After: next[field]}
+		if encrypted(field) {
+			change.Before, change.After = redacted(change.Before), redacted(change.After)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Returns Redacted in place of a present value
+func redacted(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return Redacted
+}
+
+// Flattens JSON representation of the item into dotted field paths
+func flatten(item *Item) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if item == nil {
+		return fields
+	}
+	b, _ := json.Marshal(item)
+	var doc map[string]interface{}
+	_ = json.Unmarshal(b, &doc)
+
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		if m, ok := value.(map[string]interface{}); ok {
+			for k, v := range m {
+				if prefix == "" {
+					walk(k, v)
+				} else {
+					walk(prefix+"."+k, v)
+				}
+			}
+			return
+		}
+		fields[prefix] = value
+	}
+	walk("", doc)
+	return fields
+}
+
+// AuditLog provides DynamoDB client capabilities for the audit trail
+type AuditLog struct {
+	Client    dynamodbiface.DynamoDBAPI
+	TableName string
+	Retry     RetryPolicy // retries of throttled operations (single attempt if zero)
+	ctx       context.Context
+}
+
+// AuditTrail returns a configured DynamoDB client for the audit trail
+func AuditTrail(tableName string) *AuditLog {
+
+	// retries are controlled by the audit log policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &AuditLog{
+		Client:    dynamodb.New(sess),
+		TableName: tableName,
+		Retry:     DefaultRetryPolicy,
+	}
+}
+
+// WithContext returns a shallow copy of the audit log bound to the context (e.g. Lambda deadline)
+func (l *AuditLog) WithContext(ctx context.Context) *AuditLog {
+	audit := *l
+	audit.ctx = ctx
+	return &audit
+}
+
+// Returns the bound context or a background one
+func (l *AuditLog) context() context.Context {
+	if l.ctx != nil {
+		return l.ctx
+	}
+	return context.Background()
+}
+
+// Record an audit entry of the item mutation, existing entries are never overwritten
+func (l *AuditLog) Record(action Event, actor, requestID string, before, after *Item) (*AuditEntry, error) {
+	entry := AuditEntry{
+		Action:    action,
+		Actor:     actor,
+		RequestID: requestID,
+		Timestamp: time.Now().UTC(),
+		Diff:      Diff(before, after),
+	}
+	if after != nil {
+		entry.ItemID = after.ID
+	} else if before != nil {
+		entry.ItemID = before.ID
+	}
+	if entry.ItemID == "" {
+		return nil, errors.New("Missing resource ID")
+	}
+	// sortable by time, unique per request
+	entry.ID = entry.Timestamp.Format(time.RFC3339Nano) + "#" + requestID
+
+	// prepare query data
+	av, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	input := &dynamodb.PutItemInput{
+		Item:                av,
+		TableName:           &l.TableName,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	}
+
+	// execute query
+	err = l.Retry.Do(l.context(), func() (err error) {
+		_, err = l.Client.PutItem(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to record audit entry")
+	}
+	return &entry, nil
+}
+
+// List a page of audit entries of the item in chronological order
+func (l *AuditLog) List(itemID string, page Page) ([]AuditEntry, string, error) {
+	if itemID == "" {
+		return nil, "", errors.New("Missing resource ID")
+	}
+	startKey, err := page.startKey(keyAttr{name: "itemId", value: itemID}, keyAttr{name: "id"})
+	if err != nil {
+		return nil, "", err
+	}
+
+	// prepare query data
+	input := &dynamodb.QueryInput{
+		TableName:              &l.TableName,
+		KeyConditionExpression: aws.String("itemId = :itemId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":itemId": {S: aws.String(itemID)},
+		},
+		Limit:             page.limit(),
+		ExclusiveStartKey: startKey,
+	}
+
+	// execute query
+	var res *dynamodb.QueryOutput
+	err = l.Retry.Do(l.context(), func() (err error) {
+		res, err = l.Client.Query(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, "", errors.New("Failed to read audit entries")
+	}
+
+	// process query results
+	var entries []AuditEntry
+	if err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &entries); err != nil {
+		log.Println("Failed to unmarshal:", err.Error())
+		return nil, "", err
+	}
+	return entries, nextPageToken(res.LastEvaluatedKey), nil
+}
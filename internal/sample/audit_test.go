@@ -0,0 +1,129 @@
+package sample
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client querying audit entries
+type mockDdbAudit struct {
+	mockDdbRecorder
+	entries []AuditEntry
+	input   *dynamodb.QueryInput
+}
+
+func (mock *mockDdbAudit) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	mock.input = input
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	output := new(dynamodb.QueryOutput)
+	for _, entry := range mock.entries {
+		av, _ := dynamodbattribute.MarshalMap(entry)
+		output.Items = append(output.Items, av)
+	}
+	output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"itemId": {S: aws.String("test-item-id")}, "id": {S: aws.String("2")}}
+	return output, nil
+}
+
+func TestDiff(t *testing.T) {
+	before := &Item{ID: "test-item-id", Name: "old", Details: Details{Location: "A1", Quantity: 5}}
+	after := &Item{ID: "test-item-id", Name: "new", Details: Details{Location: "A1", Quantity: 3}}
+
+	assert := assert.New(t)
+	assert.Equal([]FieldChange{
+		{Field: "details.quantity", Before: float64(5), After: float64(3)},
+		{Field: "name", Before: "old", After: "new"},
+	}, Diff(before, after), "Update")
+	assert.Empty(Diff(before, before), "No changes")
+	assert.Len(Diff(nil, after), 4, "Create")
+	assert.Len(Diff(before, nil), 4, "Delete")
+}
+
+func TestAuditLog_Record(t *testing.T) {
+	item := &Item{ID: "test-item-id", Name: "test-item-name"}
+	tests := []struct {
+		name    string
+		client  *mockDdbRecorder
+		before  *Item
+		after   *Item
+		wantErr bool
+	}{
+		{name: "create", client: &mockDdbRecorder{}, after: item},
+		{name: "delete", client: &mockDdbRecorder{}, before: item},
+		{name: "missing item", client: &mockDdbRecorder{}, wantErr: true},
+		{
+			name:    "failed operation",
+			client:  &mockDdbRecorder{mockDdb: mockDdb{err: errors.New("Mock DynamoDB error")}},
+			after:   item,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &AuditLog{Client: tt.client, TableName: "mock-table"}
+			got, err := l.Record(ItemCreated, "test-actor", "test-request-id", tt.before, tt.after)
+			assert := assert.New(t)
+			if tt.wantErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal("test-item-id", got.ItemID, "ItemID")
+				assert.Equal("test-actor", got.Actor, "Actor")
+				assert.Contains(got.ID, "#test-request-id", "ID")
+				assert.NotEmpty(got.Diff, "Diff")
+				assert.Len(tt.client.puts, 1, "Recorded entries")
+			}
+		})
+	}
+}
+
+func TestAuditLog_RecordRedacted(t *testing.T) {
+	before := &Item{ID: "test-item-id", Details: Details{Location: "secret-before"}}
+	after := &Item{ID: "test-item-id", Details: Details{Location: "secret-after"}}
+	client := &mockDdbRecorder{}
+	l := &AuditLog{Client: client, TableName: "mock-table"}
+
+	assert := assert.New(t)
+	got, err := l.Record(ItemUpdated, "test-actor", "test-request-id", before, after)
+	if assert.NoError(err) && assert.Len(client.puts, 1) {
+		assert.Equal([]FieldChange{{Field: "details.location", Before: Redacted, After: Redacted}}, got.Diff, "Change recorded")
+		b, _ := json.Marshal(client.puts[0])
+		assert.NotContains(string(b), "secret", "No plaintext location stored")
+	}
+}
+
+func TestAuditLog_List(t *testing.T) {
+	client := &mockDdbAudit{entries: []AuditEntry{{ItemID: "test-item-id", ID: "1"}, {ItemID: "test-item-id", ID: "2"}}}
+	l := &AuditLog{Client: client, TableName: "mock-table"}
+
+	assert := assert.New(t)
+	entries, next, err := l.List("test-item-id", Page{Limit: 2})
+	if assert.NoError(err) {
+		assert.Len(entries, 2, "Entries")
+		assert.NotEmpty(next, "Next page")
+		assert.EqualValues(2, *client.input.Limit, "Limit")
+	}
+
+	_, _, err = l.List("test-item-id", Page{Next: next})
+	if assert.NoError(err) {
+		assert.NotNil(client.input.ExclusiveStartKey, "Start key")
+	}
+
+	_, _, err = l.List("test-item-id", Page{Next: "malformed"})
+	assert.Error(err, "Invalid token")
+	_, _, err = l.List("other-item-id", Page{Next: next})
+	assert.Equal(ErrInvalidPageToken, err, "Token of another item")
+	_, _, err = l.List("", Page{})
+	assert.Error(err, "Missing ID")
+
+	client.err = errors.New("Mock DynamoDB error")
+	_, _, err = l.List("test-item-id", Page{})
+	assert.Error(err, "Failed operation")
+}
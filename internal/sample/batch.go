@@ -0,0 +1,339 @@
+package sample
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Request limits of DynamoDB batch operations
+const (
+	maxBatchWrite = 25
+	maxBatchGet   = 100
+)
+
+// Batch errors
+var (
+	ErrDuplicateID = errors.New("Duplicate resource ID in the batch")
+	ErrUnprocessed = errors.New("Resource was not processed, retry later")
+)
+
+// ItemResult is an outcome of a batch operation on a single item
+type ItemResult struct {
+	ID   string
+	Item *Item // state after the operation (nil on failure)
+	Err  error // ErrNotFound or a failure of the item
+}
+
+// A write request of an item (owner) of the batch
+type batchWrite struct {
+	owner   int
+	table   string
+	request *dynamodb.WriteRequest
+}
+
+// A put of a versioned item (owner) of the batch and its version record
+type versionedWrite struct {
+	owner       int
+	av, version map[string]*dynamodb.AttributeValue
+}
+
+// BatchSave saves items as new database resources, results are in the order of items.
+// Items are written by BatchWriteItem, so existing resources are overwritten unlike by Save.
+// A versioned item and its version record are written together by a transaction per item,
+// as a batch request may process one without the other.
+func (r *Repo) BatchSave(items []Item) []ItemResult {
+	results := make([]ItemResult, len(items))
+	seen := make(map[string]bool, len(items))
+	var writes []batchWrite
+	var versioned []versionedWrite
+	now := time.Now()
+
+	for i, item := range items {
+		item = created(item, now)
+		results[i].ID = item.ID
+		if seen[item.ID] {
+			results[i].Err = ErrDuplicateID
+			continue
+		}
+		seen[item.ID] = true
+
+		av, version, err := r.marshal(item)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Item = &item
+		if version != nil {
+			versioned = append(versioned, versionedWrite{i, av, version})
+			continue
+		}
+		writes = append(writes, batchWrite{i, r.TableName, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: av}}})
+	}
+
+	r.batchWrite(writes, results)
+	r.transactWrite(versioned, results)
+	return results
+}
+
+// BatchGet gets existing resources by IDs, results are in the order of IDs.
+// Soft deleted and expired items are not found.
+func (r *Repo) BatchGet(itemIDs []string) []ItemResult {
+	now := time.Now()
+	results := make([]ItemResult, len(itemIDs))
+	owners := make(map[string][]int, len(itemIDs))
+	var keys []map[string]*dynamodb.AttributeValue
+
+	for i, id := range itemIDs {
+		results[i].ID = id
+		if id == "" {
+			results[i].Err = errors.New("Missing resource ID")
+			continue
+		}
+		if _, ok := owners[id]; !ok {
+			keys = append(keys, map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}})
+		}
+		owners[id] = append(owners[id], i)
+	}
+
+	for start := 0; start < len(keys); start += maxBatchGet {
+		end := start + maxBatchGet
+		if end > len(keys) {
+			end = len(keys)
+		}
+		pending := map[string]*dynamodb.KeysAndAttributes{r.TableName: {Keys: keys[start:end]}}
+
+		// execute query, unprocessed keys are retried with backoff
+		var found []map[string]*dynamodb.AttributeValue
+		err := r.Retry.Do(r.context(), func() error {
+			res, err := r.Client.BatchGetItem(&dynamodb.BatchGetItemInput{RequestItems: pending})
+			if err != nil {
+				return err
+			}
+			found = append(found, res.Responses[r.TableName]...)
+			if len(res.UnprocessedKeys) > 0 {
+				pending = res.UnprocessedKeys
+				return errRetry
+			}
+			pending = nil
+			return nil
+		})
+
+		// process query results
+		for _, av := range found {
+			var item Item
+			err := r.upgrade(av)
+			if err == nil {
+				if err = dynamodbattribute.UnmarshalMap(av, &item); err != nil {
+					log.Println("Failed to unmarshal:", err.Error())
+					err = errors.New("Failed to read item from the repository")
+				}
+			}
+			if err != nil { // the item exists, so it's a read failure rather than not found
+				if id, ok := av["id"]; ok {
+					for _, i := range owners[aws.StringValue(id.S)] {
+						results[i].Err = err
+					}
+				}
+				continue
+			}
+			if item.DeletedAt != nil || item.Expired(now) {
+				continue
+			}
+			err = r.rehydrate(&item)
+			for _, i := range owners[item.ID] {
+				if err != nil {
+					results[i].Err = err
+					continue
+				}
+				item := item
+				results[i].Item = &item
+			}
+		}
+		for _, key := range keys[start:end] {
+			for _, i := range owners[*key["id"].S] {
+				if results[i].Item != nil || results[i].Err != nil {
+					continue
+				}
+				switch {
+				case err == nil || errors.Is(err, errRetry) && !r.unprocessed(pending, key):
+					results[i].Err = ErrNotFound
+				case errors.Is(err, errRetry):
+					results[i].Err = ErrUnprocessed
+				default:
+					log.Println(err.Error())
+					results[i].Err = errors.New("Failed to read item from the repository")
+				}
+			}
+		}
+	}
+	return results
+}
+
+// Reports whether the key is still pending in unprocessed keys
+func (r *Repo) unprocessed(pending map[string]*dynamodb.KeysAndAttributes, key map[string]*dynamodb.AttributeValue) bool {
+	if pending[r.TableName] == nil {
+		return false
+	}
+	for _, k := range pending[r.TableName].Keys {
+		if aws.StringValue(k["id"].S) == aws.StringValue(key["id"].S) {
+			return true
+		}
+	}
+	return false
+}
+
+// BatchDelete soft deletes existing resources by IDs, results are in the order of IDs.
+// Every item is deleted by a conditional update like by Delete, so concurrent updates are kept.
+func (r *Repo) BatchDelete(itemIDs []string) []ItemResult {
+	results := make([]ItemResult, len(itemIDs))
+	seen := make(map[string]bool, len(itemIDs))
+	sem := make(chan struct{}, maxBatchWrite)
+	var wg sync.WaitGroup
+
+	for i, id := range itemIDs {
+		results[i].ID = id
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(result *ItemResult) {
+			defer wg.Done()
+			result.Item, result.Err = r.Delete(result.ID)
+			<-sem
+		}(&results[i])
+	}
+	wg.Wait()
+
+	// repeated IDs share the outcome of the first one
+	first := make(map[string]int, len(results))
+	for i := range results {
+		if j, ok := first[results[i].ID]; ok {
+			results[i].Item, results[i].Err = results[j].Item, results[j].Err
+		} else {
+			first[results[i].ID] = i
+		}
+	}
+	return results
+}
+
+// Executes write requests in batches, unprocessed requests are retried with backoff.
+// Consecutive requests of an owner are kept in the same batch.
+// Failures are recorded in the results of request owners.
+func (r *Repo) batchWrite(writes []batchWrite, results []ItemResult) {
+	for start, end := 0, 0; start < len(writes); start = end {
+		for end < len(writes) {
+			next := end + 1
+			for next < len(writes) && writes[next].owner == writes[end].owner {
+				next++
+			}
+			if next-start > maxBatchWrite && end > start {
+				break
+			}
+			end = next
+		}
+		chunk := writes[start:end]
+		pending := make(map[string][]*dynamodb.WriteRequest)
+		for _, w := range chunk {
+			pending[w.table] = append(pending[w.table], w.request)
+		}
+
+		// execute query
+		err := r.Retry.Do(r.context(), func() error {
+			res, err := r.Client.BatchWriteItem(&dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return err
+			}
+			if len(res.UnprocessedItems) > 0 {
+				pending = res.UnprocessedItems
+				return errRetry
+			}
+			pending = nil
+			return nil
+		})
+		if err == nil {
+			continue
+		}
+
+		// fail owners of requests which were not processed
+		failed := ErrUnprocessed
+		if !errors.Is(err, errRetry) {
+			log.Println(err.Error())
+			failed = errors.New("Failed to save into the repository")
+		}
+		unprocessed := make(map[string]bool)
+		for table, requests := range pending {
+			for _, req := range requests {
+				unprocessed[table+"/"+writeKey(req)] = true
+			}
+		}
+		for _, w := range chunk {
+			if unprocessed[w.table+"/"+writeKey(w.request)] {
+				results[w.owner].Item, results[w.owner].Err = nil, failed
+				if failed == ErrUnprocessed && w.table == r.TableName {
+					r.discard(w.request.PutRequest.Item)
+				}
+			}
+		}
+	}
+}
+
+// Executes puts of versioned items and their version records by a transaction per item,
+// up to maxBatchWrite transactions run concurrently.
+// Failures are recorded in the results of item owners.
+func (r *Repo) transactWrite(writes []versionedWrite, results []ItemResult) {
+	sem := make(chan struct{}, maxBatchWrite)
+	var wg sync.WaitGroup
+
+	for _, w := range writes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(w versionedWrite, result *ItemResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// execute transaction
+			err := r.Transact().
+				Put(r.TableName, w.av, Expr{}).
+				Put(r.VersionTableName, w.version, Expr{}).
+				Commit()
+			if err == nil {
+				return
+			}
+
+			// transient failures left after retries fail the item as unprocessed
+			result.Item = nil
+			var txErr *TransactionError
+			if errors.As(err, &txErr) && txErr.transient() || errors.Is(err, errRetry) {
+				result.Err = ErrUnprocessed
+				r.discard(w.av)
+				return
+			}
+			log.Println(err.Error())
+			result.Err = errors.New("Failed to save into the repository")
+		}(w, &results[w.owner])
+	}
+	wg.Wait()
+}
+
+// Returns the item ID of a put request of the items or versions table
+func writeKey(req *dynamodb.WriteRequest) string {
+	if req.PutRequest == nil {
+		return ""
+	}
+	if id := req.PutRequest.Item["id"]; id != nil {
+		return aws.StringValue(id.S)
+	}
+	if id := req.PutRequest.Item["itemId"]; id != nil {
+		return aws.StringValue(id.S)
+	}
+	return ""
+}
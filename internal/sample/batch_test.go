@@ -0,0 +1,235 @@
+package sample
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client keeping tables in memory and leaving the last request
+// of every batch unprocessed a number of times
+type mockDdbBatch struct {
+	dynamodbiface.DynamoDBAPI
+	tables       map[string]map[string]map[string]*dynamodb.AttributeValue
+	unprocessed  int
+	writes       []int // number of requests per batch write call
+	transactions int   // number of committed transactions
+	err          error
+	mu           sync.Mutex
+}
+
+func newMockDdbBatch(unprocessed int) *mockDdbBatch {
+	return &mockDdbBatch{tables: map[string]map[string]map[string]*dynamodb.AttributeValue{}, unprocessed: unprocessed}
+}
+
+func (mock *mockDdbBatch) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	output := &dynamodb.BatchWriteItemOutput{}
+	count := 0
+	for table, requests := range input.RequestItems {
+		count += len(requests)
+		if mock.tables[table] == nil {
+			mock.tables[table] = map[string]map[string]*dynamodb.AttributeValue{}
+		}
+		for i, req := range requests {
+			if mock.unprocessed > 0 && i == len(requests)-1 {
+				mock.unprocessed--
+				output.UnprocessedItems = map[string][]*dynamodb.WriteRequest{table: {req}}
+				continue
+			}
+			mock.tables[table][writeKey(req)] = req.PutRequest.Item
+		}
+	}
+	mock.writes = append(mock.writes, count)
+	return output, nil
+}
+
+// Writes all puts of the transaction, conflicts with other transactions take the place of unprocessed requests
+func (mock *mockDdbBatch) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	if mock.unprocessed > 0 {
+		mock.unprocessed--
+		return nil, mockCanceled(ReasonConflict, ReasonNone)
+	}
+	for _, op := range input.TransactItems {
+		table := *op.Put.TableName
+		if mock.tables[table] == nil {
+			mock.tables[table] = map[string]map[string]*dynamodb.AttributeValue{}
+		}
+		mock.tables[table][writeKey(&dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: op.Put.Item}})] = op.Put.Item
+	}
+	mock.transactions++
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (mock *mockDdbBatch) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	output := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+	for table, keys := range input.RequestItems {
+		for i, key := range keys.Keys {
+			if mock.unprocessed > 0 && i == len(keys.Keys)-1 {
+				mock.unprocessed--
+				output.UnprocessedKeys = map[string]*dynamodb.KeysAndAttributes{table: {Keys: []map[string]*dynamodb.AttributeValue{key}}}
+				continue
+			}
+			if item, ok := mock.tables[table][*key["id"].S]; ok {
+				output.Responses[table] = append(output.Responses[table], item)
+			}
+		}
+	}
+	return output, nil
+}
+
+// Soft deletes a stored item unless it is missing or deleted already
+func (mock *mockDdbBatch) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	item, ok := mock.tables[*input.TableName][*input.Key["id"].S]
+	if !ok || item["deletedAt"] != nil {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "Mock condition failure", nil)
+	}
+	item["deletedAt"] = input.ExpressionAttributeValues[":deletedAt"]
+	if ttl, ok := input.ExpressionAttributeValues[":ttl"]; ok {
+		item["ttl"] = ttl
+	}
+	return &dynamodb.UpdateItemOutput{Attributes: item}, nil
+}
+
+// Returns a retry policy with the mock clock
+func mockRetry(attempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: attempts, BaseDelay: 10 * time.Millisecond, Clock: &mockClock{now: time.Now()}}
+}
+
+func TestRepo_BatchSave(t *testing.T) {
+	items := make([]Item, 30)
+	for i := range items {
+		items[i].Name = "test-item-name"
+	}
+
+	assert := assert.New(t)
+	client := newMockDdbBatch(1)
+	r := &Repo{Client: client, TableName: "mock-table", VersionTableName: "mock-version-table", Retry: mockRetry(3)}
+	results := r.BatchSave(items)
+	if assert.Len(results, 30) {
+		for _, result := range results {
+			assert.NoError(result.Err)
+			assert.NotEmpty(result.ID, "Generated ID")
+			assert.Equal(1, result.Item.Version, "Initial version")
+		}
+	}
+	assert.Len(client.tables["mock-table"], 30, "Items written")
+	assert.Len(client.tables["mock-version-table"], 30, "Versions written")
+	assert.Equal(30, client.transactions, "Item and its version written by a transaction")
+	assert.Empty(client.writes, "No batch writes of versioned items")
+
+	client = newMockDdbBatch(1)
+	r.Client = client
+	results = r.BatchSave(items[:1])
+	assert.NoError(results[0].Err, "Retried conflict")
+	assert.Equal(1, client.transactions, "Retried conflict")
+	client.unprocessed = 3
+	r.Retry = mockRetry(2)
+	results = r.BatchSave(items[:1])
+	assert.Equal(ErrUnprocessed, results[0].Err, "Conflict left after retries")
+	assert.Nil(results[0].Item, "Conflict left after retries")
+
+	client = newMockDdbBatch(1)
+	r = &Repo{Client: client, TableName: "mock-table", Retry: mockRetry(3)}
+	r.BatchSave(items)
+	assert.Equal([]int{25, 1, 5}, client.writes, "Batches of up to 25 requests with a retry of unprocessed one")
+
+	// unprocessed requests fail their items when retries are exhausted
+	client = newMockDdbBatch(5)
+	r = &Repo{Client: client, TableName: "mock-table", Retry: mockRetry(2)}
+	results = r.BatchSave([]Item{{ID: "a"}, {ID: "b"}, {ID: "a"}})
+	assert.NoError(results[0].Err, "Processed")
+	assert.Equal(ErrUnprocessed, results[1].Err, "Unprocessed")
+	assert.Nil(results[1].Item, "Unprocessed")
+	assert.Equal(ErrDuplicateID, results[2].Err, "Duplicate")
+
+	client.err = errors.New("Mock DynamoDB error")
+	results = r.BatchSave([]Item{{ID: "a"}})
+	assert.Error(results[0].Err, "Failed operation")
+	assert.Nil(results[0].Item, "Failed operation")
+}
+
+func TestRepo_BatchGetAndDelete(t *testing.T) {
+	deletedAt := time.Now()
+	client := newMockDdbBatch(0)
+	client.tables["mock-table"] = map[string]map[string]*dynamodb.AttributeValue{}
+	for _, item := range []Item{{ID: "a", Name: "first"}, {ID: "b", Name: "second"}, {ID: "c", DeletedAt: &deletedAt}} {
+		av, _ := dynamodbattribute.MarshalMap(item)
+		client.tables["mock-table"][item.ID] = av
+	}
+	r := &Repo{Client: client, TableName: "mock-table", Retry: mockRetry(3), Retention: time.Hour}
+
+	assert := assert.New(t)
+	client.unprocessed = 1
+	results := r.BatchGet([]string{"a", "b", "c", "missing", "a", ""})
+	if assert.Len(results, 6) {
+		assert.Equal("first", results[0].Item.Name)
+		assert.Equal("second", results[1].Item.Name, "Unprocessed key retried")
+		assert.Equal(ErrNotFound, results[2].Err, "Soft deleted")
+		assert.Equal(ErrNotFound, results[3].Err, "Missing")
+		assert.Equal("first", results[4].Item.Name, "Repeated ID")
+		assert.Error(results[5].Err, "Missing ID")
+	}
+
+	results = r.BatchDelete([]string{"a", "missing", "a", "c"})
+	if assert.Len(results, 4) {
+		assert.NotNil(results[0].Item.DeletedAt, "Tombstone")
+		assert.NotZero(results[0].Item.TTL, "Purge scheduled")
+		assert.Equal(ErrNotFound, results[1].Err, "Missing")
+		assert.Equal(results[0].Item, results[2].Item, "Repeated ID")
+		assert.Equal(ErrNotFound, results[3].Err, "Deleted already")
+	}
+	assert.NotNil(client.tables["mock-table"]["a"]["deletedAt"], "Tombstone written")
+	assert.NotNil(client.tables["mock-table"]["a"]["ttl"], "TTL written")
+
+	failing := *r
+	failing.Migrations = Migrations{{Version: 2, Up: func(av map[string]*dynamodb.AttributeValue) error {
+		return errors.New("Mock migration error")
+	}}}
+	client.tables["mock-table"]["legacy"] = legacyItem("legacy", "legacy", "")
+	results = failing.BatchGet([]string{"legacy", "legacy"})
+	for _, result := range results {
+		assert.Error(result.Err, "Failed migration")
+		assert.NotEqual(ErrNotFound, result.Err, "Failed migration")
+	}
+
+	malformed, _ := dynamodbattribute.MarshalMap(Item{ID: "malformed"})
+	malformed["details"] = &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{"quantity": {S: aws.String("many")}}}
+	client.tables["mock-table"]["malformed"] = malformed
+	results = r.BatchGet([]string{"malformed"})
+	assert.Error(results[0].Err, "Failed unmarshal")
+	assert.NotEqual(ErrNotFound, results[0].Err, "Failed unmarshal")
+
+	client.unprocessed = 5
+	r.Retry = mockRetry(1)
+	results = r.BatchGet([]string{"a", "b"})
+	assert.Equal(ErrUnprocessed, results[1].Err, "Unprocessed key")
+
+	client.err = errors.New("Mock DynamoDB error")
+	results = r.BatchGet([]string{"b"})
+	assert.Error(results[0].Err, "Failed operation")
+	assert.NotEqual(ErrNotFound, results[0].Err, "Failed operation")
+}
@@ -0,0 +1,274 @@
+package sample
+
+import (
+	"container/list"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// Default expiry of cached items
+const (
+	DefaultCacheTTL    = 30 * time.Second
+	DefaultNegativeTTL = 5 * time.Second // items not found, so a created item becomes visible soon
+)
+
+// ItemStore reads and writes items by ID
+type ItemStore interface {
+	Get(itemID string) (*Item, error)
+	Lookup(itemID string, includeDeleted bool) (*Item, error)
+	Save(item Item) (*Item, error)
+	Update(item Item) (*Item, error)
+	Delete(itemID string) (*Item, error)
+	Undelete(itemID string) (*Item, error)
+}
+
+// RemoteCache is a cache shared by function instances (e.g. Redis), values are removed after their TTL
+type RemoteCache interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+// LRU is an in-process cache of items evicting the least recently used ones, safe for concurrent use.
+// A package level cache survives across warm invocations of the function.
+type LRU struct {
+	capacity int
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List // most recently used first
+}
+
+// Cached item, a nil item caches its absence
+type cacheEntry struct {
+	key     string
+	item    *Item
+	expires time.Time
+}
+
+// NewLRU returns an empty cache of up to capacity items
+func NewLRU(capacity int) *LRU {
+	return &LRU{capacity: capacity, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// Returns the cached entry unless expired
+func (c *LRU) get(key string, now time.Time) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !now.Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+// Caches the item (absence if nil) until the expiry
+func (c *LRU) set(key string, item *Item, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = &cacheEntry{key: key, item: item, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, item: item, expires: expires})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Remove the cached entry
+func (c *LRU) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns the number of cached entries including expired ones
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Item encoded in the remote cache, a nil item caches its absence
+type remoteEntry struct {
+	Item *Item `json:"item"`
+}
+
+// CachedStore is a read-through cache in front of an item store.
+// Items are cached by Get only, writes through the store evict the written item.
+// Writes of other writers (e.g. other function instances) are served after the TTL.
+type CachedStore struct {
+	Store       ItemStore
+	Local       *LRU          // in-process cache (not used if nil)
+	Remote      RemoteCache   // shared cache (not used if nil)
+	TTL         time.Duration // expiry of found items (DefaultCacheTTL if zero)
+	NegativeTTL time.Duration // expiry of items not found (DefaultNegativeTTL if zero)
+	Clock       Clock         // time source (system clock if nil)
+}
+
+// Returns the current time of the clock
+func (s *CachedStore) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock.Now()
+	}
+	return time.Now()
+}
+
+// Returns the expiry of a cache entry
+func (s *CachedStore) expiry(item *Item, now time.Time) time.Duration {
+	if item == nil {
+		if s.NegativeTTL > 0 {
+			return s.NegativeTTL
+		}
+		return DefaultNegativeTTL
+	}
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if item.ExpiresAt != nil && item.ExpiresAt.Sub(now) < ttl { // expired items are never served
+		ttl = item.ExpiresAt.Sub(now)
+	}
+	return ttl
+}
+
+// Returns a cache key of the item
+func cacheKey(itemID string) string {
+	return "item:" + itemID
+}
+
+// Get an existing resource by ID from the cache or the store, absence of the resource is cached too
+func (s *CachedStore) Get(itemID string) (*Item, error) {
+	key, now := cacheKey(itemID), s.now()
+	if s.Local != nil {
+		if entry, ok := s.Local.get(key, now); ok {
+			return found(entry.item)
+		}
+	}
+	if s.Remote != nil {
+		if entry, ok := s.remote(key); ok {
+			s.cacheLocal(key, entry.Item, now)
+			return found(entry.Item)
+		}
+	}
+
+	item, err := s.Store.Get(itemID)
+	switch err {
+	case nil:
+	case ErrNotFound:
+		item = nil
+	default: // failures are not cached
+		return nil, err
+	}
+	s.cacheLocal(key, item, now)
+	if s.Remote != nil {
+		b, _ := json.Marshal(remoteEntry{Item: item})
+		if err := s.Remote.Set(key, b, s.expiry(item, now)); err != nil {
+			log.Println("Failed to cache:", err.Error())
+		}
+	}
+	return found(item)
+}
+
+// Returns a copy of the cached item or an error of the cached absence
+func found(item *Item) (*Item, error) {
+	if item == nil {
+		return nil, ErrNotFound
+	}
+	out := *item
+	return &out, nil
+}
+
+// Caches the item in the process
+func (s *CachedStore) cacheLocal(key string, item *Item, now time.Time) {
+	if s.Local == nil {
+		return
+	}
+	if item != nil { // callers may modify returned items
+		cached := *item
+		item = &cached
+	}
+	if ttl := s.expiry(item, now); ttl > 0 {
+		s.Local.set(key, item, now.Add(ttl))
+	}
+}
+
+// Returns the entry of the remote cache, failures are handled as misses
+func (s *CachedStore) remote(key string) (*remoteEntry, bool) {
+	b, ok, err := s.Remote.Get(key)
+	if err != nil {
+		log.Println("Failed to read cache:", err.Error())
+		return nil, false
+	} else if !ok {
+		return nil, false
+	}
+	var entry remoteEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		log.Println("Failed to decode cache:", err.Error())
+		return nil, false
+	}
+	return &entry, true
+}
+
+// Lookup an existing resource by ID, soft deleted items are never cached
+func (s *CachedStore) Lookup(itemID string, includeDeleted bool) (*Item, error) {
+	if includeDeleted {
+		return s.Store.Lookup(itemID, true)
+	}
+	return s.Get(itemID)
+}
+
+// Save a new resource and evict its cached absence
+func (s *CachedStore) Save(item Item) (*Item, error) {
+	out, err := s.Store.Save(item)
+	if err == nil {
+		s.Invalidate(out.ID)
+	}
+	return out, err
+}
+
+// Update an existing resource and evict it from the cache
+func (s *CachedStore) Update(item Item) (*Item, error) {
+	defer s.Invalidate(item.ID)
+	return s.Store.Update(item)
+}
+
+// Delete an existing resource and evict it from the cache
+func (s *CachedStore) Delete(itemID string) (*Item, error) {
+	defer s.Invalidate(itemID)
+	return s.Store.Delete(itemID)
+}
+
+// Undelete a soft deleted resource and evict its cached absence
+func (s *CachedStore) Undelete(itemID string) (*Item, error) {
+	defer s.Invalidate(itemID)
+	return s.Store.Undelete(itemID)
+}
+
+// Invalidate evicts the item from the caches, e.g. on its change by another writer
+func (s *CachedStore) Invalidate(itemID string) {
+	key := cacheKey(itemID)
+	if s.Local != nil {
+		s.Local.Remove(key)
+	}
+	if s.Remote != nil {
+		if err := s.Remote.Delete(key); err != nil {
+			log.Println("Failed to invalidate cache:", err.Error())
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package sample
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ ItemStore = (*Repo)(nil)
+
+// Mock item store counting reads
+type mockStore struct {
+	ItemStore
+	items map[string]Item
+	reads int
+	err   error
+}
+
+func (mock *mockStore) Get(itemID string) (*Item, error) {
+	mock.reads++
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	item, ok := mock.items[itemID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &item, nil
+}
+
+func (mock *mockStore) Update(item Item) (*Item, error) {
+	mock.items[item.ID] = item
+	return &item, nil
+}
+
+// Mock remote cache keeping values in memory
+type mockRemoteCache struct {
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	err    error
+}
+
+func (mock *mockRemoteCache) Get(key string) ([]byte, bool, error) {
+	b, ok := mock.values[key]
+	return b, ok, mock.err
+}
+
+func (mock *mockRemoteCache) Set(key string, value []byte, ttl time.Duration) error {
+	mock.values[key], mock.ttls[key] = value, ttl
+	return mock.err
+}
+
+func (mock *mockRemoteCache) Delete(key string) error {
+	delete(mock.values, key)
+	return mock.err
+}
+
+func TestCachedStore_Get(t *testing.T) {
+	clock := &mockClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := &mockStore{items: map[string]Item{"a": {ID: "a", Name: "cached"}}}
+	cached := &CachedStore{Store: store, Local: NewLRU(10), TTL: time.Minute, NegativeTTL: 10 * time.Second, Clock: clock}
+
+	assert := assert.New(t)
+	for i := 0; i < 3; i++ {
+		got, err := cached.Get("a")
+		if assert.NoError(err) {
+			assert.Equal("cached", got.Name)
+			got.Name = "modified by caller"
+		}
+	}
+	assert.Equal(1, store.reads, "Read through once")
+
+	_, err := cached.Get("missing")
+	assert.Equal(ErrNotFound, err)
+	_, err = cached.Lookup("missing", false)
+	assert.Equal(ErrNotFound, err)
+	assert.Equal(2, store.reads, "Negative caching")
+
+	clock.now = clock.now.Add(30 * time.Second)
+	_, _ = cached.Get("missing")
+	_, _ = cached.Get("a")
+	assert.Equal(3, store.reads, "Absence expired before the item")
+
+	clock.now = clock.now.Add(time.Minute)
+	_, _ = cached.Get("a")
+	assert.Equal(4, store.reads, "Item expired")
+
+	if _, err := cached.Update(Item{ID: "a", Name: "updated"}); assert.NoError(err) {
+		got, _ := cached.Get("a")
+		assert.Equal("updated", got.Name, "Invalidated on update")
+	}
+
+	store.err = errors.New("Mock store error")
+	_, err = cached.Get("b")
+	assert.Error(err, "Failed read")
+	store.err = nil
+	_, err = cached.Get("b")
+	assert.Equal(ErrNotFound, err, "Failures are not cached")
+}
+
+func TestCachedStore_Expiry(t *testing.T) {
+	clock := &mockClock{now: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}
+	expiresAt := clock.now.Add(5 * time.Second)
+	store := &mockStore{items: map[string]Item{"a": {ID: "a", ExpiresAt: &expiresAt}}}
+	cached := &CachedStore{Store: store, Local: NewLRU(10), Clock: clock}
+
+	_, _ = cached.Get("a")
+	clock.now = expiresAt
+	_, _ = cached.Get("a")
+	assert.Equal(t, 2, store.reads, "Cached until the item expiry")
+}
+
+func TestCachedStore_Remote(t *testing.T) {
+	store := &mockStore{items: map[string]Item{"a": {ID: "a", Name: "shared"}}}
+	remote := &mockRemoteCache{values: map[string][]byte{}, ttls: map[string]time.Duration{}}
+	cached := &CachedStore{Store: store, Remote: remote}
+
+	assert := assert.New(t)
+	_, _ = cached.Get("a")
+	_, _ = cached.Get("missing")
+	assert.Equal(DefaultCacheTTL, remote.ttls["item:a"], "Item TTL")
+	assert.Equal(DefaultNegativeTTL, remote.ttls["item:missing"], "Negative TTL")
+
+	other := &CachedStore{Store: store, Remote: remote, Local: NewLRU(10)}
+	got, err := other.Get("a")
+	if assert.NoError(err) {
+		assert.Equal("shared", got.Name)
+	}
+	_, err = other.Get("missing")
+	assert.Equal(ErrNotFound, err)
+	assert.Equal(2, store.reads, "Shared between instances")
+
+	other.Invalidate("a")
+	assert.NotContains(remote.values, "item:a", "Invalidated by change")
+	assert.Equal(1, other.Local.Len(), "Invalidated locally")
+
+	remote.err = errors.New("Mock cache error")
+	_, err = cached.Get("a")
+	assert.NoError(err, "Cache failures fall back to the store")
+}
+
+func TestLRU(t *testing.T) {
+	now := time.Now()
+	c := NewLRU(2)
+	c.set("a", &Item{ID: "a"}, now.Add(time.Minute))
+	c.set("b", &Item{ID: "b"}, now.Add(time.Minute))
+	_, _ = c.get("a", now)
+	c.set("c", &Item{ID: "c"}, now.Add(time.Minute))
+
+	assert := assert.New(t)
+	assert.Equal(2, c.Len())
+	_, ok := c.get("b", now)
+	assert.False(ok, "Least recently used evicted")
+	_, ok = c.get("a", now)
+	assert.True(ok, "Recently used kept")
+	_, ok = c.get("c", now.Add(time.Minute))
+	assert.False(ok, "Expired")
+	assert.Equal(1, c.Len(), "Expired entry removed")
+}
@@ -0,0 +1,231 @@
+package sample
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Webhook request headers
+const (
+	HeaderSignature = "X-Sample-Signature-256" // HMAC-SHA256 of the body as "sha256=<hex>"
+	HeaderEvent     = "X-Sample-Event"         // item lifecycle event
+	HeaderDelivery  = "X-Sample-Delivery"      // unique delivery (message) ID
+)
+
+// WebhookPayload is a body of the webhook callback
+type WebhookPayload struct {
+	ID        string    `json:"id"`
+	Event     Event     `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Item      Item      `json:"item"`
+}
+
+// Delivery is a record of a single webhook delivery attempt
+type Delivery struct {
+	WebhookID   string    `json:"webhookId"`
+	ID          string    `json:"id"` // attempt time, message ID and attempt number
+	MessageID   string    `json:"messageId"`
+	Event       Event     `json:"event"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Succeeded   bool      `json:"succeeded"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+// DeliveryLog provides DynamoDB client capabilities for delivery attempts
+type DeliveryLog struct {
+	Client    dynamodbiface.DynamoDBAPI
+	TableName string
+	Retry     RetryPolicy // retries of throttled operations (single attempt if zero)
+	ctx       context.Context
+}
+
+// DeliveryLogTable returns a configured DynamoDB client for delivery attempts
+func DeliveryLogTable(tableName string) *DeliveryLog {
+
+	// retries are controlled by the delivery log policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &DeliveryLog{
+		Client:    dynamodb.New(sess),
+		TableName: tableName,
+		Retry:     DefaultRetryPolicy,
+	}
+}
+
+// WithContext returns a shallow copy of the delivery log bound to the context (e.g. Lambda deadline)
+func (l *DeliveryLog) WithContext(ctx context.Context) *DeliveryLog {
+	dl := *l
+	dl.ctx = ctx
+	return &dl
+}
+
+// Returns the bound context or a background one
+func (l *DeliveryLog) context() context.Context {
+	if l.ctx != nil {
+		return l.ctx
+	}
+	return context.Background()
+}
+
+// Record a delivery attempt
+func (l *DeliveryLog) Record(delivery Delivery) error {
+	delivery.ID = fmt.Sprintf("%s#%s#%d", delivery.AttemptedAt.UTC().Format(time.RFC3339Nano), delivery.MessageID, delivery.Attempt)
+
+	// prepare query data
+	av, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return err
+	}
+
+	// execute query
+	err = l.Retry.Do(l.context(), func() error {
+		_, err := l.Client.PutItem(&dynamodb.PutItemInput{Item: av, TableName: &l.TableName})
+		return err
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return errors.New("Failed to record webhook delivery")
+	}
+	return nil
+}
+
+// WebhookDeliverer sends signed item events to webhook endpoints
+type WebhookDeliverer struct {
+	Client *http.Client     // HTTP client (default client if nil)
+	Retry  RetryPolicy      // retries of failed deliveries (single attempt if zero)
+	Log    *DeliveryLog     // delivery attempts log (not recorded if nil)
+	Clock  func() time.Time // time source (system time if nil)
+	ctx    context.Context
+}
+
+// WithContext returns a copy of the deliverer bound to the context (e.g. Lambda deadline)
+func (d WebhookDeliverer) WithContext(ctx context.Context) *WebhookDeliverer {
+	d.ctx = ctx
+	return &d
+}
+
+// Returns the bound context or a background one
+func (d WebhookDeliverer) context() context.Context {
+	if d.ctx != nil {
+		return d.ctx
+	}
+	return context.Background()
+}
+
+// Returns current time
+func (d WebhookDeliverer) now() time.Time {
+	if d.Clock != nil {
+		return d.Clock()
+	}
+	return time.Now()
+}
+
+// Sign returns a signature header value of the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver an item event to the webhook, retrying on network errors, throttling and server errors
+func (d WebhookDeliverer) Deliver(hook Webhook, msgID string, event Event, item Item) error {
+	body, _ := json.Marshal(WebhookPayload{ID: msgID, Event: event, Timestamp: d.now().UTC(), Item: item})
+
+	attempt := 0
+	err := d.Retry.Do(d.context(), func() error {
+		attempt++
+		status, err := d.post(hook, msgID, event, body)
+		d.record(Delivery{
+			WebhookID:   hook.ID,
+			MessageID:   msgID,
+			Event:       event,
+			Attempt:     attempt,
+			StatusCode:  status,
+			Error:       errorText(err),
+			Succeeded:   err == nil,
+			AttemptedAt: d.now(),
+		})
+		return err
+	})
+
+	if err != nil {
+		log.Println(err.Error())
+		return fmt.Errorf("Failed to deliver %v to webhook %v", msgID, hook.ID)
+	}
+	return nil
+}
+
+// Sends a single webhook request and returns response status code
+func (d WebhookDeliverer) post(hook Webhook, msgID string, event Event, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(d.context(), http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, body))
+	req.Header.Set(HeaderEvent, string(event))
+	req.Header.Set(HeaderDelivery, msgID)
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		// network errors are transient
+		return 0, fmt.Errorf("%w: %v", errRetry, err)
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return res.StatusCode, nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return res.StatusCode, fmt.Errorf("%w: HTTP status %v", errRetry, res.StatusCode)
+	default:
+		return res.StatusCode, fmt.Errorf("HTTP status %v", res.StatusCode)
+	}
+}
+
+// Records a delivery attempt if the log is configured
+func (d WebhookDeliverer) record(delivery Delivery) {
+	if d.Log != nil {
+		// recording failures don't affect delivery
+		_ = d.Log.WithContext(d.context()).Record(delivery)
+	}
+}
+
+// Returns error text or an empty string
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Deliverer returns a configured deliverer recording attempts in the table
+func Deliverer(deliveryTableName string) *WebhookDeliverer {
+	return &WebhookDeliverer{
+		Client: &http.Client{Timeout: 5 * time.Second},
+		Retry:  RetryPolicy{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 5 * time.Second, Jitter: 0.5},
+		Log:    DeliveryLogTable(deliveryTableName),
+	}
+}
@@ -0,0 +1,121 @@
+package sample
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client recording written items
+type mockDdbRecorder struct {
+	mockDdb
+	puts []map[string]*dynamodb.AttributeValue
+}
+
+func (mock *mockDdbRecorder) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	mock.puts = append(mock.puts, input.Item)
+	return mock.mockDdb.PutItem(input)
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t,
+		"sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		Sign("key", []byte("The quick brown fox jumps over the lazy dog")),
+		"Signature")
+}
+
+func TestWebhookDeliverer_Deliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{
+			name:         "successful delivery",
+			statuses:     []int{http.StatusNoContent},
+			wantAttempts: 1,
+		},
+		{
+			name:         "retried server error",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			wantAttempts: 3,
+		},
+		{
+			name:         "client error not retried",
+			statuses:     []int{http.StatusBadRequest},
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "retries exhausted",
+			statuses:     []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			wantErr:      true,
+			wantAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert := assert.New(t)
+			hook := Webhook{ID: "test-webhook-id", Secret: "test-secret"}
+
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				assert.Equal(Sign(hook.Secret, body), r.Header.Get(HeaderSignature), "Signature header")
+				assert.Equal(string(ItemCreated), r.Header.Get(HeaderEvent), "Event header")
+				assert.Equal("test-message-id", r.Header.Get(HeaderDelivery), "Delivery header")
+				w.WriteHeader(tt.statuses[requests])
+				requests++
+			}))
+			defer server.Close()
+			hook.URL = server.URL
+
+			recorder := &mockDdbRecorder{}
+			deliverer := WebhookDeliverer{
+				Client: server.Client(),
+				Retry:  RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Clock: &mockClock{}},
+				Log:    &DeliveryLog{Client: recorder, TableName: "mock-table"},
+			}
+			err := deliverer.Deliver(hook, "test-message-id", ItemCreated, Item{ID: "test-item-id"})
+
+			if tt.wantErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+			assert.Equal(tt.wantAttempts, requests, "HTTP requests")
+			if assert.Len(recorder.puts, tt.wantAttempts, "Recorded attempts") {
+				var last Delivery
+				assert.NoError(dynamodbattribute.UnmarshalMap(recorder.puts[tt.wantAttempts-1], &last))
+				assert.Equal(hook.ID, last.WebhookID, "WebhookID")
+				assert.Equal(tt.wantAttempts, last.Attempt, "Attempt")
+				assert.Equal(tt.statuses[tt.wantAttempts-1], last.StatusCode, "StatusCode")
+				assert.Equal(!tt.wantErr, last.Succeeded, "Succeeded")
+				assert.NotEmpty(last.ID, "ID")
+			}
+		})
+	}
+}
+
+func TestWebhookDeliverer_DeliverUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	deliverer := WebhookDeliverer{
+		Client: server.Client(),
+		Retry:  RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, Clock: &mockClock{}},
+		Log:    &DeliveryLog{Client: &mockDdb{err: errors.New("Mock DynamoDB error")}, TableName: "mock-table"},
+	}
+	err := deliverer.Deliver(Webhook{ID: "test-webhook-id", URL: server.URL}, "test-message-id", ItemDeleted, Item{})
+
+	assert.Error(t, err)
+}
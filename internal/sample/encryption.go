@@ -0,0 +1,468 @@
+package sample
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/nb-samples/aws-serverless-go/internal/filter"
+)
+
+// EncryptedKey is the data key encrypting sensitive fields of an item, encrypted by a master key
+type EncryptedKey struct {
+	KeyID     string `json:"keyId"`               // master key
+	Key       []byte `json:"key"`                 // encrypted data key
+	CreatedAt int64  `json:"createdAt,omitempty"` // generation time of the data key (Unix seconds)
+}
+
+// KeyProvider generates and decrypts data keys of envelope encryption (e.g. AWS KMS).
+// Data keys are bound to the encryption context, so they can't be decrypted with a different one.
+type KeyProvider interface {
+	GenerateDataKey(ctx context.Context, keyID string, encryptionContext map[string]string) (plaintext, ciphertext []byte, err error)
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte, encryptionContext map[string]string) ([]byte, error)
+}
+
+// KMSKeys provides data keys of AWS KMS
+type KMSKeys struct {
+	Client kmsiface.KMSAPI
+	Retry  RetryPolicy // retries of throttled operations (single attempt if zero)
+}
+
+// KMS returns a configured KMS client
+func KMS() *KMSKeys {
+
+	// retries are controlled by the provider policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &KMSKeys{
+		Client: kms.New(sess),
+		Retry:  DefaultRetryPolicy,
+	}
+}
+
+// GenerateDataKey returns a new AES-256 data key and its copy encrypted by the master key
+func (k *KMSKeys) GenerateDataKey(ctx context.Context, keyID string, encryptionContext map[string]string) ([]byte, []byte, error) {
+	input := &kms.GenerateDataKeyInput{
+		KeyId:             &keyID,
+		KeySpec:           aws.String(kms.DataKeySpecAes256),
+		EncryptionContext: aws.StringMap(encryptionContext),
+	}
+
+	// execute query
+	var res *kms.GenerateDataKeyOutput
+	err := k.Retry.Do(ctx, func() (err error) {
+		res, err = k.Client.GenerateDataKey(input)
+		return
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.Plaintext, res.CiphertextBlob, nil
+}
+
+// Decrypt returns the plaintext of the data key encrypted by the master key
+func (k *KMSKeys) Decrypt(ctx context.Context, keyID string, ciphertext []byte, encryptionContext map[string]string) ([]byte, error) {
+	input := &kms.DecryptInput{
+		KeyId:             &keyID,
+		CiphertextBlob:    ciphertext,
+		EncryptionContext: aws.StringMap(encryptionContext),
+	}
+
+	// execute query
+	var res *kms.DecryptOutput
+	err := k.Retry.Do(ctx, func() (err error) {
+		res, err = k.Client.Decrypt(input)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.Plaintext, nil
+}
+
+// LocalKeys provides data keys encrypted by in-memory AES master keys by key ID (e.g. tests and local development)
+type LocalKeys map[string][]byte
+
+// GenerateDataKey returns a new AES-256 data key and its copy encrypted by the master key
+func (k LocalKeys) GenerateDataKey(ctx context.Context, keyID string, encryptionContext map[string]string) ([]byte, []byte, error) {
+	master, ok := k[keyID]
+	if !ok {
+		return nil, nil, fmt.Errorf("Unknown key %v", keyID)
+	}
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, err
+	}
+	ciphertext, err := seal(master, plaintext, canonicalContext(encryptionContext))
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, ciphertext, nil
+}
+
+// Decrypt returns the plaintext of the data key encrypted by the master key
+func (k LocalKeys) Decrypt(ctx context.Context, keyID string, ciphertext []byte, encryptionContext map[string]string) ([]byte, error) {
+	master, ok := k[keyID]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key %v", keyID)
+	}
+	return open(master, ciphertext, canonicalContext(encryptionContext))
+}
+
+// Returns the encryption context as additional authenticated data
+func canonicalContext(encryptionContext map[string]string) []byte {
+	pairs := make([]string, 0, len(encryptionContext))
+	for k, v := range encryptionContext {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return []byte(strings.Join(pairs, "&"))
+}
+
+// Encrypts the plaintext by AES-GCM, the random nonce is prepended to the ciphertext
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Decrypts and authenticates the ciphertext of seal
+func open(key, ciphertext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("Invalid ciphertext")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, aad)
+}
+
+// sensitiveField is a string field of the item tagged by `encrypt:"true"`
+type sensitiveField struct {
+	index []int  // field index for reflection
+	path  string // document path (e.g. details.location)
+}
+
+// encryptedFields are the sensitive fields of items
+var encryptedFields = sensitiveFields(reflect.TypeOf(Item{}), nil, "")
+
+// Returns the tagged string fields of the struct type and its nested structs
+func sensitiveFields(t reflect.Type, index []int, prefix string) []sensitiveField {
+	var fields []sensitiveField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		switch {
+		case f.Type.Kind() == reflect.String && f.Tag.Get("encrypt") == "true":
+			fields = append(fields, sensitiveField{index: fieldIndex, path: prefix + name})
+		case f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}):
+			fields = append(fields, sensitiveFields(f.Type, fieldIndex, prefix+name+".")...)
+		}
+	}
+	return fields
+}
+
+// Redacted replaces values of sensitive fields in audit entries, events and logs
+const Redacted = "[redacted]"
+
+// Redact returns the item with values of sensitive fields (tagged by `encrypt:"true"`) replaced by Redacted,
+// so their plaintext never leaves the repository
+func Redact(item Item) Item {
+	v := reflect.ValueOf(&item).Elem()
+	for _, f := range encryptedFields {
+		if field := v.FieldByIndex(f.index); field.String() != "" {
+			field.SetString(Redacted)
+		}
+	}
+	return item
+}
+
+// Reports whether the item field path is encrypted or contains encrypted fields
+func encrypted(path string) bool {
+	for _, f := range encryptedFields {
+		if f.path == path || strings.HasPrefix(f.path, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the filter refers to encrypted fields, such terms are evaluated in memory
+func encryptedTerm(e filter.Expr) bool {
+	found := false
+	_ = filter.Walk(e, func(c *filter.Compare) error {
+		if encrypted(c.Field) {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// Returns the encryption context binding data keys and fields to the item
+func itemContext(itemID string) map[string]string {
+	return map[string]string{"itemId": itemID}
+}
+
+// FieldEncryption encrypts sensitive item fields (tagged by `encrypt:"true"`) by data keys unique to every write
+type FieldEncryption struct {
+	Keys      KeyProvider
+	KeyID     string    // master key of new data keys
+	RotatedAt time.Time // last rotation of the master key material, which keeps the key ID (none if zero)
+	ctx       context.Context
+}
+
+// Encryption returns field encryption by data keys of the KMS key
+func Encryption(keyID string) *FieldEncryption {
+	return &FieldEncryption{Keys: KMS(), KeyID: keyID}
+}
+
+// WithContext returns a shallow copy of the encryption bound to the context (e.g. Lambda deadline)
+func (e *FieldEncryption) WithContext(ctx context.Context) *FieldEncryption {
+	enc := *e
+	enc.ctx = ctx
+	return &enc
+}
+
+// Returns the bound context or a background one
+func (e *FieldEncryption) context() context.Context {
+	if e.ctx != nil {
+		return e.ctx
+	}
+	return context.Background()
+}
+
+// Encrypt replaces sensitive fields of the item by their ciphertext.
+// Items without sensitive content and already encrypted items are kept.
+func (e *FieldEncryption) Encrypt(item Item) (Item, error) {
+	if item.Encryption != nil {
+		return item, nil
+	}
+	v := reflect.ValueOf(&item).Elem()
+	sensitive := false
+	for _, f := range encryptedFields {
+		sensitive = sensitive || v.FieldByIndex(f.index).String() != ""
+	}
+	if !sensitive {
+		return item, nil
+	}
+
+	plaintext, ciphertext, err := e.Keys.GenerateDataKey(e.context(), e.KeyID, itemContext(item.ID))
+	if err != nil {
+		log.Println(err.Error())
+		return item, errors.New("Failed to generate data key")
+	}
+	for _, f := range encryptedFields {
+		field := v.FieldByIndex(f.index)
+		if field.String() == "" {
+			continue
+		}
+		b, err := seal(plaintext, []byte(field.String()), []byte(item.ID+"/"+f.path)) // fields can't be swapped
+		if err != nil {
+			log.Println("Failed to encrypt:", err.Error())
+			return item, errors.New("Failed to encrypt item")
+		}
+		field.SetString(base64.StdEncoding.EncodeToString(b))
+	}
+	item.Encryption = &EncryptedKey{KeyID: e.KeyID, Key: ciphertext, CreatedAt: time.Now().Unix()}
+	return item, nil
+}
+
+// Decrypt restores sensitive fields of the encrypted item, items without a data key are kept
+func (e *FieldEncryption) Decrypt(item *Item) error {
+	if item == nil || item.Encryption == nil {
+		return nil
+	}
+	plaintext, err := e.Keys.Decrypt(e.context(), item.Encryption.KeyID, item.Encryption.Key, itemContext(item.ID))
+	if err != nil {
+		log.Println(err.Error())
+		return fmt.Errorf("Failed to decrypt data key of item %v", item.ID)
+	}
+	v := reflect.ValueOf(item).Elem()
+	for _, f := range encryptedFields {
+		field := v.FieldByIndex(f.index)
+		if field.String() == "" { // missing in sparse fieldsets
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(field.String())
+		if err == nil {
+			b, err = open(plaintext, b, []byte(item.ID+"/"+f.path))
+		}
+		if err != nil {
+			log.Println("Failed to decrypt:", err.Error())
+			return fmt.Errorf("Invalid ciphertext of item %v", item.ID)
+		}
+		field.SetString(string(b))
+	}
+	item.Encryption = nil
+	return nil
+}
+
+// Reencrypt encrypts items and their versions (if enabled) by data keys of the current master key
+// after key rotation, items encrypted by the current key are skipped. Data keys generated before
+// the rotation of the key material (RotatedAt) are replaced even if the key ID is unchanged.
+// Only the data key and encrypted fields are written, so concurrent changes of other fields are kept.
+// Items changed by a concurrent write are encrypted by the current key already.
+func (r *Repo) Reencrypt() (int, error) {
+	if r.Encryption == nil {
+		return 0, errors.New("Item encryption is not configured")
+	}
+	n, err := r.reencrypt(r.TableName, "", "id")
+	if err != nil || r.VersionTableName == "" {
+		return n, err
+	}
+	versions, err := r.reencrypt(r.VersionTableName, "item.", "itemId", "version")
+	return n + versions, err
+}
+
+// Re-encrypts items of the table stored under the path prefix, returns the number of re-encrypted items
+func (r *Repo) reencrypt(table, prefix string, keys ...string) (int, error) {
+	b := &filter.Builder{
+		Names:  map[string]*string{},
+		Values: map[string]*dynamodb.AttributeValue{":keyId": {S: aws.String(r.Encryption.KeyID)}},
+	}
+	stale := b.Path(prefix+"encryption.keyId") + " <> :keyId"
+	if !r.Encryption.RotatedAt.IsZero() { // data keys of earlier key material (or unknown age)
+		createdAt := b.Path(prefix + "encryption.createdAt")
+		stale = "(" + stale + " OR attribute_not_exists(" + createdAt + ") OR " + createdAt + " < :rotatedAt)"
+		b.Values[":rotatedAt"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(r.Encryption.RotatedAt.Unix(), 10))}
+	}
+	input := &dynamodb.ScanInput{
+		TableName:                 &table,
+		FilterExpression:          aws.String("attribute_exists(" + b.Path(prefix+"encryption") + ") AND " + stale),
+		ExpressionAttributeNames:  b.Names,
+		ExpressionAttributeValues: b.Values,
+	}
+
+	reencrypted := 0
+	for {
+		// execute query
+		var res *dynamodb.ScanOutput
+		err := r.Retry.Do(r.context(), func() (err error) {
+			res, err = r.Client.Scan(input)
+			return
+		})
+		if err != nil {
+			log.Println(err.Error())
+			return reencrypted, errors.New("Failed to scan the repository")
+		}
+
+		// process query results
+		for _, av := range res.Items {
+			key := make(map[string]*dynamodb.AttributeValue, len(keys))
+			for _, name := range keys {
+				key[name] = av[name]
+			}
+			doc := av
+			if prefix != "" {
+				doc = av[strings.TrimSuffix(prefix, ".")].M
+			}
+			ok, err := r.reencryptItem(table, prefix, key, doc)
+			if err != nil {
+				return reencrypted, err
+			} else if ok {
+				reencrypted++
+			}
+		}
+
+		if len(res.LastEvaluatedKey) == 0 {
+			return reencrypted, nil
+		}
+		input.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+}
+
+// Re-encrypts a single item unless it was changed concurrently
+func (r *Repo) reencryptItem(table, prefix string, key, doc map[string]*dynamodb.AttributeValue) (bool, error) {
+	var item Item
+	if err := dynamodbattribute.UnmarshalMap(doc, &item); err != nil {
+		log.Println("Failed to unmarshal:", err.Error())
+		return false, err
+	}
+	previous := item.Encryption
+	enc := r.Encryption.WithContext(r.context())
+	if err := enc.Decrypt(&item); err != nil {
+		return false, err
+	}
+	item, err := enc.Encrypt(item)
+	if err != nil {
+		return false, err
+	}
+
+	// prepare query data
+	encryption, err := dynamodbattribute.Marshal(item.Encryption)
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return false, err
+	}
+	b := &filter.Builder{
+		Names:  map[string]*string{},
+		Values: map[string]*dynamodb.AttributeValue{":encryption": encryption, ":previous": {B: previous.Key}},
+	}
+	update := []string{b.Path(prefix+"encryption") + " = :encryption"}
+	v := reflect.ValueOf(item)
+	for i, f := range encryptedFields {
+		if s := v.FieldByIndex(f.index).String(); s != "" {
+			placeholder := fmt.Sprintf(":field%d", i)
+			update = append(update, b.Path(prefix+f.path)+" = "+placeholder)
+			b.Values[placeholder] = &dynamodb.AttributeValue{S: aws.String(s)}
+		}
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 &table,
+		Key:                       key,
+		UpdateExpression:          aws.String("SET " + strings.Join(update, ", ")),
+		ConditionExpression:       aws.String(b.Path(prefix+"encryption.key") + " = :previous"), // data key of any write is unique
+		ExpressionAttributeNames:  b.Names,
+		ExpressionAttributeValues: b.Values,
+	}
+
+	// execute query
+	err = r.Retry.Do(r.context(), func() (err error) {
+		_, err = r.Client.UpdateItem(input)
+		return
+	})
+	if conditionFailed(err) {
+		return false, nil
+	} else if err != nil {
+		log.Println(err.Error())
+		return false, errors.New("Failed to save into the repository")
+	}
+	return true, nil
+}
@@ -0,0 +1,176 @@
+package sample
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+// Local master keys of tests
+var testKeys = LocalKeys{
+	"key-1": bytes.Repeat([]byte{1}, 32),
+	"key-2": bytes.Repeat([]byte{2}, 32),
+}
+
+// Mock DynamoDB client scanning stored items and recording updates
+type mockDdbScan struct {
+	mockDdb
+	items   []map[string]*dynamodb.AttributeValue
+	updates []*dynamodb.UpdateItemInput
+	scan    *dynamodb.ScanInput // the last Scan input
+}
+
+func (mock *mockDdbScan) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	mock.scan = input
+	return &dynamodb.ScanOutput{Items: mock.items}, mock.err
+}
+
+func (mock *mockDdbScan) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	mock.updates = append(mock.updates, input)
+	return &dynamodb.UpdateItemOutput{}, mock.err
+}
+
+func TestFieldEncryption(t *testing.T) {
+	enc := &FieldEncryption{Keys: testKeys, KeyID: "key-1"}
+	item := Item{ID: "test-item-id", Name: "test-item-name", Details: Details{Location: "Vault 7", Quantity: 2}}
+
+	assert := assert.New(t)
+	encrypted, err := enc.Encrypt(item)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.NotEqual("Vault 7", encrypted.Details.Location, "Encrypted location")
+	assert.Equal("test-item-name", encrypted.Name, "Plaintext name")
+	assert.Equal("key-1", encrypted.Encryption.KeyID, "Master key")
+	again, _ := enc.Encrypt(encrypted)
+	assert.Equal(encrypted, again, "Already encrypted")
+
+	decrypted := encrypted
+	if assert.NoError(enc.Decrypt(&decrypted)) {
+		assert.Equal(item, decrypted, "Decrypted item")
+	}
+
+	moved := encrypted
+	moved.ID = "other-item-id"
+	assert.Error(enc.Decrypt(&moved), "Data key bound to the item")
+
+	plain, err := enc.Encrypt(Item{ID: "test-item-id", Name: "test-item-name"})
+	if assert.NoError(err) {
+		assert.Nil(plain.Encryption, "Nothing to encrypt")
+	}
+
+	_, err = (&FieldEncryption{Keys: testKeys, KeyID: "unknown-key"}).Encrypt(item)
+	assert.Error(err, "Unknown master key")
+}
+
+func TestRepo_Encryption(t *testing.T) {
+	ddb := &mockDdbStore{}
+	r := &Repo{Client: ddb, TableName: "mock-table", Encryption: &FieldEncryption{Keys: testKeys, KeyID: "key-1"}}
+
+	assert := assert.New(t)
+	saved, err := r.Save(Item{ID: "test-item-id", Name: "test-item-name", Details: Details{Location: "Vault 7"}})
+	if !assert.NoError(err, "Save") {
+		return
+	}
+	assert.Equal("Vault 7", saved.Details.Location, "Saved item keeps plaintext")
+	assert.NotEqual("Vault 7", aws.StringValue(ddb.stored["details"].M["location"].S), "Stored ciphertext")
+	assert.NotContains(ddb.stored, "location", "Encrypted location not indexed")
+	assert.Contains(ddb.stored, "encryption", "Stored data key")
+
+	got, err := r.Get("test-item-id")
+	if assert.NoError(err, "Get") {
+		assert.Equal("Vault 7", got.Details.Location, "Decrypted location")
+		assert.Nil(got.Encryption)
+	}
+
+	_, err = (&Repo{Client: ddb, TableName: "mock-table"}).Get("test-item-id")
+	assert.Error(err, "Encrypted item without encryption")
+}
+
+func TestRepo_QueryEncrypted(t *testing.T) {
+	old := &FieldEncryption{Keys: testKeys, KeyID: "key-1"}
+	a, _ := old.Encrypt(Item{ID: "a", Name: "gold", Details: Details{Location: "Vault 7"}})
+	b, _ := old.Encrypt(Item{ID: "b", Name: "silver", Details: Details{Location: "Vault 8"}})
+	client := &mockDdbQuery{items: []Item{a, b}}
+	r := &Repo{Client: client, TableName: "mock-table", Encryption: old}
+
+	assert := assert.New(t)
+	got, _, err := r.Query(Query{Location: "Vault 7", Fields: []string{"name", "location"}}, Page{})
+	if assert.NoError(err) && assert.Len(got, 1, "Filtered in memory") {
+		assert.Equal("Vault 7", got[0].Details.Location)
+	}
+	assert.Equal(IndexByName, aws.StringValue(client.input.IndexName), "Location not indexed")
+	var names []string
+	for _, name := range client.input.ExpressionAttributeNames {
+		names = append(names, aws.StringValue(name))
+	}
+	assert.Subset(names, []string{"id", "encryption"}, "Projection with data key")
+}
+
+func TestRepo_Reencrypt(t *testing.T) {
+	old := &FieldEncryption{Keys: testKeys, KeyID: "key-1"}
+	item, _ := old.Encrypt(Item{ID: "test-item-id", Details: Details{Location: "Vault 7"}})
+	av, _ := dynamodbattribute.MarshalMap(item)
+	client := &mockDdbScan{items: []map[string]*dynamodb.AttributeValue{av}}
+	r := &Repo{Client: client, TableName: "mock-table", Encryption: &FieldEncryption{Keys: testKeys, KeyID: "key-2"}}
+
+	assert := assert.New(t)
+	n, err := r.Reencrypt()
+	if !assert.NoError(err) || !assert.Len(client.updates, 1) {
+		return
+	}
+	assert.Equal(1, n)
+	update := client.updates[0]
+	assert.Equal("test-item-id", aws.StringValue(update.Key["id"].S), "Item key")
+	assert.Equal(item.Encryption.Key, update.ExpressionAttributeValues[":previous"].B, "Unchanged since scanned")
+
+	var key EncryptedKey
+	_ = dynamodbattribute.Unmarshal(update.ExpressionAttributeValues[":encryption"], &key)
+	reencrypted := Item{ID: item.ID, Details: Details{Location: aws.StringValue(update.ExpressionAttributeValues[":field0"].S)}, Encryption: &key}
+	assert.Equal("key-2", key.KeyID, "Current master key")
+	if assert.NoError(r.Encryption.WithContext(context.Background()).Decrypt(&reencrypted)) {
+		assert.Equal("Vault 7", reencrypted.Details.Location, "Re-encrypted location")
+	}
+
+	_, err = (&Repo{Client: client, TableName: "mock-table"}).Reencrypt()
+	assert.Error(err, "Encryption not configured")
+}
+
+func TestRepo_ReencryptRotated(t *testing.T) {
+	enc := &FieldEncryption{Keys: testKeys, KeyID: "key-1"}
+	item, _ := enc.Encrypt(Item{ID: "test-item-id", Details: Details{Location: "Vault 7"}})
+	av, _ := dynamodbattribute.MarshalMap(item)
+	client := &mockDdbScan{items: []map[string]*dynamodb.AttributeValue{av}}
+	r := &Repo{Client: client, TableName: "mock-table", Encryption: enc}
+
+	assert := assert.New(t)
+	assert.NotZero(item.Encryption.CreatedAt, "Data key generation time")
+	enc.RotatedAt = time.Unix(item.Encryption.CreatedAt, 0).Add(time.Hour)
+	n, err := r.Reencrypt()
+	if !assert.NoError(err) || !assert.Len(client.updates, 1) {
+		return
+	}
+	assert.Equal(1, n, "Same key ID re-encrypted after rotation")
+	scan := client.scan
+	assert.Contains(*scan.FilterExpression, " < :rotatedAt", "Data keys before rotation")
+	assert.Equal(strconv.FormatInt(enc.RotatedAt.Unix(), 10), *scan.ExpressionAttributeValues[":rotatedAt"].N)
+
+	var key EncryptedKey
+	_ = dynamodbattribute.Unmarshal(client.updates[0].ExpressionAttributeValues[":encryption"], &key)
+	assert.Equal("key-1", key.KeyID, "Current master key")
+	assert.False(time.Unix(key.CreatedAt, 0).Before(time.Unix(item.Encryption.CreatedAt, 0)), "New data key")
+	assert.NotEqual(item.Encryption.Key, key.Key, "New data key")
+
+	enc.RotatedAt = time.Time{}
+	_, err = r.Reencrypt()
+	if assert.NoError(err) {
+		assert.NotContains(*client.scan.FilterExpression, ":rotatedAt", "Key ID changes only")
+	}
+}
@@ -0,0 +1,106 @@
+package sample
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+)
+
+// EventSource is the source of all item events sent to EventBridge
+const EventSource = "sample.items"
+
+// EventBridgeBus provides EventBridge client capabilities
+type EventBridgeBus struct {
+	Client   eventbridgeiface.EventBridgeAPI
+	Name     string        // event bus name or ARN
+	Retry    RetryPolicy   // retries of throttled requests and failed entries (single attempt if zero)
+	Payloads *PayloadStore // claim-check store of event details over the EventBridge limit (sent as is if nil)
+	ctx      context.Context
+}
+
+// WithContext returns a copy of the event bus client bound to the context (e.g. Lambda deadline)
+func (b EventBridgeBus) WithContext(ctx context.Context) *EventBridgeBus {
+	b.ctx = ctx
+	return &b
+}
+
+// Returns the bound context or a background one
+func (b EventBridgeBus) context() context.Context {
+	if b.ctx != nil {
+		return b.ctx
+	}
+	return context.Background()
+}
+
+// PublishEvent sends an item to the event bus with detail-type of the event and returns EventId
+func (b EventBridgeBus) PublishEvent(event Event, item Item) (string, error) {
+	// prepare event details
+	detail, err := b.detail(item)
+	if err != nil {
+		return "", err
+	}
+
+	input := &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{{
+			EventBusName: &b.Name,
+			Source:       aws.String(EventSource),
+			DetailType:   aws.String(string(event)),
+			Detail:       aws.String(detail),
+		}},
+	}
+
+	// put the event to the bus, a failed entry doesn't fail the whole request
+	var eventID string
+	err = b.Retry.Do(b.context(), func() error {
+		out, err := b.Client.PutEvents(input)
+		if err != nil {
+			return err
+		}
+		if len(out.Entries) == 0 {
+			return errors.New("Missing result of the event entry")
+		}
+		entry := out.Entries[0]
+		if aws.Int64Value(out.FailedEntryCount) > 0 || entry.ErrorCode != nil {
+			return awserr.New(aws.StringValue(entry.ErrorCode), aws.StringValue(entry.ErrorMessage), nil)
+		}
+		eventID = aws.StringValue(entry.EventId)
+		return nil
+	})
+
+	if err != nil {
+		log.Println(err.Error())
+		return "", fmt.Errorf("Failed to send an event to %v", b.Name)
+	}
+	return eventID, nil
+}
+
+// Returns the event detail of the item, which is replaced by a claim check if over the EventBridge limit.
+// Sensitive fields are redacted.
+func (b EventBridgeBus) detail(item Item) (string, error) {
+	detail, _ := json.Marshal(Redact(item))
+	if b.Payloads == nil {
+		return string(detail), nil
+	}
+	return b.Payloads.WithContext(b.context()).Message(item.ID, detail)
+}
+
+// EventBus returns a configured event bus client
+func EventBus(name string) *EventBridgeBus {
+
+	// retries are controlled by the event bus policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &EventBridgeBus{
+		Client: eventbridge.New(sess),
+		Name:   name,
+		Retry:  DefaultRetryPolicy,
+	}
+}
@@ -0,0 +1,119 @@
+package sample
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock EventBridge client failing a number of entries before success
+type mockEventBridge struct {
+	eventbridgeiface.EventBridgeAPI
+	failures []string // error codes of failed entries per call
+	err      error
+	empty    bool // no result entries in the response
+	inputs   []*eventbridge.PutEventsInput
+}
+
+func (mock *mockEventBridge) PutEvents(input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	call := len(mock.inputs)
+	mock.inputs = append(mock.inputs, input)
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	if mock.empty {
+		return &eventbridge.PutEventsOutput{FailedEntryCount: aws.Int64(0)}, nil
+	}
+	if call < len(mock.failures) {
+		return &eventbridge.PutEventsOutput{
+			FailedEntryCount: aws.Int64(1),
+			Entries:          []*eventbridge.PutEventsResultEntry{{ErrorCode: aws.String(mock.failures[call])}},
+		}, nil
+	}
+	return &eventbridge.PutEventsOutput{
+		FailedEntryCount: aws.Int64(0),
+		Entries:          []*eventbridge.PutEventsResultEntry{{EventId: aws.String("test-event-id")}},
+	}, nil
+}
+
+func TestEventBridgeBus_PublishEventRedacted(t *testing.T) {
+	client := &mockEventBridge{}
+	bus := EventBridgeBus{Client: client, Name: "test-bus"}
+
+	assert := assert.New(t)
+	_, err := bus.PublishEvent(ItemUpdated, Item{ID: "test-item-id", Details: Details{Location: "secret"}})
+	if assert.NoError(err) {
+		assert.NotContains(*client.inputs[0].Entries[0].Detail, "secret", "Redacted location")
+	}
+}
+
+func TestEventBridgeBus_PublishEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		client    *mockEventBridge
+		want      string
+		wantErr   bool
+		wantCalls int
+	}{
+		{
+			name:      "successful publishing",
+			client:    &mockEventBridge{},
+			want:      "test-event-id",
+			wantCalls: 1,
+		},
+		{
+			name:      "failed entry retried",
+			client:    &mockEventBridge{failures: []string{"ThrottlingException"}},
+			want:      "test-event-id",
+			wantCalls: 2,
+		},
+		{
+			name:      "failed entry not retryable",
+			client:    &mockEventBridge{failures: []string{"MalformedDetail"}},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "missing entry result",
+			client:    &mockEventBridge{empty: true},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "unsuccessful publishing",
+			client:    &mockEventBridge{err: errors.New("Mock EventBridge error")},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bus Publisher = EventBridgeBus{
+				Client: tt.client,
+				Name:   "mock-bus",
+				Retry:  RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Clock: &mockClock{}},
+			}
+			got, err := bus.PublishEvent(ItemDeleted, Item{ID: "test-item-id"})
+
+			assert := assert.New(t)
+			assert.Len(tt.client.inputs, tt.wantCalls, "PutEvents calls")
+			if entry := tt.client.inputs[0].Entries[0]; assert.NotNil(entry) {
+				assert.Equal(string(ItemDeleted), *entry.DetailType, "DetailType")
+				assert.Equal(EventSource, *entry.Source, "Source")
+				assert.Equal("mock-bus", *entry.EventBusName, "EventBusName")
+				assert.JSONEq(`{"id":"test-item-id","details":{}}`, *entry.Detail, "Detail")
+			}
+			if tt.wantErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(tt.want, got, "EventId")
+			}
+		})
+	}
+}
@@ -0,0 +1,452 @@
+package sample
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// File formats of exports and imports
+const (
+	FormatNDJSON = "ndjson" // an item JSON document per line
+	FormatCSV    = "csv"    // a header line followed by item records (see csvColumns)
+)
+
+// Limits of imports
+const (
+	maxImportLine     = 1024 * 1024 // longest NDJSON line in bytes
+	maxReportedErrors = 100         // failed rows listed by a report
+)
+
+// csvColumns are the columns of exported CSV files. Imported files may have any of them in any order,
+// server-managed columns (reserved, createdAt, updatedAt, version) are ignored by imports.
+var csvColumns = []string{"id", "name", "description", "location", "quantity", "reserved", "tags", "attributes", "expiresAt", "createdAt", "updatedAt", "version"}
+
+// ErrFormat is returned for files of an unsupported format
+var ErrFormat = errors.New("Unsupported file format, use .ndjson or .csv")
+
+// FileFormat returns the format of the file by its extension
+func FileFormat(key string) (string, error) {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	case ".csv":
+		return FormatCSV, nil
+	}
+	return "", ErrFormat
+}
+
+// ImportReport is an outcome of an import stored under ReportKey of the imported file
+type ImportReport struct {
+	Source   string        `json:"source"`
+	Imported int           `json:"imported"`
+	Failed   int           `json:"failed"`
+	Errors   []ImportError `json:"errors,omitempty"` // first failed rows
+}
+
+// ImportError is a failure of a single row (row 0 fails the whole file)
+type ImportError struct {
+	Row     int    `json:"row"` // line number of NDJSON, record number of CSV (header is 1)
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+// Adds a failed row to the report
+func (r *ImportReport) fail(row int, id string, err error) {
+	r.Failed++
+	if len(r.Errors) < maxReportedErrors {
+		r.Errors = append(r.Errors, ImportError{Row: row, ID: id, Message: err.Error()})
+	}
+}
+
+// ReportKey returns the object key of the import report of the file
+func ReportKey(key string) string {
+	return "reports/" + key + ".json"
+}
+
+// ItemExport provides S3 client capabilities for exports and imports of items
+type ItemExport struct {
+	Client s3iface.S3API
+	Bucket string
+	Retry  RetryPolicy // retries of throttled operations (single attempt if zero)
+	ctx    context.Context
+}
+
+// Exports returns a configured S3 client for exports and imports
+func Exports(bucket string) *ItemExport {
+
+	// retries are controlled by the export policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &ItemExport{
+		Client: s3.New(sess),
+		Bucket: bucket,
+		Retry:  DefaultRetryPolicy,
+	}
+}
+
+// WithContext returns a shallow copy of the export client bound to the context (e.g. Lambda deadline)
+func (e *ItemExport) WithContext(ctx context.Context) *ItemExport {
+	export := *e
+	export.ctx = ctx
+	return &export
+}
+
+// Returns the bound context or a background one
+func (e *ItemExport) context() context.Context {
+	if e.ctx != nil {
+		return e.ctx
+	}
+	return context.Background()
+}
+
+// Export writes items of the repository scanned by the options to the object in the format,
+// returns the number of exported items. The object is uploaded while it's written.
+func (e *ItemExport) Export(repo *Repo, key, format string, opts ScanOptions) (int, error) {
+	contentType := "application/x-ndjson"
+	if format == FormatCSV {
+		contentType = "text/csv"
+	} else if format != FormatNDJSON {
+		return 0, ErrFormat
+	}
+
+	pr, pw := io.Pipe()
+	uploaded := make(chan error, 1)
+	go func() {
+		_, err := s3manager.NewUploaderWithClient(e.Client).UploadWithContext(e.context(), &s3manager.UploadInput{
+			Bucket:      &e.Bucket,
+			Key:         &key,
+			Body:        pr,
+			ContentType: &contentType,
+		})
+		pr.CloseWithError(err) // unblocks the writer on failure
+		uploaded <- err
+	}()
+
+	w := newItemWriter(pw, format)
+	var mu sync.Mutex
+	exported := 0
+	_, err := repo.WithContext(e.context()).ScanAll(opts, func(segment int, items []Item) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, item := range items {
+			if err := w.write(item); err != nil {
+				return err
+			}
+			exported++
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.flush()
+	}
+	if err != nil {
+		pw.CloseWithError(err) // upload is aborted
+	} else {
+		_ = pw.Close()
+	}
+
+	if uploadErr := <-uploaded; uploadErr != nil && err == nil {
+		log.Println(uploadErr.Error())
+		err = errors.New("Failed to upload export")
+	}
+	return exported, err
+}
+
+// itemWriter encodes items in a file format
+type itemWriter struct {
+	buf    *bufio.Writer
+	csv    *csv.Writer // nil for NDJSON
+	header bool
+}
+
+// Returns a writer of items in the format
+func newItemWriter(w io.Writer, format string) *itemWriter {
+	iw := &itemWriter{buf: bufio.NewWriter(w)}
+	if format == FormatCSV {
+		iw.csv = csv.NewWriter(iw.buf)
+	}
+	return iw
+}
+
+// Writes a single item (preceded by the CSV header)
+func (w *itemWriter) write(item Item) error {
+	if w.csv == nil {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		_, err = w.buf.Write(append(b, '\n'))
+		return err
+	}
+	if !w.header {
+		w.header = true
+		if err := w.csv.Write(csvColumns); err != nil {
+			return err
+		}
+	}
+	record, err := csvRecord(item)
+	if err != nil {
+		return err
+	}
+	return w.csv.Write(record)
+}
+
+// Flushes buffered items, an empty CSV file gets its header
+func (w *itemWriter) flush() error {
+	if w.csv != nil {
+		if !w.header {
+			w.header = true
+			if err := w.csv.Write(csvColumns); err != nil {
+				return err
+			}
+		}
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
+
+// Returns the CSV record of the item in the order of csvColumns
+func csvRecord(item Item) ([]string, error) {
+	var attributes string
+	if len(item.Attributes) > 0 {
+		b, err := json.Marshal(item.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		attributes = string(b)
+	}
+	return []string{
+		item.ID,
+		item.Name,
+		item.Details.Description,
+		item.Details.Location,
+		strconv.Itoa(item.Details.Quantity),
+		strconv.Itoa(item.Details.Reserved),
+		strings.Join(item.Tags, ","), // tags never contain commas
+		attributes,
+		formatTime(item.ExpiresAt),
+		formatTime(item.CreatedAt),
+		formatTime(item.UpdatedAt),
+		strconv.Itoa(item.Version),
+	}, nil
+}
+
+// Returns the time in RFC 3339 format (empty if nil)
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// Parses a CSV record by the column indexes of the header
+func parseRecord(record []string, columns map[string]int) (Item, error) {
+	var item Item
+	value := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	item.ID = value("id")
+	item.Name = value("name")
+	item.Details.Description = value("description")
+	item.Details.Location = value("location")
+	if s := value("quantity"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return item, fmt.Errorf("Invalid quantity %q", s)
+		}
+		item.Details.Quantity = n
+	}
+	if s := value("tags"); s != "" {
+		item.Tags = strings.Split(s, ",")
+	}
+	if s := value("attributes"); s != "" {
+		if err := json.Unmarshal([]byte(s), &item.Attributes); err != nil {
+			return item, fmt.Errorf("Invalid attributes, expected a JSON object")
+		}
+	}
+	if s := value("expiresAt"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return item, fmt.Errorf("Invalid expiresAt %q, expected RFC 3339 time", s)
+		}
+		item.ExpiresAt = &t
+	}
+	return item, nil
+}
+
+// Reads items of the file row by row, rows failed to parse are passed with their error.
+// Reading stops at a failure of the callback or of the file.
+func readItems(r io.Reader, format string, fn func(row int, item Item, err error) error) error {
+	if format == FormatNDJSON {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), maxImportLine)
+		for row := 1; sc.Scan(); row++ {
+			line := bytes.TrimSpace(sc.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var item Item
+			err := json.Unmarshal(line, &item)
+			if err = fn(row, item, err); err != nil {
+				return err
+			}
+		}
+		return sc.Err()
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // short records are reported per row
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	columns := make(map[string]int, len(header))
+	known := make(map[string]bool, len(csvColumns))
+	for _, name := range csvColumns {
+		known[name] = true
+	}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")) // byte order mark of spreadsheet exports
+		if !known[name] {
+			return fmt.Errorf("Unknown CSV column %q", name)
+		}
+		columns[name] = i
+	}
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var item Item
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			err = parseErr.Err
+		} else if err != nil {
+			return err
+		} else if len(record) != len(header) {
+			err = fmt.Errorf("Expected %d fields, got %d", len(header), len(record))
+		} else {
+			item, err = parseRecord(record, columns)
+		}
+		if err = fn(row, item, err); err != nil {
+			return err
+		}
+	}
+}
+
+// Import saves valid items of the object in batches and stores the report of failed rows under ReportKey.
+// Objects failed to open are returned as errors (e.g. to be retried), other failures are reported only
+// as rows saved before can't be rolled back. Items are written by BatchSave, so existing items
+// of the same IDs are overwritten and items without IDs get new ones.
+func (e *ItemExport) Import(repo *Repo, key string) (*ImportReport, error) {
+	report := &ImportReport{Source: key}
+	format, err := FileFormat(key)
+	if err != nil {
+		report.fail(0, "", err)
+		return report, e.saveReport(key, report)
+	}
+
+	// execute query
+	var res *s3.GetObjectOutput
+	err = e.Retry.Do(e.context(), func() (err error) {
+		res, err = e.Client.GetObject(&s3.GetObjectInput{Bucket: &e.Bucket, Key: &key})
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, fmt.Errorf("Failed to read import %v", key)
+	}
+	defer res.Body.Close()
+
+	// valid rows are saved in batches
+	repo = repo.WithContext(e.context())
+	now := time.Now()
+	var batch []Item
+	var rows []int
+	save := func() {
+		for i, result := range repo.BatchSave(batch) {
+			if result.Err != nil {
+				report.fail(rows[i], result.ID, result.Err)
+			} else {
+				report.Imported++
+			}
+		}
+		batch, rows = batch[:0], rows[:0]
+	}
+	err = readItems(res.Body, format, func(row int, item Item, err error) error {
+		if err == nil && item.ExpiresAt != nil && !item.ExpiresAt.After(now) {
+			err = errors.New("Item expiry must be in the future")
+		}
+		if err == nil {
+			err = item.Validate()
+		}
+		if err != nil {
+			report.fail(row, item.ID, err)
+			return nil
+		}
+		batch, rows = append(batch, item), append(rows, row)
+		if len(batch) == maxBatchWrite {
+			save()
+		}
+		return e.context().Err()
+	})
+	if len(batch) > 0 && err == nil {
+		save()
+	}
+	if err != nil {
+		log.Println("Failed to read:", err.Error())
+		report.fail(0, "", err) // rows read before are imported
+	}
+	return report, e.saveReport(key, report)
+}
+
+// Stores the report of the imported file
+func (e *ItemExport) saveReport(key string, report *ImportReport) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	reportKey := ReportKey(key)
+
+	// execute query
+	err = e.Retry.Do(e.context(), func() (err error) {
+		_, err = e.Client.PutObject(&s3.PutObjectInput{
+			Bucket:      &e.Bucket,
+			Key:         &reportKey,
+			Body:        bytes.NewReader(b),
+			ContentType: aws.String("application/json"),
+		})
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return errors.New("Failed to store import report")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package sample
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"hash/fnv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+// Scan returns items of the segment of the table in a single page
+func (mock *mockDdbBatch) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	output := &dynamodb.ScanOutput{}
+	for id, item := range mock.tables[*input.TableName] {
+		h := fnv.New32()
+		_, _ = h.Write([]byte(id))
+		if int64(h.Sum32())%*input.TotalSegments == *input.Segment {
+			output.Items = append(output.Items, item)
+		}
+	}
+	return output, nil
+}
+
+func TestItemExport_Export(t *testing.T) {
+	client, local := localClient(t)
+	export := &ItemExport{Client: client, Bucket: local.bucket}
+	ddb := newMockDdbBatch(0)
+	r := &Repo{Client: ddb, TableName: "mock-table"}
+	r.BatchSave([]Item{
+		{ID: "a", Name: "first", Tags: []string{"x", "y"}, Attributes: map[string]interface{}{"color": "red"}},
+		{ID: "b", Name: "second, quoted \"name\"", Details: Details{Quantity: 3}},
+		{ID: "c", Name: "third"},
+	})
+
+	assert := assert.New(t)
+	n, err := export.Export(r, "exports/items.ndjson", FormatNDJSON, ScanOptions{Segments: 2})
+	if assert.NoError(err) {
+		assert.Equal(3, n)
+		var ids []string
+		sc := bufio.NewScanner(bytes.NewReader(local.objects["exports/items.ndjson"].body))
+		for sc.Scan() {
+			var item Item
+			if assert.NoError(json.Unmarshal(sc.Bytes(), &item)) {
+				ids = append(ids, item.ID)
+			}
+		}
+		assert.ElementsMatch([]string{"a", "b", "c"}, ids, "Items of all segments")
+	}
+
+	n, err = export.Export(r, "exports/items.csv", FormatCSV, ScanOptions{Segments: 3, Concurrency: 2})
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(3, n)
+	assert.Equal("text/csv", local.objects["exports/items.csv"].contentType)
+	items := map[string]Item{}
+	err = readItems(bytes.NewReader(local.objects["exports/items.csv"].body), FormatCSV, func(row int, item Item, err error) error {
+		items[item.ID] = item
+		return err
+	})
+	if assert.NoError(err) && assert.Len(items, 3) {
+		assert.Equal("second, quoted \"name\"", items["b"].Name, "Quoted field")
+		assert.Equal(3, items["b"].Details.Quantity)
+		assert.Equal([]string{"x", "y"}, items["a"].Tags)
+		assert.Equal(map[string]interface{}{"color": "red"}, items["a"].Attributes)
+	}
+
+	_, err = export.Export(r, "exports/items.xml", "xml", ScanOptions{})
+	assert.Equal(ErrFormat, err)
+}
+
+func TestItemExport_Import(t *testing.T) {
+	client, local := localClient(t)
+	export := &ItemExport{Client: client, Bucket: local.bucket}
+	ddb := newMockDdbBatch(0)
+	r := &Repo{Client: ddb, TableName: "mock-table"}
+
+	local.objects["imports/items.csv"] = localObject{body: []byte(strings.Join([]string{
+		"\ufeffname,quantity,tags,id",
+		"valid,2,\"a,b\",",
+		"invalid quantity,-1,,",
+		"invalid tag,1,a b,",
+		"short record",
+		"kept id,1,,test-item-id",
+		"",
+	}, "\n"))}
+
+	assert := assert.New(t)
+	report, err := export.Import(r, "imports/items.csv")
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal(2, report.Imported, "Valid rows")
+	assert.Equal(3, report.Failed, "Invalid rows")
+	if assert.Len(report.Errors, 3) {
+		assert.Equal([]int{3, 4, 5}, []int{report.Errors[0].Row, report.Errors[1].Row, report.Errors[2].Row}, "Rows")
+	}
+	assert.Contains(ddb.tables["mock-table"], "test-item-id", "Imported with the ID")
+	var stored ImportReport
+	if assert.NoError(json.Unmarshal(local.objects["reports/imports/items.csv.json"].body, &stored), "Stored report") {
+		assert.Equal(*report, stored)
+	}
+
+	local.objects["imports/items.ndjson"] = localObject{body: []byte("{\"name\":\"valid\"}\n\n{\"name\":\n{\"name\":\"expired\",\"expiresAt\":\"2020-01-01T00:00:00Z\"}\n")}
+	if report, err = export.Import(r, "imports/items.ndjson"); assert.NoError(err) {
+		assert.Equal(1, report.Imported, "Valid lines")
+		assert.Equal(2, report.Failed, "Invalid lines")
+		assert.Equal(3, report.Errors[0].Row, "Line number")
+	}
+
+	local.objects["imports/unknown.csv"] = localObject{body: []byte("name,color\nred widget,red\n")}
+	if report, err = export.Import(r, "imports/unknown.csv"); assert.NoError(err) && assert.Len(report.Errors, 1) {
+		assert.Equal(0, report.Errors[0].Row, "Invalid file")
+	}
+
+	if report, err = export.Import(r, "imports/items.xml"); assert.NoError(err) {
+		assert.Equal(ErrFormat.Error(), report.Errors[0].Message)
+	}
+
+	_, err = export.Import(r, "imports/missing.csv")
+	assert.Error(err, "Missing file")
+}
@@ -0,0 +1,577 @@
+package sample
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+)
+
+// Reservation statuses
+const (
+	ReservationPending   = "pending"   // stock is held until committed, released or expired
+	ReservationCommitted = "committed" // held stock is consumed
+	ReservationReleased  = "released"  // held stock is returned
+)
+
+// IndexPendingReservations is a sparse index of pending reservations by status (partition) and expiry (sort)
+const IndexPendingReservations = "pending-index"
+
+// Inventory errors
+var (
+	ErrInsufficientQuantity = errors.New("Insufficient quantity")
+	ErrReservationSettled   = errors.New("Reservation is already committed or released")
+	ErrReservationExpired   = errors.New("Reservation has expired")
+)
+
+// Reservation holds a quantity of an item for a pending order
+type Reservation struct {
+	ID        string     `json:"id"`
+	ItemID    string     `json:"itemId"`
+	Quantity  int        `json:"quantity"`
+	Status    string     `json:"status"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Expiry    int64      `json:"-" dynamodbav:"expiry,omitempty"` // Unix seconds of ExpiresAt, indexed while pending
+	TTL       int64      `json:"-" dynamodbav:"ttl,omitempty"`    // purge time of settled reservations (Unix seconds)
+}
+
+// Expired reports whether a pending reservation is past its expiry
+func (res Reservation) Expired(now time.Time) bool {
+	return res.Status == ReservationPending && res.ExpiresAt != nil && !now.Before(*res.ExpiresAt)
+}
+
+// Placeholders of inventory attributes (some names are reserved words)
+var inventoryNames = map[string]*string{
+	"#details":  aws.String("details"),
+	"#quantity": aws.String("quantity"),
+	"#reserved": aws.String("reserved"),
+	"#version":  aws.String("version"),
+}
+
+// AdjustQuantity atomically adds a signed delta to the item quantity, which never goes negative.
+// The version is incremented, so concurrent updates of the whole item are rejected.
+// Versioned resources record the adjusted state in the version history by the same transaction.
+func (r *Repo) AdjustQuantity(itemID string, delta int) (*Item, error) {
+	if itemID == "" {
+		return nil, errors.New("Missing resource ID")
+	}
+
+	// prepare query data
+	now := time.Now()
+	updatedAt, err := dynamodbattribute.Marshal(now)
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	condition := "attribute_exists(id) AND attribute_not_exists(deletedAt)"
+	values := map[string]*dynamodb.AttributeValue{
+		":updatedAt": updatedAt,
+		":delta":     {N: aws.String(strconv.Itoa(delta))},
+		":one":       {N: aws.String("1")},
+	}
+	if delta < 0 {
+		condition += " AND #details.#quantity >= :need"
+		values[":need"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(-delta))}
+	}
+	names := map[string]*string{
+		"#details":  inventoryNames["#details"],
+		"#quantity": inventoryNames["#quantity"],
+		"#version":  inventoryNames["#version"],
+	}
+	update := "SET updatedAt = :updatedAt ADD #details.#quantity :delta, #version :one"
+
+	if r.VersionTableName == "" {
+		// execute query
+		item, err := r.update(itemID, update, condition, names, values)
+		if err == ErrNotFound {
+			return nil, r.shortage(itemID)
+		}
+		return item, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		rev, err := r.revise(itemID, now, false, func(item *Item) error {
+			if item.Details.Quantity+delta < 0 {
+				return ErrInsufficientQuantity
+			}
+			item.Details.Quantity += delta
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// execute transaction
+		err = r.record(r.Transact().
+			Update(r.TableName, itemKey(itemID), rev.expr(Expr{Update: update, Condition: condition, Names: names, Values: values})),
+			rev).
+			Commit()
+		if r.stale(err, rev) {
+			if attempt < maxModifyAttempts {
+				continue
+			}
+			return nil, ErrConflict
+		} else if err != nil {
+			log.Println(err.Error())
+			return nil, errors.New("Failed to update item in the repository")
+		}
+		return rev.item, nil
+	}
+}
+
+// Returns the reason of a failed stock condition: a missing item or insufficient quantity
+func (r *Repo) shortage(itemID string) error {
+	if _, err := r.Get(itemID); err != nil {
+		return err
+	}
+	return ErrInsufficientQuantity
+}
+
+// revision is the state of an item after a stock movement, recorded in the version history by the
+// transaction of the movement. The transaction expects the version read before the movement.
+type revision struct {
+	item      *Item                               // moved item (nil if versioning is disabled)
+	av        map[string]*dynamodb.AttributeValue // stored state of the moved item
+	record    map[string]*dynamodb.AttributeValue // version record of the moved item
+	condition string                              // expects the read version
+	version   *dynamodb.AttributeValue
+}
+
+// Reads the item and applies the stock movement to record its next version.
+// The revision is empty if versioning is disabled, so the movement expects no version.
+func (r *Repo) revise(itemID string, now time.Time, includeDeleted bool, move func(item *Item) error) (*revision, error) {
+	if r.VersionTableName == "" {
+		return &revision{}, nil
+	}
+	item, err := r.Lookup(itemID, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+	if err = move(item); err != nil {
+		return nil, err
+	}
+
+	// expect the read version (missing for items created before versioning)
+	rev := &revision{item: item, condition: "attribute_not_exists(#version)"}
+	if item.Version > 0 {
+		rev.condition = "#version = :version"
+		rev.version = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(item.Version))}
+	}
+	item.Version++
+	item.UpdatedAt = &now
+	if rev.av, rev.record, err = r.marshal(*item); err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// Returns the expression of the item movement expecting the read version
+func (rev *revision) expr(e Expr) Expr {
+	if rev.item == nil {
+		return e
+	}
+	e.Condition += " AND " + rev.condition
+	if rev.version != nil {
+		values := map[string]*dynamodb.AttributeValue{":version": rev.version}
+		for placeholder, value := range e.Values {
+			values[placeholder] = value
+		}
+		e.Values = values
+	}
+	return e
+}
+
+// Adds version records of the revisions to the transaction of their movement
+func (r *Repo) record(t *Transaction, revs ...*revision) *Transaction {
+	for _, rev := range revs {
+		if rev.record != nil {
+			t.Put(r.VersionTableName, rev.record, Expr{Condition: "attribute_not_exists(version)"})
+		}
+	}
+	return t
+}
+
+// Reports whether a movement of versioned items failed on a condition, so it is retried from a fresh read.
+// Payloads offloaded for version records of the failed movement are deleted.
+func (r *Repo) stale(err error, revs ...*revision) bool {
+	if !conditionFailed(err) {
+		return false
+	}
+	versioned := false
+	for _, rev := range revs {
+		if rev.item != nil {
+			r.discard(rev.av)
+			versioned = true
+		}
+	}
+	return versioned
+}
+
+// Reserve holds a quantity of the item until the reservation is committed, released or expires.
+// The held quantity is moved from the item quantity to its reserved quantity.
+// Versioned resources record the moved stock in the version history by the same transaction.
+func (r *Repo) Reserve(itemID string, quantity int, ttl time.Duration) (*Reservation, error) {
+	if itemID == "" {
+		return nil, errors.New("Missing resource ID")
+	}
+	if quantity <= 0 {
+		return nil, errors.New("Reserved quantity must be positive")
+	}
+	if r.ReservationTableName == "" {
+		return nil, errors.New("Reservations are not enabled")
+	}
+
+	// prepare query data
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	res := Reservation{
+		ID:        uuid.New().String(),
+		ItemID:    itemID,
+		Quantity:  quantity,
+		Status:    ReservationPending,
+		CreatedAt: &now,
+		UpdatedAt: &now,
+		ExpiresAt: &expiresAt,
+		Expiry:    expiresAt.Unix(),
+	}
+	av, err := dynamodbattribute.MarshalMap(res)
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	values, err := stockValues(now, quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		rev, err := r.revise(itemID, now, false, func(item *Item) error {
+			if item.Details.Quantity < quantity {
+				return ErrInsufficientQuantity
+			}
+			item.Details.Quantity -= quantity
+			item.Details.Reserved += quantity
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// execute transaction
+		err = r.record(r.Transact().
+			Update(r.TableName, itemKey(itemID), rev.expr(Expr{
+				Update:    "SET updatedAt = :updatedAt ADD #details.#quantity :minus, #details.#reserved :plus, #version :one",
+				Condition: "attribute_exists(id) AND attribute_not_exists(deletedAt) AND #details.#quantity >= :plus",
+				Names:     inventoryNames,
+				Values:    values,
+			})).
+			Put(r.ReservationTableName, av, Expr{Condition: "attribute_not_exists(id)"}),
+			rev).
+			Commit()
+		var txErr *TransactionError
+		if r.stale(err, rev) {
+			if attempt < maxModifyAttempts {
+				continue
+			}
+			return nil, ErrConflict
+		} else if errors.As(err, &txErr) && txErr.ConditionFailed(0) {
+			return nil, r.shortage(itemID)
+		} else if err != nil {
+			log.Println(err.Error())
+			return nil, errors.New("Failed to reserve item quantity")
+		}
+		return &res, nil
+	}
+}
+
+// Returns values of a stock movement of the quantity
+func stockValues(now time.Time, quantity int) (map[string]*dynamodb.AttributeValue, error) {
+	updatedAt, err := dynamodbattribute.Marshal(now)
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	return map[string]*dynamodb.AttributeValue{
+		":updatedAt": updatedAt,
+		":plus":      {N: aws.String(strconv.Itoa(quantity))},
+		":minus":     {N: aws.String(strconv.Itoa(-quantity))},
+		":one":       {N: aws.String("1")},
+	}, nil
+}
+
+// Reservation gets a reservation by ID
+func (r *Repo) Reservation(reservationID string) (*Reservation, error) {
+	if reservationID == "" {
+		return nil, errors.New("Missing reservation ID")
+	}
+	if r.ReservationTableName == "" {
+		return nil, errors.New("Reservations are not enabled")
+	}
+
+	// prepare query data
+	input := &dynamodb.GetItemInput{
+		TableName: &r.ReservationTableName,
+		Key:       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(reservationID)}},
+	}
+
+	// execute query
+	var res *dynamodb.GetItemOutput
+	err := r.Retry.Do(r.context(), func() (err error) {
+		res, err = r.Client.GetItem(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to read reservation")
+	} else if res.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	// process query results
+	var reservation Reservation
+	if err = dynamodbattribute.UnmarshalMap(res.Item, &reservation); err != nil {
+		log.Println("Failed to unmarshal:", err.Error())
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// Commit consumes the reserved quantity of a pending reservation before it expires
+func (r *Repo) Commit(reservationID string) (*Reservation, error) {
+	return r.settle(reservationID, ReservationCommitted, "ADD #details.#reserved :minus, #version :one")
+}
+
+// Release returns the reserved quantity of a pending (or expired) reservation to the item
+func (r *Repo) Release(reservationID string) (*Reservation, error) {
+	return r.settle(reservationID, ReservationReleased, "ADD #details.#quantity :plus, #details.#reserved :minus, #version :one")
+}
+
+// Settles a pending reservation with the status and moves its quantity by the item update
+func (r *Repo) settle(reservationID, status, update string) (*Reservation, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := r.Reservation(reservationID)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		if res.Status != ReservationPending {
+			return nil, ErrReservationSettled
+		} else if status == ReservationCommitted && res.Expired(now) {
+			return nil, ErrReservationExpired
+		}
+		rev, err := r.revise(res.ItemID, now, true, func(item *Item) error {
+			if status == ReservationReleased {
+				item.Details.Quantity += res.Quantity
+			}
+			item.Details.Reserved -= res.Quantity
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// prepare query data
+		values, err := stockValues(now, res.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		resUpdate := "SET #status = :status, updatedAt = :updatedAt REMOVE expiry"
+		resCondition := "#status = :pending"
+		resValues := map[string]*dynamodb.AttributeValue{
+			":status":    {S: aws.String(status)},
+			":pending":   {S: aws.String(ReservationPending)},
+			":updatedAt": values[":updatedAt"],
+		}
+		resNames := map[string]*string{"#status": aws.String("status")}
+		if status == ReservationCommitted {
+			resCondition += " AND expiry > :now"
+			resValues[":now"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Unix(), 10))}
+		}
+		if r.Retention > 0 { // settled reservations are kept for the retention period
+			resUpdate = "SET #status = :status, updatedAt = :updatedAt, #ttl = :ttl REMOVE expiry"
+			resNames["#ttl"] = aws.String("ttl")
+			resValues[":ttl"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Add(r.Retention).Unix(), 10))}
+		}
+
+		// execute transaction
+		err = r.record(r.Transact().
+			Update(r.ReservationTableName, itemKey(reservationID), Expr{Update: resUpdate, Condition: resCondition, Names: resNames, Values: resValues}).
+			Update(r.TableName, itemKey(res.ItemID), rev.expr(Expr{
+				Update:    "SET updatedAt = :updatedAt " + update,
+				Condition: "attribute_exists(id)",
+				Names:     inventoryNames,
+				Values:    values,
+			})),
+			rev).
+			Commit()
+		var txErr *TransactionError
+		if r.stale(err, rev) { // the reservation is read again, it may be settled or expired concurrently
+			if attempt < maxModifyAttempts {
+				continue
+			}
+			return nil, ErrConflict
+		} else if errors.As(err, &txErr) && txErr.ConditionFailed(0) {
+			// settled or expired concurrently
+			return nil, ErrReservationSettled
+		} else if errors.As(err, &txErr) && txErr.ConditionFailed(1) {
+			// item purged
+			return nil, ErrNotFound
+		} else if err != nil {
+			log.Println(err.Error())
+			return nil, errors.New("Failed to settle reservation")
+		}
+
+		res.Status = status
+		res.UpdatedAt = &now
+		res.Expiry = 0
+		return res, nil
+	}
+}
+
+// ReleaseExpired releases pending reservations expired before the time and returns their number.
+// Reservations settled concurrently are skipped, as are reservations of items changed concurrently.
+func (r *Repo) ReleaseExpired(now time.Time) (int, error) {
+	if r.ReservationTableName == "" {
+		return 0, errors.New("Reservations are not enabled")
+	}
+
+	// prepare query data
+	input := &dynamodb.QueryInput{
+		TableName:              &r.ReservationTableName,
+		IndexName:              aws.String(IndexPendingReservations),
+		KeyConditionExpression: aws.String("#status = :pending AND expiry <= :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pending": {S: aws.String(ReservationPending)},
+			":now":     {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	}
+
+	released := 0
+	for {
+		// execute query
+		var res *dynamodb.QueryOutput
+		err := r.Retry.Do(r.context(), func() (err error) {
+			res, err = r.Client.Query(input)
+			return
+		})
+		if err != nil {
+			log.Println(err.Error())
+			return released, errors.New("Failed to read expired reservations")
+		}
+
+		// process query results
+		var expired []Reservation
+		if err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &expired); err != nil {
+			log.Println("Failed to unmarshal:", err.Error())
+			return released, err
+		}
+		for _, reservation := range expired {
+			if _, err := r.Release(reservation.ID); err == ErrReservationSettled || err == ErrNotFound || err == ErrConflict {
+				continue
+			} else if err != nil {
+				return released, err
+			}
+			released++
+		}
+
+		if len(res.LastEvaluatedKey) == 0 {
+			return released, nil
+		}
+		input.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+}
+
+// Transfer moves a quantity of stock from one item to another (e.g. between locations)
+type Transfer struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Quantity int    `json:"quantity"`
+}
+
+// ErrTransferTarget is returned when the destination item of a transfer doesn't exist
+var ErrTransferTarget = errors.New("Transfer destination not found")
+
+// Transfer atomically moves the quantity between items, the source quantity never goes negative.
+// Versioned resources record both moved states in the version history by the same transaction.
+func (r *Repo) Transfer(t Transfer) error {
+	if t.From == "" || t.To == "" {
+		return errors.New("Missing resource ID")
+	}
+	if t.From == t.To {
+		return errors.New("Transfer source and destination must differ")
+	}
+	if t.Quantity <= 0 {
+		return errors.New("Transferred quantity must be positive")
+	}
+
+	// prepare query data
+	now := time.Now()
+	values, err := stockValues(now, t.Quantity)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		from, err := r.revise(t.From, now, false, func(item *Item) error {
+			if item.Details.Quantity < t.Quantity {
+				return ErrInsufficientQuantity
+			}
+			item.Details.Quantity -= t.Quantity
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		to, err := r.revise(t.To, now, false, func(item *Item) error {
+			item.Details.Quantity += t.Quantity
+			return nil
+		})
+		if err == ErrNotFound {
+			return ErrTransferTarget
+		} else if err != nil {
+			return err
+		}
+
+		// execute transaction
+		err = r.record(r.Transact().
+			Update(r.TableName, itemKey(t.From), from.expr(Expr{
+				Update:    "SET updatedAt = :updatedAt ADD #details.#quantity :minus, #version :one",
+				Condition: "attribute_exists(id) AND attribute_not_exists(deletedAt) AND #details.#quantity >= :plus",
+				Names:     inventoryNames,
+				Values:    values,
+			})).
+			Update(r.TableName, itemKey(t.To), to.expr(Expr{
+				Update:    "SET updatedAt = :updatedAt ADD #details.#quantity :plus, #version :one",
+				Condition: "attribute_exists(id) AND attribute_not_exists(deletedAt)",
+				Names:     inventoryNames,
+				Values:    values,
+			})),
+			from, to).
+			Commit()
+		var txErr *TransactionError
+		if r.stale(err, from, to) {
+			if attempt < maxModifyAttempts {
+				continue
+			}
+			return ErrConflict
+		} else if errors.As(err, &txErr) && txErr.ConditionFailed(0) {
+			return r.shortage(t.From)
+		} else if errors.As(err, &txErr) && txErr.ConditionFailed(1) {
+			return ErrTransferTarget
+		} else if err != nil {
+			log.Println(err.Error())
+			return errors.New("Failed to transfer item quantity")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,206 @@
+package sample
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client keeping an item and reservations, failing conditions on request
+type mockDdbInventory struct {
+	mockDdbUpdates
+	reservations map[string]Reservation
+	transact     *dynamodb.TransactWriteItemsInput
+	cancel       bool
+}
+
+func (mock *mockDdbInventory) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if aws.StringValue(input.TableName) != "mock-reservations" {
+		return mock.mockDdb.GetItem(input)
+	}
+	output := new(dynamodb.GetItemOutput)
+	if res, ok := mock.reservations[*input.Key["id"].S]; ok {
+		output.Item, _ = dynamodbattribute.MarshalMap(res)
+	}
+	return output, nil
+}
+
+func (mock *mockDdbInventory) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	if mock.cancel {
+		mock.input = input
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "Mock condition failure", nil)
+	}
+	return mock.mockDdbUpdates.UpdateItem(input)
+}
+
+func (mock *mockDdbInventory) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	mock.transact = input
+	if mock.cancel {
+		return nil, &dynamodb.TransactionCanceledException{
+			Message_:            aws.String("Mock condition failure"),
+			CancellationReasons: []*dynamodb.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}},
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (mock *mockDdbInventory) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	output := new(dynamodb.QueryOutput)
+	for _, res := range mock.reservations {
+		if res.Status == ReservationPending {
+			av, _ := dynamodbattribute.MarshalMap(res)
+			output.Items = append(output.Items, av)
+		}
+	}
+	return output, nil
+}
+
+func TestRepo_AdjustQuantity(t *testing.T) {
+	assert := assert.New(t)
+	client := &mockDdbInventory{mockDdbUpdates: mockDdbUpdates{mockDdb: mockDdb{item: &Item{ID: "test-item-id", Details: Details{Quantity: 3}}}}}
+	r := &Repo{Client: client, TableName: "mock-table"}
+
+	_, err := r.AdjustQuantity("test-item-id", 5)
+	if assert.NoError(err, "Increase") {
+		assert.Contains(*client.input.UpdateExpression, "ADD #details.#quantity :delta", "Atomic adjustment")
+		assert.NotContains(*client.input.ConditionExpression, ":need", "No stock condition")
+	}
+
+	_, err = r.AdjustQuantity("test-item-id", -2)
+	if assert.NoError(err, "Decrease") {
+		assert.Contains(*client.input.ConditionExpression, "#details.#quantity >= :need", "Stock condition")
+		assert.Equal("2", *client.input.ExpressionAttributeValues[":need"].N)
+		assert.Equal("-2", *client.input.ExpressionAttributeValues[":delta"].N)
+	}
+
+	client.cancel = true
+	_, err = r.AdjustQuantity("test-item-id", -10)
+	assert.Equal(ErrInsufficientQuantity, err, "Never negative")
+
+	client.item = nil
+	_, err = r.AdjustQuantity("test-item-id", 1)
+	assert.Equal(ErrNotFound, err, "Missing item")
+}
+
+func TestRepo_AdjustQuantityVersioned(t *testing.T) {
+	client := newMockVersionedDdb()
+	r := &Repo{Client: client, TableName: "mock-table", VersionTableName: "mock-version-table"}
+
+	assert := assert.New(t)
+	item, err := r.Save(Item{Name: "test-item-name", Details: Details{Quantity: 3}})
+	if !assert.NoError(err, "Save") {
+		return
+	}
+
+	got, err := r.AdjustQuantity(item.ID, -2)
+	if assert.NoError(err, "Decrease") {
+		assert.Equal(1, got.Details.Quantity, "Adjusted quantity")
+		assert.Equal(2, got.Version, "Next version")
+		assert.Len(client.versions[item.ID], 2, "Adjustment recorded in the version history")
+	}
+
+	_, err = r.AdjustQuantity(item.ID, -2)
+	assert.Equal(ErrInsufficientQuantity, err, "Never negative")
+	assert.Len(client.versions[item.ID], 2, "No version of a rejected adjustment")
+
+	_, err = r.AdjustQuantity("missing", 1)
+	assert.Equal(ErrNotFound, err, "Missing item")
+}
+
+func TestRepo_Reservations(t *testing.T) {
+	assert := assert.New(t)
+	past := time.Now().Add(-time.Minute)
+	client := &mockDdbInventory{
+		mockDdbUpdates: mockDdbUpdates{mockDdb: mockDdb{item: &Item{ID: "test-item-id", Details: Details{Quantity: 3}}}},
+		reservations: map[string]Reservation{
+			"expired":   {ID: "expired", ItemID: "test-item-id", Quantity: 1, Status: ReservationPending, ExpiresAt: &past, Expiry: past.Unix()},
+			"committed": {ID: "committed", ItemID: "test-item-id", Quantity: 1, Status: ReservationCommitted},
+		},
+	}
+	r := &Repo{Client: client, TableName: "mock-table", ReservationTableName: "mock-reservations", Retention: time.Hour}
+
+	res, err := r.Reserve("test-item-id", 2, 15*time.Minute)
+	if assert.NoError(err, "Reserve") {
+		assert.Equal(ReservationPending, res.Status)
+		assert.Equal(res.ExpiresAt.Unix(), res.Expiry, "Indexed expiry")
+		update := client.transact.TransactItems[0].Update
+		assert.Equal("attribute_exists(id) AND attribute_not_exists(deletedAt) AND #details.#quantity >= :plus", *update.ConditionExpression)
+		assert.Equal("2", *update.ExpressionAttributeValues[":plus"].N)
+		assert.Equal("mock-reservations", *client.transact.TransactItems[1].Put.TableName)
+	}
+	_, err = r.Reserve("test-item-id", 0, time.Minute)
+	assert.Error(err, "Non-positive quantity")
+
+	_, err = r.Commit("expired")
+	assert.Equal(ErrReservationExpired, err, "Commit after expiry")
+	_, err = r.Release("committed")
+	assert.Equal(ErrReservationSettled, err, "Release after commit")
+	_, err = r.Commit("missing")
+	assert.Equal(ErrNotFound, err, "Missing reservation")
+
+	res, err = r.Release("expired")
+	if assert.NoError(err, "Release after expiry") {
+		assert.Equal(ReservationReleased, res.Status)
+		update := client.transact.TransactItems[1].Update
+		assert.Contains(*update.UpdateExpression, "ADD #details.#quantity :plus, #details.#reserved :minus", "Stock returned")
+		assert.Contains(*client.transact.TransactItems[0].Update.UpdateExpression, "#ttl = :ttl", "Purge scheduled")
+	}
+
+	n, err := r.ReleaseExpired(time.Now())
+	assert.NoError(err, "Release expired")
+	assert.Equal(1, n, "Pending reservations released")
+
+	client.cancel = true
+	_, err = r.Reserve("test-item-id", 5, time.Minute)
+	assert.Equal(ErrInsufficientQuantity, err, "Not enough stock")
+	_, err = r.Release("expired")
+	assert.Equal(ErrReservationSettled, err, "Settled concurrently")
+}
+
+func TestRepo_ReservationsVersioned(t *testing.T) {
+	assert := assert.New(t)
+	client := &mockDdbInventory{
+		mockDdbUpdates: mockDdbUpdates{mockDdb: mockDdb{item: &Item{ID: "test-item-id", Version: 4, Details: Details{Quantity: 3, Reserved: 1}}}},
+		reservations: map[string]Reservation{
+			"pending": {ID: "pending", ItemID: "test-item-id", Quantity: 1, Status: ReservationPending},
+		},
+	}
+	r := &Repo{Client: client, TableName: "mock-table", ReservationTableName: "mock-reservations", VersionTableName: "mock-version-table"}
+
+	_, err := r.Reserve("test-item-id", 2, 15*time.Minute)
+	if assert.NoError(err, "Reserve") && assert.Len(client.transact.TransactItems, 3, "Version record") {
+		update := client.transact.TransactItems[0].Update
+		assert.Contains(*update.ConditionExpression, "#version = :version", "Read version expected")
+		assert.Equal("4", *update.ExpressionAttributeValues[":version"].N)
+		put := client.transact.TransactItems[2].Put
+		assert.Equal("mock-version-table", *put.TableName)
+		var v ItemVersion
+		if assert.NoError(dynamodbattribute.UnmarshalMap(put.Item, &v)) {
+			assert.Equal(5, v.Version, "Next version")
+			assert.Equal(1, v.Item.Details.Quantity, "Held quantity moved")
+			assert.Equal(3, v.Item.Details.Reserved, "Reserved quantity")
+		}
+	}
+
+	_, err = r.Commit("pending")
+	if assert.NoError(err, "Commit") && assert.Len(client.transact.TransactItems, 3, "Version record") {
+		var v ItemVersion
+		if assert.NoError(dynamodbattribute.UnmarshalMap(client.transact.TransactItems[2].Put.Item, &v)) {
+			assert.Equal(5, v.Version, "Next version")
+			assert.Equal(3, v.Item.Details.Quantity, "Quantity kept")
+			assert.Equal(0, v.Item.Details.Reserved, "Reserved quantity consumed")
+		}
+	}
+
+	_, err = r.Reserve("test-item-id", 5, time.Minute)
+	assert.Equal(ErrInsufficientQuantity, err, "Not enough stock")
+
+	client.cancel = true
+	_, err = r.Reserve("test-item-id", 1, time.Minute)
+	assert.Equal(ErrConflict, err, "Version changed concurrently")
+}
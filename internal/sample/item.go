@@ -4,16 +4,41 @@ import "time"
 
 // Item structure
 type Item struct {
-	ID        string     `json:"id,omitempty"`
-	Name      string     `json:"name,omitempty"`
-	CreatedAt *time.Time `json:"createdAt,omitempty"`
-	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
-	Details   Details    `json:"details,omitempty"`
+	ID            string                 `json:"id,omitempty"`
+	Name          string                 `json:"name,omitempty"`
+	CreatedAt     *time.Time             `json:"createdAt,omitempty"`
+	UpdatedAt     *time.Time             `json:"updatedAt,omitempty"`
+	Version       int                    `json:"version,omitempty"`
+	ExpiresAt     *time.Time             `json:"expiresAt,omitempty"`          // temporary items disappear after
+	DeletedAt     *time.Time             `json:"deletedAt,omitempty"`          // tombstone of a soft deleted item
+	TTL           int64                  `json:"-" dynamodbav:"ttl,omitempty"` // purge time of DynamoDB TTL (Unix seconds)
+	Details       Details                `json:"details,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`                                 // custom attributes (see ValidateAttributes)
+	Tags          []string               `json:"tags,omitempty" dynamodbav:"tags,stringset,omitempty"` // labels stored as a string set
+	Attachments   []Attachment           `json:"attachments,omitempty"`                                // files stored in S3
+	Payload       *PayloadRef            `json:"-" dynamodbav:"payload,omitempty"`                     // claim check of content offloaded to S3
+	Encryption    *EncryptedKey          `json:"-" dynamodbav:"encryption,omitempty"`                  // data key of fields tagged by `encrypt:"true"`
+	SchemaVersion int                    `json:"-" dynamodbav:"schemaVersion,omitempty"`               // version of the stored item (see ItemMigrations)
+}
+
+// Expired reports whether the item is past its expiry or purge time,
+// DynamoDB TTL deletes such items eventually (typically within days).
+func (item Item) Expired(now time.Time) bool {
+	return item.ExpiresAt != nil && !now.Before(*item.ExpiresAt) || item.TTL > 0 && item.TTL <= now.Unix()
+}
+
+// Returns the TTL of the expiry (zero if the item doesn't expire)
+func (item Item) expiryTTL() int64 {
+	if item.ExpiresAt == nil {
+		return 0
+	}
+	return item.ExpiresAt.Unix()
 }
 
 // Details of the item
 type Details struct {
 	Description string `json:"description,omitempty"`
-	Location    string `json:"location,omitempty"`
-	Quantity    int    `json:"quantity,omitempty"`
+	Location    string `json:"location,omitempty" encrypt:"true"` // sensitive (e.g. location of high-value stock)
+	Quantity    int    `json:"quantity,omitempty"`                // available quantity
+	Reserved    int    `json:"reserved,omitempty"`                // quantity held by pending reservations
 }
@@ -0,0 +1,305 @@
+package sample
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Migration upgrades a stored item of the previous schema version to its version.
+// Items are migrated as stored, so encrypted and offloaded fields are kept as they are.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(av map[string]*dynamodb.AttributeValue) error
+}
+
+// Migrations are ordered by their versions starting with 2, items stored without a schema version
+// have the version 1 (the schema before migrations)
+type Migrations []Migration
+
+// ItemMigrations is the registry of item schema migrations, new ones are appended with the next version
+var ItemMigrations = Migrations{
+	{Version: 2, Description: "Add index attributes of items saved before secondary indexes", Up: addIndexAttributes},
+	{Version: 3, Description: "Spread items of the name index across shards", Up: shardNameIndex},
+}
+
+// Latest returns the schema version of items migrated by all migrations
+func (m Migrations) Latest() int {
+	if len(m) == 0 {
+		return 1
+	}
+	return m[len(m)-1].Version
+}
+
+// Validate checks the migrations are ordered by consecutive versions
+func (m Migrations) Validate() error {
+	for i, migration := range m {
+		if migration.Version != i+2 {
+			return fmt.Errorf("Migration %q has version %d, expected %d", migration.Description, migration.Version, i+2)
+		}
+		if migration.Up == nil {
+			return fmt.Errorf("Migration %q has no function", migration.Description)
+		}
+	}
+	return nil
+}
+
+// Upgrade applies migrations newer than the schema version of the stored item in order,
+// reports whether any was applied
+func (m Migrations) Upgrade(av map[string]*dynamodb.AttributeValue) (bool, error) {
+	if av == nil {
+		return false, nil
+	}
+	current := schemaVersion(av)
+	applied := false
+	for _, migration := range m {
+		if migration.Version <= current {
+			continue
+		}
+		if err := migration.Up(av); err != nil {
+			return applied, fmt.Errorf("Failed to migrate item to schema version %d: %v", migration.Version, err)
+		}
+		av["schemaVersion"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(migration.Version))}
+		applied = true
+	}
+	return applied, nil
+}
+
+// Returns the schema version of the stored item
+func schemaVersion(av map[string]*dynamodb.AttributeValue) int {
+	if v, ok := av["schemaVersion"]; ok && v.N != nil {
+		if n, err := strconv.Atoi(*v.N); err == nil {
+			return n
+		}
+	}
+	return 1
+}
+
+// Adds index attributes missing in items saved before secondary indexes, so lists find them
+func addIndexAttributes(av map[string]*dynamodb.AttributeValue) error {
+	details, ok := av["details"]
+	if !ok || details.M == nil {
+		details = &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{}}
+		av["details"] = details
+	}
+	if name, ok := av["name"]; !ok || aws.StringValue(name.S) == "" {
+		return nil
+	}
+	av["kind"] = &dynamodb.AttributeValue{S: aws.String(kindItem)}
+	if location, ok := details.M["location"]; ok && aws.StringValue(location.S) != "" &&
+		(av["encryption"] == nil || !encrypted("details.location")) { // ciphertext can't be queried
+		av["location"] = &dynamodb.AttributeValue{S: location.S}
+	}
+	return nil
+}
+
+// Moves items from the single partition of the name index to their shards
+func shardNameIndex(av map[string]*dynamodb.AttributeValue) error {
+	if kind, ok := av["kind"]; ok && aws.StringValue(kind.S) == kindItem {
+		av["kind"] = &dynamodb.AttributeValue{S: aws.String(nameShardKey(nameShard(aws.StringValue(av["id"].S))))}
+	}
+	return nil
+}
+
+// Returns the migrations of stored items
+func (r *Repo) migrations() Migrations {
+	if r.Migrations != nil {
+		return r.Migrations
+	}
+	return ItemMigrations
+}
+
+// Upgrades the stored item to the latest schema version (upgrade-on-read, the stored item is kept)
+func (r *Repo) upgrade(av map[string]*dynamodb.AttributeValue) error {
+	if _, err := r.migrations().Upgrade(av); err != nil {
+		log.Println(err.Error())
+		return errors.New("Failed to migrate item")
+	}
+	return nil
+}
+
+// Upgrades the item of a stored version record
+func (r *Repo) upgradeVersion(av map[string]*dynamodb.AttributeValue) error {
+	if item, ok := av["item"]; ok && item.M != nil {
+		return r.upgrade(item.M)
+	}
+	return nil
+}
+
+// BackfillOptions control a migration of all stored items
+type BackfillOptions struct {
+	Scan       ScanOptions                  // scan of the items table (soft deleted and expired items are always included)
+	DryRun     bool                         // count items to migrate without writing them
+	OnProgress func(BackfillProgress) error // receives the progress after every page (e.g. to report it and persist the checkpoint)
+}
+
+// BackfillProgress reports a migration of stored items
+type BackfillProgress struct {
+	Scanned    int            `json:"scanned"`
+	Migrated   int            `json:"migrated"` // items migrated (to be migrated by a dry run)
+	Skipped    int            `json:"skipped"`  // items changed concurrently, migrated by their next write
+	Checkpoint ScanCheckpoint `json:"checkpoint"`
+}
+
+// Backfill migrates stored items to the latest schema version. Only attributes changed by migrations
+// are written under the condition they were not changed since scanned, so concurrent writes are kept.
+// Item versions are immutable records upgraded on read only.
+func (r *Repo) Backfill(opts BackfillOptions) (BackfillProgress, error) {
+	var mu sync.Mutex
+	var progress BackfillProgress
+	if err := r.migrations().Validate(); err != nil {
+		return progress, err
+	}
+	scan := opts.Scan
+	scan.IncludeDeleted = true
+	scan.OnCheckpoint = func(c ScanCheckpoint) error {
+		mu.Lock()
+		progress.Checkpoint = c
+		current := progress
+		mu.Unlock()
+		if opts.OnProgress != nil {
+			return opts.OnProgress(current)
+		}
+		return nil
+	}
+
+	checkpoint, err := r.scan(scan, func(segment int, page []map[string]*dynamodb.AttributeValue) error {
+		for _, av := range page {
+			migrated, skipped, err := r.backfillItem(av, opts.DryRun)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			progress.Scanned++
+			if migrated {
+				progress.Migrated++
+			} else if skipped {
+				progress.Skipped++
+			}
+			mu.Unlock()
+		}
+		return nil
+	})
+	progress.Checkpoint = checkpoint
+	return progress, err
+}
+
+// Migrates a single stored item, reports whether it was migrated or skipped on a concurrent change
+func (r *Repo) backfillItem(av map[string]*dynamodb.AttributeValue, dryRun bool) (migrated, skipped bool, err error) {
+	original := copyAttributes(av) // migrations may change nested attributes in place
+	applied, err := r.migrations().Upgrade(av)
+	if err != nil {
+		log.Println(err.Error())
+		return false, false, fmt.Errorf("Failed to migrate item %v", aws.StringValue(av["id"].S))
+	}
+	if !applied || dryRun {
+		return applied, false, nil
+	}
+
+	// prepare query data, changed attributes are expected to be as scanned
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{}
+	var set, remove []string
+	conditions := []string{"attribute_exists(id)"}
+	for i, name := range changedAttributes(original, av) {
+		attr := fmt.Sprintf("#m%d", i)
+		names[attr] = aws.String(name)
+		if v, ok := av[name]; ok {
+			set = append(set, fmt.Sprintf("%s = :m%d", attr, i))
+			values[fmt.Sprintf(":m%d", i)] = v
+		} else {
+			remove = append(remove, attr)
+		}
+		if v, ok := original[name]; ok {
+			conditions = append(conditions, fmt.Sprintf("%s = :o%d", attr, i))
+			values[fmt.Sprintf(":o%d", i)] = v
+		} else {
+			conditions = append(conditions, fmt.Sprintf("attribute_not_exists(%s)", attr))
+		}
+	}
+	var update []string
+	if len(set) > 0 {
+		update = append(update, "SET "+strings.Join(set, ", "))
+	}
+	if len(remove) > 0 {
+		update = append(update, "REMOVE "+strings.Join(remove, ", "))
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 &r.TableName,
+		Key:                       map[string]*dynamodb.AttributeValue{"id": av["id"]},
+		UpdateExpression:          aws.String(strings.Join(update, " ")),
+		ConditionExpression:       aws.String(strings.Join(conditions, " AND ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	if len(values) == 0 {
+		input.ExpressionAttributeValues = nil
+	}
+
+	// execute query
+	err = r.Retry.Do(r.context(), func() (err error) {
+		_, err = r.Client.UpdateItem(input)
+		return
+	})
+	if conditionFailed(err) {
+		return false, true, nil
+	} else if err != nil {
+		log.Println(err.Error())
+		return false, false, errors.New("Failed to save into the repository")
+	}
+	return true, false, nil
+}
+
+// Returns names of top-level attributes added, changed or removed by migrations in order
+func changedAttributes(before, after map[string]*dynamodb.AttributeValue) []string {
+	var names []string
+	for name, v := range after {
+		if old, ok := before[name]; !ok || !reflect.DeepEqual(old, v) {
+			names = append(names, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns a deep copy of stored attributes
+func copyAttributes(av map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	if av == nil {
+		return nil
+	}
+	c := make(map[string]*dynamodb.AttributeValue, len(av))
+	for name, v := range av {
+		c[name] = copyAttribute(v)
+	}
+	return c
+}
+
+// Returns a deep copy of a stored attribute value
+func copyAttribute(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if v == nil {
+		return nil
+	}
+	c := *v
+	c.M = copyAttributes(v.M)
+	if v.L != nil {
+		c.L = make([]*dynamodb.AttributeValue, len(v.L))
+		for i, e := range v.L {
+			c.L[i] = copyAttribute(e)
+		}
+	}
+	return &c
+}
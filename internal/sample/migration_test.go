@@ -0,0 +1,272 @@
+package sample
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client of stored items recording updates, items with the ID of conflict fail conditions
+type mockDdbStored struct {
+	mockDdb
+	stored   []map[string]*dynamodb.AttributeValue
+	conflict string
+	mu       sync.Mutex
+	updates  []*dynamodb.UpdateItemInput
+}
+
+func (mock *mockDdbStored) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	for _, av := range mock.stored {
+		if *av["id"].S == *input.Key["id"].S {
+			return &dynamodb.GetItemOutput{Item: copyAttributes(av)}, nil
+		}
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (mock *mockDdbStored) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	output := &dynamodb.ScanOutput{}
+	for i, av := range mock.stored {
+		if int64(i)%*input.TotalSegments == *input.Segment {
+			output.Items = append(output.Items, copyAttributes(av))
+		}
+	}
+	return output, nil
+}
+
+func (mock *mockDdbStored) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if *input.Key["id"].S == mock.conflict {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "Mock condition failure", nil)
+	}
+	mock.updates = append(mock.updates, input)
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// Returns stored attributes of an item saved before schema versions
+func legacyItem(id, name, location string) map[string]*dynamodb.AttributeValue {
+	av := map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}}
+	if name != "" {
+		av["name"] = &dynamodb.AttributeValue{S: aws.String(name)}
+	}
+	if location != "" {
+		av["details"] = &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+			"location": {S: aws.String(location)},
+		}}
+	}
+	return av
+}
+
+func TestMigrations_Upgrade(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations Migrations
+		stored     map[string]*dynamodb.AttributeValue
+		applied    bool
+		expected   map[string]*dynamodb.AttributeValue
+		err        bool
+	}{
+		{
+			name:       "named item",
+			migrations: ItemMigrations,
+			stored:     legacyItem("first", "test-item-name", "A1"),
+			applied:    true,
+			expected: map[string]*dynamodb.AttributeValue{
+				"id":            {S: aws.String("first")},
+				"name":          {S: aws.String("test-item-name")},
+				"details":       {M: map[string]*dynamodb.AttributeValue{"location": {S: aws.String("A1")}}},
+				"kind":          {S: aws.String(nameShardKey(nameShard("first")))},
+				"location":      {S: aws.String("A1")},
+				"schemaVersion": {N: aws.String("3")},
+			},
+		},
+		{
+			name:       "unnamed item",
+			migrations: ItemMigrations,
+			stored:     legacyItem("first", "", ""),
+			applied:    true,
+			expected: map[string]*dynamodb.AttributeValue{
+				"id":            {S: aws.String("first")},
+				"details":       {M: map[string]*dynamodb.AttributeValue{}},
+				"schemaVersion": {N: aws.String("3")},
+			},
+		},
+		{
+			name:       "current item",
+			migrations: ItemMigrations,
+			stored: map[string]*dynamodb.AttributeValue{
+				"id":            {S: aws.String("first")},
+				"schemaVersion": {N: aws.String("3")},
+			},
+			expected: map[string]*dynamodb.AttributeValue{
+				"id":            {S: aws.String("first")},
+				"schemaVersion": {N: aws.String("3")},
+			},
+		},
+		{
+			name: "newer migrations only",
+			migrations: Migrations{
+				{Version: 2, Up: func(av map[string]*dynamodb.AttributeValue) error {
+					return errors.New("Mock migration applied again")
+				}},
+				{Version: 3, Up: func(av map[string]*dynamodb.AttributeValue) error {
+					av["name"] = &dynamodb.AttributeValue{S: aws.String("renamed")}
+					return nil
+				}},
+			},
+			stored: map[string]*dynamodb.AttributeValue{
+				"id":            {S: aws.String("first")},
+				"schemaVersion": {N: aws.String("2")},
+			},
+			applied: true,
+			expected: map[string]*dynamodb.AttributeValue{
+				"id":            {S: aws.String("first")},
+				"name":          {S: aws.String("renamed")},
+				"schemaVersion": {N: aws.String("3")},
+			},
+		},
+		{
+			name: "failing migration",
+			migrations: Migrations{
+				{Version: 2, Up: func(av map[string]*dynamodb.AttributeValue) error {
+					return errors.New("Mock migration error")
+				}},
+			},
+			stored: legacyItem("first", "", ""),
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, err := tt.migrations.Upgrade(tt.stored)
+
+			assert := assert.New(t)
+			if tt.err {
+				assert.Error(err)
+				return
+			}
+			if assert.NoError(err) {
+				assert.Equal(tt.applied, applied, "Applied")
+				assert.Equal(tt.expected, tt.stored, "Item")
+			}
+		})
+	}
+}
+
+func TestMigrations_Validate(t *testing.T) {
+	up := func(map[string]*dynamodb.AttributeValue) error { return nil }
+
+	assert := assert.New(t)
+	assert.NoError(ItemMigrations.Validate(), "Item migrations")
+	assert.NoError(Migrations{}.Validate(), "No migrations")
+	assert.Error(Migrations{{Version: 3, Up: up}}.Validate(), "Skipped version")
+	assert.Error(Migrations{{Version: 2, Up: up}, {Version: 2, Up: up}}.Validate(), "Repeated version")
+	assert.Error(Migrations{{Version: 2}}.Validate(), "Missing function")
+	assert.Equal(1, Migrations{}.Latest())
+}
+
+func TestRepo_GetUpgrade(t *testing.T) {
+	client := &mockDdbStored{stored: []map[string]*dynamodb.AttributeValue{legacyItem("first", "test-item-name", "A1")}}
+	r := &Repo{Client: client, TableName: "mock-table"}
+
+	item, err := r.Get("first")
+
+	assert := assert.New(t)
+	if assert.NoError(err) {
+		assert.Equal(ItemMigrations.Latest(), item.SchemaVersion, "Schema version")
+		assert.Equal("test-item-name", item.Name)
+		assert.Equal("A1", item.Details.Location)
+		assert.Empty(client.updates, "Stored item is kept")
+	}
+}
+
+func TestRepo_Backfill(t *testing.T) {
+	current, _ := dynamodbattribute.MarshalMap(Item{ID: "current", Name: "current", SchemaVersion: ItemMigrations.Latest()})
+	stored := func() []map[string]*dynamodb.AttributeValue {
+		return []map[string]*dynamodb.AttributeValue{
+			legacyItem("first", "test-item-name", "A1"),
+			legacyItem("second", "", ""),
+			current,
+			legacyItem("changed", "test-item-name", ""),
+		}
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		client := &mockDdbStored{stored: stored()}
+		r := &Repo{Client: client, TableName: "mock-table"}
+
+		progress, err := r.Backfill(BackfillOptions{Scan: ScanOptions{Segments: 2}, DryRun: true})
+
+		assert := assert.New(t)
+		if assert.NoError(err) {
+			assert.Equal(4, progress.Scanned, "Scanned")
+			assert.Equal(3, progress.Migrated, "Migrated")
+			assert.True(progress.Checkpoint.Done(), "Completed")
+			assert.Empty(client.updates, "Updates")
+		}
+	})
+
+	t.Run("migrate", func(t *testing.T) {
+		client := &mockDdbStored{stored: stored(), conflict: "changed"}
+		r := &Repo{Client: client, TableName: "mock-table"}
+
+		var mu sync.Mutex
+		var reports []BackfillProgress
+		progress, err := r.Backfill(BackfillOptions{
+			Scan: ScanOptions{Segments: 2},
+			OnProgress: func(p BackfillProgress) error {
+				mu.Lock()
+				defer mu.Unlock()
+				reports = append(reports, p)
+				return nil
+			},
+		})
+
+		assert := assert.New(t)
+		if assert.NoError(err) {
+			assert.Equal(BackfillProgress{Scanned: 4, Migrated: 2, Skipped: 1, Checkpoint: progress.Checkpoint}, progress)
+			assert.Len(reports, 2, "Progress per page")
+			if assert.Len(client.updates, 2, "Updates") {
+				var first *dynamodb.UpdateItemInput
+				for _, u := range client.updates {
+					if *u.Key["id"].S == "first" {
+						first = u
+					}
+				}
+				if assert.NotNil(first, "First item updated") {
+					assert.Equal("SET #m0 = :m0, #m1 = :m1, #m2 = :m2", *first.UpdateExpression)
+					assert.Equal("attribute_exists(id) AND attribute_not_exists(#m0) AND attribute_not_exists(#m1) AND attribute_not_exists(#m2)", *first.ConditionExpression)
+					assert.Equal(map[string]*string{
+						"#m0": aws.String("kind"),
+						"#m1": aws.String("location"),
+						"#m2": aws.String("schemaVersion"),
+					}, first.ExpressionAttributeNames)
+				}
+			}
+		}
+	})
+
+	t.Run("failing migration", func(t *testing.T) {
+		client := &mockDdbStored{stored: stored()}
+		r := &Repo{Client: client, TableName: "mock-table", Migrations: Migrations{
+			{Version: 2, Up: func(av map[string]*dynamodb.AttributeValue) error {
+				return errors.New("Mock migration error")
+			}},
+		}}
+
+		progress, err := r.Backfill(BackfillOptions{})
+
+		assert := assert.New(t)
+		assert.Error(err)
+		assert.False(progress.Checkpoint.Done(), "Resumable")
+		assert.Empty(client.updates, "Updates")
+	})
+}
@@ -0,0 +1,137 @@
+package sample
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Page size limits
+const (
+	DefaultPageLimit = 25
+	MaxPageLimit     = 100
+)
+
+// ErrInvalidPageToken is returned for malformed page tokens (tokens are unsigned start keys)
+var ErrInvalidPageToken = errors.New("Invalid page token")
+
+// Page requests a page of query results
+type Page struct {
+	Limit int    // maximum number of results (default if zero)
+	Next  string // token of the next page (first page if empty)
+}
+
+// Returns the effective page size
+func (p Page) limit() *int64 {
+	switch {
+	case p.Limit <= 0:
+		return aws.Int64(DefaultPageLimit)
+	case p.Limit > MaxPageLimit:
+		return aws.Int64(MaxPageLimit)
+	default:
+		return aws.Int64(int64(p.Limit))
+	}
+}
+
+// keyAttr is a key attribute of a table or an index, start keys of page tokens hold key attributes only
+type keyAttr struct {
+	name   string
+	number bool   // numeric attribute (non-empty string if false)
+	value  string // required value (any if empty), e.g. the partition of the query
+}
+
+// Reports whether the key holds exactly the key attributes of their types and required values
+func validKey(key map[string]*dynamodb.AttributeValue, attrs ...keyAttr) bool {
+	if len(key) != len(attrs) {
+		return false
+	}
+	for _, attr := range attrs {
+		av := key[attr.name]
+		if av == nil {
+			return false
+		}
+		var value string
+		var scalar *dynamodb.AttributeValue // the attribute of a single value
+		if attr.number {
+			value, scalar = aws.StringValue(av.N), &dynamodb.AttributeValue{N: av.N}
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return false
+			}
+		} else {
+			value, scalar = aws.StringValue(av.S), &dynamodb.AttributeValue{S: av.S}
+		}
+		if value == "" || !reflect.DeepEqual(av, scalar) || attr.value != "" && value != attr.value {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the exclusive start key of the page, the key must hold the key attributes
+func (p Page) startKey(attrs ...keyAttr) (map[string]*dynamodb.AttributeValue, error) {
+	if p.Next == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(p.Next)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	var key map[string]*dynamodb.AttributeValue
+	if err = json.Unmarshal(b, &key); err != nil || !validKey(key, attrs...) {
+		return nil, ErrInvalidPageToken
+	}
+	return key, nil
+}
+
+// shardCursor is the position of a page in a shard of a sharded index
+type shardCursor struct {
+	Start map[string]*dynamodb.AttributeValue `json:"start,omitempty"` // exclusive start key (first page if nil)
+	Done  bool                                `json:"done,omitempty"`
+}
+
+// Returns positions of the page in the shards of the name index, start keys must be name index keys of the shards
+func (p Page) shardCursors(shards int) ([]shardCursor, error) {
+	if p.Next == "" {
+		return make([]shardCursor, shards), nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(p.Next)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+	var cursors []shardCursor
+	if err = json.Unmarshal(b, &cursors); err != nil || len(cursors) != shards {
+		return nil, ErrInvalidPageToken
+	}
+	for shard, cursor := range cursors {
+		if cursor.Start != nil && (cursor.Done || !validKey(cursor.Start, keyAttr{name: "id"},
+			keyAttr{name: "kind", value: nameShardKey(shard)}, keyAttr{name: "name"})) {
+			return nil, ErrInvalidPageToken
+		}
+	}
+	return cursors, nil
+}
+
+// Returns a token of the page starting at the shard positions (empty if all shards are done)
+func nextShardsToken(cursors []shardCursor) string {
+	for _, cursor := range cursors {
+		if !cursor.Done {
+			b, _ := json.Marshal(cursors)
+			return base64.RawURLEncoding.EncodeToString(b)
+		}
+	}
+	return ""
+}
+
+// Returns a token of the page starting after the last evaluated key (empty if no more pages)
+func nextPageToken(key map[string]*dynamodb.AttributeValue) string {
+	if len(key) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
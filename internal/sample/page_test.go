@@ -0,0 +1,83 @@
+package sample
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPage(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.EqualValues(DefaultPageLimit, *Page{}.limit(), "Default limit")
+	assert.EqualValues(MaxPageLimit, *Page{Limit: 1000}.limit(), "Max limit")
+	assert.EqualValues(10, *Page{Limit: 10}.limit(), "Limit")
+
+	key := map[string]*dynamodb.AttributeValue{
+		"itemId": {S: aws.String("test-item-id")},
+		"id":     {S: aws.String("2020-09-01T00:00:00Z#test")},
+	}
+	token := nextPageToken(key)
+	got, err := Page{Next: token}.startKey(keyAttr{name: "itemId", value: "test-item-id"}, keyAttr{name: "id"})
+	if assert.NoError(err) {
+		assert.Equal(key, got, "Start key")
+	}
+	_, err = Page{Next: token}.startKey(keyAttr{name: "itemId", value: "other-item-id"}, keyAttr{name: "id"})
+	assert.Equal(ErrInvalidPageToken, err, "Other partition")
+	_, err = Page{Next: token}.startKey(keyAttr{name: "itemId"}, keyAttr{name: "version", number: true})
+	assert.Equal(ErrInvalidPageToken, err, "Other key attributes")
+	_, err = Page{Next: token}.startKey(keyAttr{name: "itemId"})
+	assert.Equal(ErrInvalidPageToken, err, "Extra attribute")
+	for name, av := range map[string]*dynamodb.AttributeValue{
+		"Empty":       {S: aws.String("")},
+		"Not number":  {N: aws.String("x")},
+		"Wrong type":  {S: aws.String("1")},
+		"Two values":  {N: aws.String("1"), S: aws.String("1")},
+		"Nested type": {M: map[string]*dynamodb.AttributeValue{"N": {N: aws.String("1")}}},
+	} {
+		token := nextPageToken(map[string]*dynamodb.AttributeValue{"itemId": {S: aws.String("a")}, "version": av})
+		_, err = Page{Next: token}.startKey(keyAttr{name: "itemId"}, keyAttr{name: "version", number: true})
+		assert.Equal(ErrInvalidPageToken, err, name)
+	}
+
+	assert.Empty(nextPageToken(nil), "Last page")
+	got, err = Page{}.startKey()
+	assert.NoError(err)
+	assert.Nil(got, "First page")
+
+	_, err = Page{Next: "not a token!"}.startKey()
+	assert.Equal(ErrInvalidPageToken, err, "Malformed token")
+	_, err = Page{Next: "e30"}.startKey(keyAttr{name: "id"})
+	assert.Equal(ErrInvalidPageToken, err, "Empty key")
+}
+
+func TestPage_ShardCursors(t *testing.T) {
+	assert := assert.New(t)
+	start := func(shard int) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"id":   {S: aws.String("test-item-id")},
+			"kind": {S: aws.String(nameShardKey(shard))},
+			"name": {S: aws.String("test-item-name")},
+		}
+	}
+
+	cursors := []shardCursor{{Start: start(0)}, {Done: true}}
+	got, err := Page{Next: nextShardsToken(cursors)}.shardCursors(2)
+	if assert.NoError(err) {
+		assert.Equal(cursors, got, "Cursors")
+	}
+	_, err = Page{Next: nextShardsToken(cursors)}.shardCursors(3)
+	assert.Equal(ErrInvalidPageToken, err, "Other number of shards")
+
+	for name, cursors := range map[string][]shardCursor{
+		"Other shard":   {{Start: start(1)}, {}},
+		"Out of range":  {{}, {Start: start(nameShards)}},
+		"Missing name":  {{Start: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}, "kind": {S: aws.String(nameShardKey(0))}}}, {}},
+		"Done with key": {{Start: start(0), Done: true}, {}},
+	} {
+		_, err = Page{Next: nextShardsToken(cursors)}.shardCursors(2)
+		assert.Equal(ErrInvalidPageToken, err, name)
+	}
+}
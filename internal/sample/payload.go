@@ -0,0 +1,353 @@
+package sample
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultOffloadThreshold is the item size which leaves room below the DynamoDB limit of 400 KB
+	// for index attributes and the version record
+	DefaultOffloadThreshold = 350 * 1024
+	// maxMessageSize is the SNS limit of the message body and its attributes
+	maxMessageSize = 256 * 1024
+	// orphanTag marks payloads no longer referenced by items, the bucket lifecycle expires them
+	orphanTag      = "payload"
+	orphanTagValue = "orphaned"
+)
+
+// PayloadRef is a claim check of a payload stored in S3
+type PayloadRef struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Size   int    `json:"size"` // uncompressed size in bytes
+}
+
+// Content of an item offloaded to S3, the rest of the item stays in DynamoDB
+type itemPayload struct {
+	Description string                 `json:"description,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// claimCheck is an SNS message body (or EventBridge event detail) replacing a message over the size limit
+type claimCheck struct {
+	ID         string      `json:"id,omitempty"`
+	ClaimCheck *PayloadRef `json:"claimCheck"`
+}
+
+// ErrClaimCheck is returned when a message replaced by a claim check is decoded before it is resolved
+var ErrClaimCheck = errors.New("Message is a claim check of a stored payload")
+
+// IsClaimCheck reports whether the message body is a claim check of a stored message
+func IsClaimCheck(message string) bool {
+	var check claimCheck
+	return json.Unmarshal([]byte(message), &check) == nil && check.ClaimCheck != nil
+}
+
+// PayloadStore provides S3 client capabilities for compressed payloads of large items and messages
+type PayloadStore struct {
+	Client s3iface.S3API
+	Bucket string
+	Retry  RetryPolicy // retries of throttled operations (single attempt if zero)
+	ctx    context.Context
+}
+
+// Payloads returns a configured S3 client for large payloads
+func Payloads(bucket string) *PayloadStore {
+
+	// retries are controlled by the store policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &PayloadStore{
+		Client: s3.New(sess),
+		Bucket: bucket,
+		Retry:  DefaultRetryPolicy,
+	}
+}
+
+// WithContext returns a shallow copy of the store bound to the context (e.g. Lambda deadline)
+func (s *PayloadStore) WithContext(ctx context.Context) *PayloadStore {
+	store := *s
+	store.ctx = ctx
+	return &store
+}
+
+// Returns the bound context or a background one
+func (s *PayloadStore) context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
+// Put stores the gzip compressed payload under the key and returns its claim check
+func (s *PayloadStore) Put(key string, payload []byte) (*PayloadRef, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	// execute query
+	err := s.Retry.Do(s.context(), func() (err error) {
+		_, err = s.Client.PutObject(&s3.PutObjectInput{
+			Bucket:      &s.Bucket,
+			Key:         &key,
+			Body:        bytes.NewReader(buf.Bytes()),
+			ContentType: aws.String("application/gzip"), // no content encoding, so HTTP clients don't decompress it
+		})
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to store payload")
+	}
+	return &PayloadRef{Bucket: s.Bucket, Key: key, Size: len(payload)}, nil
+}
+
+// Get reads and decompresses the payload of the claim check
+func (s *PayloadStore) Get(ref PayloadRef) ([]byte, error) {
+	input := &s3.GetObjectInput{Bucket: &ref.Bucket, Key: &ref.Key}
+
+	// execute query
+	var res *s3.GetObjectOutput
+	err := s.Retry.Do(s.context(), func() (err error) {
+		res, err = s.Client.GetObject(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, fmt.Errorf("Failed to read payload %v", ref.Key)
+	}
+	defer res.Body.Close()
+
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		log.Println("Failed to decompress:", err.Error())
+		return nil, fmt.Errorf("Invalid payload %v", ref.Key)
+	}
+	defer zr.Close()
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		log.Println("Failed to decompress:", err.Error())
+		return nil, fmt.Errorf("Invalid payload %v", ref.Key)
+	}
+	return b, nil
+}
+
+// Delete removes the payload of the claim check
+func (s *PayloadStore) Delete(ref PayloadRef) error {
+	input := &s3.DeleteObjectInput{Bucket: &ref.Bucket, Key: &ref.Key}
+
+	// execute query
+	err := s.Retry.Do(s.context(), func() (err error) {
+		_, err = s.Client.DeleteObject(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return fmt.Errorf("Failed to delete payload %v", ref.Key)
+	}
+	return nil
+}
+
+// Orphan marks the payload under the key to be expired by the bucket lifecycle.
+// It stays readable until then, so stream records of the item can be retried.
+func (s *PayloadStore) Orphan(key string) error {
+	input := &s3.PutObjectTaggingInput{
+		Bucket:  &s.Bucket,
+		Key:     &key,
+		Tagging: &s3.Tagging{TagSet: []*s3.Tag{{Key: aws.String(orphanTag), Value: aws.String(orphanTagValue)}}},
+	}
+
+	// execute query
+	err := s.Retry.Do(s.context(), func() (err error) {
+		_, err = s.Client.PutObjectTagging(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return fmt.Errorf("Failed to mark payload %v", key)
+	}
+	return nil
+}
+
+// OrphanPrefix marks all payloads under the key prefix to be expired by the bucket lifecycle
+func (s *PayloadStore) OrphanPrefix(prefix string) error {
+	if prefix == "" {
+		return errors.New("Missing key prefix")
+	}
+	input := &s3.ListObjectsV2Input{Bucket: &s.Bucket, Prefix: &prefix}
+	for {
+		// execute query
+		var res *s3.ListObjectsV2Output
+		err := s.Retry.Do(s.context(), func() (err error) {
+			res, err = s.Client.ListObjectsV2(input)
+			return
+		})
+		if err != nil {
+			log.Println(err.Error())
+			return errors.New("Failed to list payloads")
+		}
+		for _, o := range res.Contents {
+			if err := s.Orphan(aws.StringValue(o.Key)); err != nil {
+				return err
+			}
+		}
+		if !aws.BoolValue(res.IsTruncated) {
+			return nil
+		}
+		input.ContinuationToken = res.NextContinuationToken
+	}
+}
+
+// PayloadSink marks payloads of items no longer referenced by them to be expired
+type PayloadSink struct {
+	Store     *PayloadStore
+	Versioned bool // replaced payloads are kept for version records referring to them
+}
+
+// Handle marks all payloads of purged, expired and hard deleted items and payloads replaced by writes
+// of unversioned items, soft deleted items keep them to be restorable
+func (s PayloadSink) Handle(ctx context.Context, change Change) error {
+	store := s.Store.WithContext(ctx)
+	switch {
+	case change.Type == ChangeRemove && change.Old != nil:
+		return store.OrphanPrefix(ItemPayloadPrefix(change.Old.ID))
+	case change.Type == ChangeModify && !s.Versioned && change.OldPayload != nil &&
+		(change.NewPayload == nil || change.NewPayload.Key != change.OldPayload.Key):
+		return store.Orphan(change.OldPayload.Key)
+	}
+	return nil
+}
+
+// ItemPayloadPrefix returns the key prefix of payloads offloaded from the item
+func ItemPayloadPrefix(itemID string) string {
+	return "items/" + itemID + "/"
+}
+
+// Offload moves the description and custom attributes of the item to the store.
+// Every write has a unique key, so concurrent writes of the same version don't overwrite each other.
+func (s *PayloadStore) Offload(item Item) (Item, error) {
+	b, err := json.Marshal(itemPayload{Description: item.Details.Description, Attributes: item.Attributes})
+	if err != nil {
+		return item, err
+	}
+	ref, err := s.Put(fmt.Sprintf("%s%d-%s.json.gz", ItemPayloadPrefix(item.ID), item.Version, uuid.New().String()), b)
+	if err != nil {
+		return item, err
+	}
+	item.Details.Description = ""
+	item.Attributes = nil
+	item.Payload = ref
+	return item, nil
+}
+
+// Rehydrate restores offloaded content of the item, items without a claim check are kept
+func (s *PayloadStore) Rehydrate(item *Item) error {
+	if item == nil || item.Payload == nil {
+		return nil
+	}
+	b, err := s.Get(*item.Payload)
+	if err != nil {
+		return err
+	}
+	var payload itemPayload
+	if err := json.Unmarshal(b, &payload); err != nil {
+		log.Println("Failed to decode:", err.Error())
+		return fmt.Errorf("Invalid payload %v", item.Payload.Key)
+	}
+	item.Details.Description = payload.Description
+	item.Attributes = payload.Attributes
+	item.Payload = nil
+	return nil
+}
+
+// Message returns the message body as is or a claim check of the body stored if over the SNS (and EventBridge) limit
+func (s *PayloadStore) Message(id string, body []byte) (string, error) {
+	if len(body) <= maxMessageSize-len(messageSubject)-1024 { // room for message attributes
+		return string(body), nil
+	}
+	ref, err := s.Put("messages/"+uuid.New().String()+".json.gz", body)
+	if err != nil {
+		return "", err
+	}
+	b, _ := json.Marshal(claimCheck{ID: id, ClaimCheck: ref})
+	return string(b), nil
+}
+
+// Resolve returns the original body of a message replaced by a claim check, other messages are returned as is
+func (s *PayloadStore) Resolve(message string) (string, error) {
+	var check claimCheck
+	if err := json.Unmarshal([]byte(message), &check); err != nil || check.ClaimCheck == nil {
+		return message, nil
+	}
+	if s == nil {
+		return "", errors.New("Message payload store is not configured")
+	}
+	b, err := s.Get(*check.ClaimCheck)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ItemSize estimates the stored size of the item attributes according to DynamoDB rules
+func ItemSize(av map[string]*dynamodb.AttributeValue) int {
+	size := 0
+	for name, v := range av {
+		size += len(name) + attributeSize(v)
+	}
+	return size
+}
+
+// Estimates the stored size of an attribute value
+func attributeSize(v *dynamodb.AttributeValue) int {
+	switch {
+	case v == nil:
+		return 0
+	case v.S != nil:
+		return len(*v.S)
+	case v.N != nil:
+		return len(*v.N)/2 + 1
+	case v.B != nil:
+		return len(v.B)
+	case v.BOOL != nil, v.NULL != nil:
+		return 1
+	case v.M != nil:
+		return 3 + ItemSize(v.M) + len(v.M)
+	case v.L != nil:
+		size := 3
+		for _, e := range v.L {
+			size += 1 + attributeSize(e)
+		}
+		return size
+	}
+	size := 0
+	for _, s := range v.SS {
+		size += len(*s)
+	}
+	for _, n := range v.NS {
+		size += len(*n)/2 + 1
+	}
+	for _, b := range v.BS {
+		size += len(b)
+	}
+	return size
+}
@@ -0,0 +1,190 @@
+package sample
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client storing the last written item
+type mockDdbStore struct {
+	mockDdb
+	stored map[string]*dynamodb.AttributeValue
+}
+
+func (mock *mockDdbStore) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	mock.stored = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (mock *mockDdbStore) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: mock.stored}, nil
+}
+
+func TestRepo_Offload(t *testing.T) {
+	client, local := localClient(t)
+	ddb := &mockDdbStore{}
+	r := &Repo{
+		Client:           ddb,
+		TableName:        "mock-table",
+		Payloads:         &PayloadStore{Client: client, Bucket: local.bucket},
+		OffloadThreshold: 1024,
+	}
+	large := strings.Repeat("Large description. ", 100)
+
+	assert := assert.New(t)
+	saved, err := r.Save(Item{ID: "test-item-id", Name: "test-item-name", Details: Details{Description: large, Quantity: 2}, Attributes: map[string]interface{}{"color": "red"}})
+	if !assert.NoError(err, "Save") {
+		return
+	}
+	assert.Equal(large, saved.Details.Description, "Saved item keeps content")
+	assert.Contains(ddb.stored, "payload", "Claim check")
+	assert.NotContains(ddb.stored, "attributes", "Offloaded attributes")
+	assert.NotContains(ddb.stored["details"].M, "description", "Offloaded description")
+	assert.Equal("2", aws.StringValue(ddb.stored["details"].M["quantity"].N), "Quantity kept in DynamoDB")
+	if assert.Len(local.objects, 1) {
+		for key, o := range local.objects {
+			assert.True(strings.HasPrefix(key, "items/test-item-id/1-"), "Unique key of the version")
+			assert.Less(len(o.body), len(large), "Compressed")
+		}
+	}
+
+	got, err := r.Get("test-item-id")
+	if assert.NoError(err, "Get") {
+		assert.Equal(large, got.Details.Description, "Rehydrated description")
+		assert.Equal(map[string]interface{}{"color": "red"}, got.Attributes, "Rehydrated attributes")
+		assert.Nil(got.Payload, "Claim check resolved")
+	}
+
+	_, err = (&Repo{Client: ddb, TableName: "mock-table"}).Get("test-item-id")
+	assert.Error(err, "Offloaded item without payload store")
+
+	if _, err = r.Save(Item{ID: "small-item-id", Details: Details{Description: "Small"}}); assert.NoError(err) {
+		assert.NotContains(ddb.stored, "payload", "Small item kept in DynamoDB")
+	}
+}
+
+func TestRepo_OffloadConflict(t *testing.T) {
+	client, local := localClient(t)
+	r := &Repo{
+		Client:           newMockVersionedDdb(),
+		TableName:        "mock-table",
+		VersionTableName: "mock-version-table",
+		Payloads:         &PayloadStore{Client: client, Bucket: local.bucket},
+		OffloadThreshold: 1024,
+	}
+	item := Item{ID: "test-item-id", Details: Details{Description: strings.Repeat("Large description. ", 100)}}
+
+	assert := assert.New(t)
+	_, err := r.Save(item)
+	assert.NoError(err, "Save")
+	_, err = r.Save(item)
+	assert.Equal(ErrConflict, err, "Existing item")
+	assert.Len(local.objects, 1, "Payload of the rejected write deleted")
+}
+
+func TestPayloadSink(t *testing.T) {
+	client, local := localClient(t)
+	store := &PayloadStore{Client: client, Bucket: local.bucket}
+	for _, key := range []string{"items/a/1-x.json.gz", "items/a/2-y.json.gz", "items/b/1-z.json.gz"} {
+		local.objects[key] = localObject{}
+	}
+	ref := func(key string) *PayloadRef { return &PayloadRef{Bucket: local.bucket, Key: key} }
+	orphaned := func(key string) bool { return local.objects[key].tags[orphanTag] == orphanTagValue }
+
+	assert := assert.New(t)
+	versioned := PayloadSink{Store: store, Versioned: true}
+	modify := Change{Type: ChangeModify, Old: &Item{ID: "a"}, New: &Item{ID: "a"}, OldPayload: ref("items/a/1-x.json.gz"), NewPayload: ref("items/a/2-y.json.gz")}
+	assert.NoError(versioned.Handle(context.Background(), modify))
+	assert.False(orphaned("items/a/1-x.json.gz"), "Kept for version records")
+
+	assert.NoError(PayloadSink{Store: store}.Handle(context.Background(), modify))
+	assert.True(orphaned("items/a/1-x.json.gz"), "Replaced payload")
+	assert.False(orphaned("items/a/2-y.json.gz"), "Current payload")
+
+	assert.NoError(versioned.Handle(context.Background(), Change{Type: ChangeRemove, Old: &Item{ID: "a"}}))
+	assert.True(orphaned("items/a/2-y.json.gz"), "Payloads of a removed item")
+	assert.False(orphaned("items/b/1-z.json.gz"), "Other item kept")
+	assert.Len(local.objects, 3, "Readable until expired")
+}
+
+func TestPayloadStore_Message(t *testing.T) {
+	client, local := localClient(t)
+	store := &PayloadStore{Client: client, Bucket: local.bucket}
+	assert := assert.New(t)
+
+	small := `{"id":"test-item-id"}`
+	msg, err := store.Message("test-item-id", []byte(small))
+	if assert.NoError(err) {
+		assert.Equal(small, msg, "Small message sent as is")
+	}
+	resolved, err := store.Resolve(msg)
+	if assert.NoError(err) {
+		assert.Equal(small, resolved, "Plain message")
+	}
+
+	large := `{"id":"test-item-id","name":"` + strings.Repeat("x", maxMessageSize) + `"}`
+	msg, err = store.Message("test-item-id", []byte(large))
+	if assert.NoError(err) {
+		assert.Contains(msg, `"claimCheck"`, "Large message replaced")
+		assert.Less(len(msg), 1024, "Claim check size")
+	}
+	resolved, err = store.Resolve(msg)
+	if assert.NoError(err) {
+		assert.Equal(large, resolved, "Resolved claim check")
+	}
+
+	var none *PayloadStore
+	_, err = none.Resolve(msg)
+	assert.Error(err, "Claim check without store")
+}
+
+func TestTopic_PublishLarge(t *testing.T) {
+	client, local := localClient(t)
+	mock := &mockSns{msgID: "test-message-id"}
+	topic := Topic{Client: mock, ARN: "arn:mock:sns:topic", Payloads: &PayloadStore{Client: client, Bucket: local.bucket}}
+	item := Item{ID: "test-item-id", Details: Details{Description: strings.Repeat("x", maxMessageSize)}}
+
+	assert := assert.New(t)
+	if _, err := topic.PublishEvent(ItemUpdated, item); assert.NoError(err) {
+		assert.Contains(*mock.input.Message, `"claimCheck"`, "Published claim check")
+	}
+	result := topic.PublishEventBatch(ItemCreated, []Item{item, {ID: "small-item-id"}})
+	if assert.NoError(result.Err()) {
+		assert.Contains(*mock.batch.PublishBatchRequestEntries[0].Message, `"claimCheck"`, "Batch claim check")
+		assert.Contains(*mock.batch.PublishBatchRequestEntries[1].Message, `"small-item-id"`, "Batch message as is")
+	}
+	assert.Len(local.objects, 2, "Stored messages")
+}
+
+func TestEventBridgeBus_PublishLarge(t *testing.T) {
+	client, local := localClient(t)
+	mock := &mockEventBridge{}
+	store := &PayloadStore{Client: client, Bucket: local.bucket}
+	bus := EventBridgeBus{Client: mock, Name: "test-bus", Payloads: store}
+	item := Item{ID: "test-item-id", Details: Details{Description: strings.Repeat("x", maxMessageSize)}}
+
+	assert := assert.New(t)
+	if _, err := bus.PublishEvent(ItemUpdated, item); assert.NoError(err) {
+		detail := *mock.inputs[0].Entries[0].Detail
+		assert.True(IsClaimCheck(detail), "Published claim check")
+		resolved, err := store.Resolve(detail)
+		if assert.NoError(err) {
+			assert.Contains(resolved, `"test-item-id"`, "Resolved event detail")
+		}
+	}
+	if _, err := bus.PublishEvent(ItemUpdated, Item{ID: "small-item-id"}); assert.NoError(err) {
+		assert.False(IsClaimCheck(*mock.inputs[1].Entries[0].Detail), "Event detail as is")
+	}
+}
+
+func TestItemSize(t *testing.T) {
+	av, _ := dynamodbattribute.MarshalMap(Item{ID: "abc", Version: 12, Tags: []string{"a", "bc"}, Details: Details{Location: "A1"}})
+	// id(2+3) version(7+2) tags(4+3) details(7+3+location(8+2)+1)
+	assert.Equal(t, 42, ItemSize(av))
+}
@@ -0,0 +1,247 @@
+package sample
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Read model key prefixes
+const (
+	prefixContribution = "item#"
+	prefixLocation     = "location#"
+)
+
+// ErrConcurrentProjection is returned when the item projection changed while being applied
+var ErrConcurrentProjection = errors.New("Item projection changed concurrently")
+
+type (
+	// LocationTotal is a read model of the total quantity of items per location
+	LocationTotal struct {
+		Location string `json:"location"`
+		Quantity int    `json:"quantity"`
+		Items    int    `json:"items"`
+	}
+
+	// contribution of a single item to location totals
+	contribution struct {
+		PK        string    `json:"pk"`
+		Location  string    `json:"location,omitempty"`
+		Quantity  int       `json:"quantity"`
+		UpdatedAt time.Time `json:"updatedAt"`
+		Deleted   bool      `json:"deleted"`
+		Revision  int       `json:"revision"`
+	}
+)
+
+// Reports whether the item is counted in location totals
+func (c *contribution) counted() bool {
+	return c != nil && !c.Deleted && c.Location != ""
+}
+
+// Reports whether the contribution is a newer state than the other one.
+// Deletion is newer than any state with the same update timestamp.
+func (c *contribution) newer(other *contribution) bool {
+	if other == nil {
+		return true
+	}
+	if c.UpdatedAt.Equal(other.UpdatedAt) {
+		return c.Deleted && !other.Deleted
+	}
+	return c.UpdatedAt.After(other.UpdatedAt)
+}
+
+// Projection maintains a denormalised read model of location totals.
+// Every item keeps its last applied state, so redelivered and stale events are ignored.
+// Redacted (encrypted) locations are never stored, such items are not counted.
+type Projection struct {
+	Client    dynamodbiface.DynamoDBAPI
+	TableName string
+	Retry     RetryPolicy // retries of throttled operations (single attempt if zero)
+	ctx       context.Context
+}
+
+// ReadModel returns a configured DynamoDB client for the read model
+func ReadModel(tableName string) *Projection {
+
+	// retries are controlled by the projection policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &Projection{
+		Client:    dynamodb.New(sess),
+		TableName: tableName,
+		Retry:     DefaultRetryPolicy,
+	}
+}
+
+// WithContext returns a shallow copy of the projection bound to the context (e.g. Lambda deadline)
+func (p *Projection) WithContext(ctx context.Context) *Projection {
+	projection := *p
+	projection.ctx = ctx
+	return &projection
+}
+
+// Returns the bound context or a background one
+func (p *Projection) context() context.Context {
+	if p.ctx != nil {
+		return p.ctx
+	}
+	return context.Background()
+}
+
+// Apply an item event to the read model and report whether it changed anything
+func (p *Projection) Apply(event Event, item Item) (bool, error) {
+	if item.ID == "" {
+		return false, errors.New("Missing resource ID")
+	}
+
+	next := &contribution{
+		PK:       prefixContribution + item.ID,
+		Location: item.Details.Location,
+		Quantity: item.Details.Quantity,
+		Deleted:  event == ItemDeleted || event == ItemPurged || event == ItemExpired,
+	}
+	if item.UpdatedAt != nil {
+		next.UpdatedAt = *item.UpdatedAt
+	}
+	if next.Location == Redacted {
+		next.Location = ""
+	}
+
+	prev, err := p.contribution(next.PK)
+	if err != nil {
+		return false, err
+	}
+	if !next.newer(prev) {
+		// redelivered or stale event
+		return false, nil
+	}
+
+	// optimistic lock on the last applied state
+	var revision int
+	if prev != nil {
+		revision = prev.Revision
+	}
+	next.Revision = revision + 1
+	av, err := dynamodbattribute.MarshalMap(next)
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return false, err
+	}
+	put := Expr{Condition: "attribute_not_exists(pk)"}
+	if prev != nil {
+		put = Expr{
+			Condition: "revision = :revision",
+			Values: map[string]*dynamodb.AttributeValue{
+				":revision": {N: aws.String(strconv.Itoa(revision))},
+			},
+		}
+	}
+	tx := (&Transaction{Client: p.Client, Retry: p.Retry, ctx: p.ctx}).Put(p.TableName, av, put)
+
+	// adjust location totals
+	deltas := map[string][2]int{}
+	if prev.counted() {
+		d := deltas[prev.Location]
+		deltas[prev.Location] = [2]int{d[0] - prev.Quantity, d[1] - 1}
+	}
+	if next.counted() {
+		d := deltas[next.Location]
+		deltas[next.Location] = [2]int{d[0] + next.Quantity, d[1] + 1}
+	}
+	for location, d := range deltas {
+		if d[0] == 0 && d[1] == 0 {
+			continue
+		}
+		tx.Update(p.TableName, map[string]*dynamodb.AttributeValue{"pk": {S: aws.String(prefixLocation + location)}}, Expr{
+			Update: "SET #location = :location ADD quantity :quantity, #items :items",
+			Names: map[string]*string{
+				"#location": aws.String("location"),
+				"#items":    aws.String("items"),
+			},
+			Values: map[string]*dynamodb.AttributeValue{
+				":location": {S: aws.String(location)},
+				":quantity": {N: aws.String(strconv.Itoa(d[0]))},
+				":items":    {N: aws.String(strconv.Itoa(d[1]))},
+			},
+		})
+	}
+
+	// execute transaction
+	if err = tx.Commit(); err != nil {
+		log.Println(err.Error())
+		var txErr *TransactionError
+		if errors.As(err, &txErr) {
+			return false, ErrConcurrentProjection
+		}
+		return false, errors.New("Failed to update the read model")
+	}
+	return true, nil
+}
+
+// Reads the last applied state of the item
+func (p *Projection) contribution(pk string) (*contribution, error) {
+	input := &dynamodb.GetItemInput{
+		TableName:      &p.TableName,
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"pk": {S: aws.String(pk)},
+		},
+	}
+
+	var res *dynamodb.GetItemOutput
+	err := p.Retry.Do(p.context(), func() (err error) {
+		res, err = p.Client.GetItem(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to read from the read model")
+	} else if res.Item == nil {
+		return nil, nil
+	}
+
+	var c contribution
+	if err = dynamodbattribute.UnmarshalMap(res.Item, &c); err != nil {
+		log.Println("Failed to unmarshal:", err.Error())
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Total returns quantity totals of the location
+func (p *Projection) Total(location string) (*LocationTotal, error) {
+	input := &dynamodb.GetItemInput{
+		TableName: &p.TableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"pk": {S: aws.String(prefixLocation + location)},
+		},
+	}
+
+	var res *dynamodb.GetItemOutput
+	err := p.Retry.Do(p.context(), func() (err error) {
+		res, err = p.Client.GetItem(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to read from the read model")
+	}
+
+	total := LocationTotal{Location: location}
+	if res.Item != nil {
+		if err = dynamodbattribute.UnmarshalMap(res.Item, &total); err != nil {
+			log.Println("Failed to unmarshal:", err.Error())
+			return nil, err
+		}
+	}
+	return &total, nil
+}
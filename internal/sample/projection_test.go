@@ -0,0 +1,174 @@
+package sample
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock read model table keeping rows in memory
+type mockReadModel struct {
+	dynamodbiface.DynamoDBAPI
+	rows         map[string]map[string]*dynamodb.AttributeValue
+	transactions int
+	err          error
+}
+
+func (mock *mockReadModel) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: mock.rows[*input.Key["pk"].S]}, nil
+}
+
+func (mock *mockReadModel) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	mock.transactions++
+	for _, item := range input.TransactItems {
+		if put := item.Put; put != nil {
+			pk := *put.Item["pk"].S
+			if existing, ok := mock.rows[pk]; ok {
+				if put.ExpressionAttributeValues == nil || *existing["revision"].N != *put.ExpressionAttributeValues[":revision"].N {
+					return nil, &dynamodb.TransactionCanceledException{Message_: aws.String("Mock condition failure")}
+				}
+			}
+			mock.rows[pk] = put.Item
+		}
+		if update := item.Update; update != nil {
+			pk := *update.Key["pk"].S
+			row, ok := mock.rows[pk]
+			if !ok {
+				row = map[string]*dynamodb.AttributeValue{"pk": {S: aws.String(pk)}}
+				mock.rows[pk] = row
+			}
+			row["location"] = update.ExpressionAttributeValues[":location"]
+			for _, attr := range []string{"quantity", "items"} {
+				value, _ := strconv.Atoi(*update.ExpressionAttributeValues[":"+attr].N)
+				if row[attr] != nil {
+					current, _ := strconv.Atoi(*row[attr].N)
+					value += current
+				}
+				row[attr] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(value))}
+			}
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func TestProjection_Apply(t *testing.T) {
+	t1 := time.Now()
+	t2 := t1.Add(time.Minute)
+	item := func(location string, quantity int, updatedAt time.Time) Item {
+		return Item{
+			ID:        "test-item-id",
+			UpdatedAt: &updatedAt,
+			Details:   Details{Location: location, Quantity: quantity},
+		}
+	}
+
+	type step struct {
+		event Event
+		item  Item
+		want  bool
+	}
+	tests := []struct {
+		name   string
+		steps  []step
+		totals map[string][2]int
+	}{
+		{
+			name:   "created",
+			steps:  []step{{ItemCreated, item("A1", 5, t1), true}},
+			totals: map[string][2]int{"A1": {5, 1}},
+		},
+		{
+			name: "redelivered",
+			steps: []step{
+				{ItemCreated, item("A1", 5, t1), true},
+				{ItemCreated, item("A1", 5, t1), false},
+			},
+			totals: map[string][2]int{"A1": {5, 1}},
+		},
+		{
+			name: "relocated",
+			steps: []step{
+				{ItemCreated, item("A1", 5, t1), true},
+				{ItemUpdated, item("B2", 3, t2), true},
+			},
+			totals: map[string][2]int{"A1": {0, 0}, "B2": {3, 1}},
+		},
+		{
+			name: "stale update",
+			steps: []step{
+				{ItemUpdated, item("B2", 3, t2), true},
+				{ItemCreated, item("A1", 5, t1), false},
+			},
+			totals: map[string][2]int{"A1": {0, 0}, "B2": {3, 1}},
+		},
+		{
+			name: "deleted",
+			steps: []step{
+				{ItemCreated, item("A1", 5, t1), true},
+				{ItemDeleted, item("A1", 5, t1), true},
+				{ItemDeleted, item("A1", 5, t1), false},
+			},
+			totals: map[string][2]int{"A1": {0, 0}},
+		},
+		{
+			name: "redacted",
+			steps: []step{
+				{ItemCreated, item("A1", 5, t1), true},
+				{ItemUpdated, item(Redacted, 3, t2), true},
+			},
+			totals: map[string][2]int{"A1": {0, 0}, Redacted: {0, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockReadModel{rows: map[string]map[string]*dynamodb.AttributeValue{}}
+			p := &Projection{Client: client, TableName: "mock-table"}
+
+			assert := assert.New(t)
+			for i, s := range tt.steps {
+				got, err := p.Apply(s.event, s.item)
+				if assert.NoError(err, "Step %d", i) {
+					assert.Equal(s.want, got, "Step %d applied", i)
+				}
+			}
+			for location, want := range tt.totals {
+				total, err := p.Total(location)
+				if assert.NoError(err) {
+					assert.Equal(want[0], total.Quantity, "Quantity at %v", location)
+					assert.Equal(want[1], total.Items, "Items at %v", location)
+				}
+			}
+		})
+	}
+}
+
+func TestProjection_ApplyFailure(t *testing.T) {
+	updatedAt := time.Now()
+	item := Item{ID: "test-item-id", UpdatedAt: &updatedAt, Details: Details{Location: "A1", Quantity: 1}}
+
+	assert := assert.New(t)
+	p := &Projection{Client: &mockReadModel{rows: map[string]map[string]*dynamodb.AttributeValue{}}, TableName: "mock-table"}
+	_, err := p.Apply(ItemCreated, Item{})
+	assert.Error(err, "Missing ID")
+
+	p.Client = &mockReadModel{
+		rows: map[string]map[string]*dynamodb.AttributeValue{},
+		err:  &dynamodb.TransactionCanceledException{Message_: aws.String("Mock conflict")},
+	}
+	_, err = p.Apply(ItemCreated, item)
+	assert.Equal(ErrConcurrentProjection, err, "Concurrent update")
+
+	p.Client = &mockReadModel{rows: map[string]map[string]*dynamodb.AttributeValue{}, err: errors.New("Mock DynamoDB error")}
+	_, err = p.Apply(ItemCreated, item)
+	assert.Error(err, "Failed operation")
+}
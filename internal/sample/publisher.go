@@ -1,9 +1,11 @@
 package sample
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -11,22 +13,83 @@ import (
 	"github.com/aws/aws-sdk-go/service/sns/snsiface"
 )
 
+const (
+	// messageSubject is a subject of every notification message
+	messageSubject = "Sample notification message"
+	// maxBatchSize is the maximum number of entries in a single PublishBatch request
+	maxBatchSize = 10
+	// eventAttribute is a message attribute holding the lifecycle event type
+	eventAttribute = "event"
+)
+
+// Event is a type of item lifecycle event
+type Event string
+
+// Item lifecycle events
+const (
+	ItemCreated  Event = "item.created"
+	ItemUpdated  Event = "item.updated"
+	ItemDeleted  Event = "item.deleted"  // soft deleted, can be restored
+	ItemRestored Event = "item.restored" // soft delete undone
+	ItemPurged   Event = "item.purged"   // removed permanently
+	ItemExpired  Event = "item.expired"  // removed by DynamoDB TTL at its expiry
+)
+
+// Publisher notifies subscribers about item lifecycle events
+type Publisher interface {
+	// PublishEvent sends an item event and returns a message (event) ID
+	PublishEvent(event Event, item Item) (string, error)
+}
+
 // Topic provides SNS client capabilities
 type Topic struct {
-	Client snsiface.SNSAPI
-	ARN    string
+	Client   snsiface.SNSAPI
+	ARN      string
+	Retry    RetryPolicy   // retries of throttled requests and failed batch entries (single attempt if zero)
+	Payloads *PayloadStore // claim-check store of messages over the SNS limit (sent as is if nil)
+	ctx      context.Context
+}
+
+// WithContext returns a copy of the topic client bound to the context (e.g. Lambda deadline)
+func (t Topic) WithContext(ctx context.Context) *Topic {
+	t.ctx = ctx
+	return &t
+}
+
+// Returns the bound context or a background one
+func (t Topic) context() context.Context {
+	if t.ctx != nil {
+		return t.ctx
+	}
+	return context.Background()
 }
 
 // Publish an item to the SNS topic and return MessageId
 func (t Topic) Publish(item Item) (string, error) {
+	return t.PublishEvent(ItemCreated, item)
+}
+
+// PublishEvent publishes an item to the SNS topic with an event type attribute and returns MessageId
+func (t Topic) PublishEvent(event Event, item Item) (string, error) {
 	// prepare a message body
-	body, _ := json.Marshal(item)
+	body, err := t.message(item)
+	if err != nil {
+		return "", err
+	}
 
 	// pubblish the message to SNS topic
-	out, err := t.Client.Publish(&sns.PublishInput{
-		Message:  aws.String(string(body)),
-		Subject:  aws.String("Sample notification message"),
+	input := &sns.PublishInput{
+		Message:  aws.String(body),
+		Subject:  aws.String(messageSubject),
 		TopicArn: &t.ARN,
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			eventAttribute: {DataType: aws.String("String"), StringValue: aws.String(string(event))},
+		},
+	}
+	var out *sns.PublishOutput
+	err = t.Retry.Do(t.context(), func() (err error) {
+		out, err = t.Client.Publish(input)
+		return
 	})
 
 	if err != nil {
@@ -36,13 +99,172 @@ func (t Topic) Publish(item Item) (string, error) {
 	return *out.MessageId, nil
 }
 
+// Returns the message body of the item, which is replaced by a claim check if over the SNS limit.
+// Sensitive fields are redacted.
+func (t Topic) message(item Item) (string, error) {
+	body, _ := json.Marshal(Redact(item))
+	if t.Payloads == nil {
+		return string(body), nil
+	}
+	return t.Payloads.WithContext(t.context()).Message(item.ID, body)
+}
+
+// BatchEntry is an outcome of publishing a single item in a batch
+type BatchEntry struct {
+	Item      Item   // published item
+	MessageID string // SNS message ID if published successfully
+	Err       error  // publishing error if failed
+}
+
+// BatchResult aggregates outcomes of publishing multiple items
+type BatchResult struct {
+	Entries []BatchEntry // outcomes in the order of published items
+}
+
+// Succeeded returns entries of successfully published items
+func (r BatchResult) Succeeded() []BatchEntry {
+	var entries []BatchEntry
+	for _, e := range r.Entries {
+		if e.Err == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Failed returns entries of items which failed to publish
+func (r BatchResult) Failed() []BatchEntry {
+	var entries []BatchEntry
+	for _, e := range r.Entries {
+		if e.Err != nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Err returns an aggregated error if any item failed to publish
+func (r BatchResult) Err() error {
+	if failed := len(r.Failed()); failed > 0 {
+		return fmt.Errorf("Failed to send %d of %d messages", failed, len(r.Entries))
+	}
+	return nil
+}
+
+// PublishBatch publishes created items to the SNS topic in batches of up to 10 messages
+func (t Topic) PublishBatch(items []Item) BatchResult {
+	return t.PublishEventBatch(ItemCreated, items)
+}
+
+// PublishEventBatch publishes items to the SNS topic in batches of up to 10 messages with an event type attribute.
+// Failed entries are retried according to the retry policy unless the failure is caused by the sender.
+func (t Topic) PublishEventBatch(event Event, items []Item) BatchResult {
+	result := BatchResult{Entries: make([]BatchEntry, len(items))}
+	for i, item := range items {
+		result.Entries[i].Item = item
+	}
+
+	for start := 0; start < len(items); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		t.publishChunk(event, result.Entries[start:end])
+	}
+	return result
+}
+
+// Publishes a chunk of batch entries, retrying failed ones
+func (t Topic) publishChunk(event Event, entries []BatchEntry) {
+	pending := make([]int, len(entries))
+	for i := range entries {
+		pending[i] = i
+	}
+
+	_ = t.Retry.Do(t.context(), func() error {
+		if pending = t.tryPublishBatch(event, entries, pending); len(pending) > 0 {
+			return errRetry
+		}
+		return nil
+	})
+}
+
+// Sends a single PublishBatch request and returns indices of entries to retry
+func (t Topic) tryPublishBatch(event Event, entries []BatchEntry, pending []int) []int {
+	input := &sns.PublishBatchInput{TopicArn: &t.ARN}
+	var sent, retry []int
+	for _, i := range pending {
+		body, err := t.message(entries[i].Item)
+		if err != nil { // claim check of the message failed to store
+			entries[i].Err = err
+			retry = append(retry, i)
+			continue
+		}
+		sent = append(sent, i)
+		input.PublishBatchRequestEntries = append(input.PublishBatchRequestEntries, &sns.PublishBatchRequestEntry{
+			Id:      aws.String(strconv.Itoa(i)),
+			Message: aws.String(body),
+			Subject: aws.String(messageSubject),
+			MessageAttributes: map[string]*sns.MessageAttributeValue{
+				eventAttribute: {DataType: aws.String("String"), StringValue: aws.String(string(event))},
+			},
+		})
+	}
+
+	if len(sent) == 0 {
+		return retry
+	}
+
+	out, err := t.Client.PublishBatch(input)
+	if err != nil {
+		log.Println(err.Error())
+		for _, i := range sent {
+			entries[i].Err = fmt.Errorf("Failed to send a message to %v", t.ARN)
+		}
+		if IsRetryable(err) {
+			return pending
+		}
+		return retry
+	}
+
+	reported := make(map[int]bool, len(sent))
+	for _, ok := range out.Successful {
+		if i, err := strconv.Atoi(aws.StringValue(ok.Id)); err == nil {
+			reported[i] = true
+			entries[i].MessageID = aws.StringValue(ok.MessageId)
+			entries[i].Err = nil
+		}
+	}
+	for _, failed := range out.Failed {
+		if i, err := strconv.Atoi(aws.StringValue(failed.Id)); err == nil {
+			reported[i] = true
+			log.Println(aws.StringValue(failed.Code), aws.StringValue(failed.Message))
+			entries[i].Err = fmt.Errorf("Failed to send a message to %v: %v", t.ARN, aws.StringValue(failed.Code))
+			if !aws.BoolValue(failed.SenderFault) {
+				retry = append(retry, i)
+			}
+		}
+	}
+
+	// entries missing in the response are not known to be published
+	for _, i := range sent {
+		if !reported[i] {
+			entries[i].Err = fmt.Errorf("Missing result of a message to %v", t.ARN)
+			retry = append(retry, i)
+		}
+	}
+	return retry
+}
+
 // SnsTopic returns a configured topic client
 func SnsTopic(arn string) *Topic {
 
-	sess := session.Must(session.NewSession())
+	// retries are controlled by the topic policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
 
 	return &Topic{
 		Client: sns.New(sess),
 		ARN:    arn,
+		Retry:  DefaultRetryPolicy,
 	}
 }
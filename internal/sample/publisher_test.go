@@ -2,8 +2,12 @@ package sample
 
 import (
 	"errors"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/aws/aws-sdk-go/service/sns/snsiface"
 	"github.com/stretchr/testify/assert"
@@ -12,14 +16,60 @@ import (
 // Mock SNS client with successful response
 type mockSns struct {
 	snsiface.SNSAPI
-	msgID string
-	err   error
+	msgID    string
+	err      error
+	failures []map[string]bool      // failed entry IDs per PublishBatch call (true if sender's fault)
+	omitted  []map[string]bool      // entry IDs missing in the result per PublishBatch call
+	calls    [][]string             // entry IDs sent in every PublishBatch call
+	input    *sns.PublishInput      // the last Publish input
+	batch    *sns.PublishBatchInput // the last PublishBatch input
 }
 
-func (mock *mockSns) Publish(*sns.PublishInput) (*sns.PublishOutput, error) {
+func (mock *mockSns) Publish(input *sns.PublishInput) (*sns.PublishOutput, error) {
+	mock.input = input
 	return &sns.PublishOutput{MessageId: &mock.msgID}, mock.err
 }
 
+func (mock *mockSns) PublishBatch(input *sns.PublishBatchInput) (*sns.PublishBatchOutput, error) {
+	mock.batch = input
+	call := len(mock.calls)
+	var ids []string
+	for _, entry := range input.PublishBatchRequestEntries {
+		ids = append(ids, *entry.Id)
+	}
+	mock.calls = append(mock.calls, ids)
+	if mock.err != nil {
+		return nil, mock.err
+	}
+
+	var failures, omitted map[string]bool
+	if call < len(mock.failures) {
+		failures = mock.failures[call]
+	}
+	if call < len(mock.omitted) {
+		omitted = mock.omitted[call]
+	}
+	output := new(sns.PublishBatchOutput)
+	for _, id := range ids {
+		if omitted[id] {
+			continue
+		}
+		if senderFault, failed := failures[id]; failed {
+			output.Failed = append(output.Failed, &sns.BatchResultErrorEntry{
+				Id:          aws.String(id),
+				Code:        aws.String("MockFailure"),
+				SenderFault: aws.Bool(senderFault),
+			})
+		} else {
+			output.Successful = append(output.Successful, &sns.PublishBatchResultEntry{
+				Id:        aws.String(id),
+				MessageId: aws.String(mock.msgID + "-" + id),
+			})
+		}
+	}
+	return output, nil
+}
+
 func TestTopic_Publish(t *testing.T) {
 	type fields struct {
 		Client snsiface.SNSAPI
@@ -73,3 +123,143 @@ func TestTopic_Publish(t *testing.T) {
 		})
 	}
 }
+
+func TestTopic_PublishRedacted(t *testing.T) {
+	client := &mockSns{msgID: "test-message-id"}
+	topic := Topic{Client: client, ARN: "arn:mock:sns:topic"}
+
+	assert := assert.New(t)
+	if _, err := topic.Publish(Item{ID: "test-item-id", Details: Details{Location: "secret"}}); assert.NoError(err) {
+		assert.NotContains(*client.input.Message, "secret", "Redacted location")
+		assert.Contains(*client.input.Message, Redacted)
+	}
+}
+
+func TestTopic_PublishBatch(t *testing.T) {
+	const maxAttempts = 3
+
+	items := func(n int) []Item {
+		items := make([]Item, n)
+		for i := range items {
+			items[i].ID = strconv.Itoa(i)
+		}
+		return items
+	}
+
+	tests := []struct {
+		name       string
+		client     *mockSns
+		items      []Item
+		wantCalls  int
+		wantFailed int
+		wantDelays int
+	}{
+		{
+			name:      "all published in chunks",
+			client:    &mockSns{msgID: "test-message-id"},
+			items:     items(25),
+			wantCalls: 3,
+		},
+		{
+			name:       "failed entry retried",
+			client:     &mockSns{msgID: "test-message-id", failures: []map[string]bool{{"3": false}}},
+			items:      items(5),
+			wantCalls:  2,
+			wantDelays: 1,
+		},
+		{
+			name:       "sender fault not retried",
+			client:     &mockSns{msgID: "test-message-id", failures: []map[string]bool{{"1": true, "2": false}}},
+			items:      items(5),
+			wantCalls:  2,
+			wantFailed: 1,
+			wantDelays: 1,
+		},
+		{
+			name:       "missing result retried",
+			client:     &mockSns{msgID: "test-message-id", omitted: []map[string]bool{{"2": true, "4": true}}},
+			items:      items(5),
+			wantCalls:  2,
+			wantDelays: 1,
+		},
+		{
+			name: "missing results exhausted",
+			client: &mockSns{msgID: "test-message-id", omitted: []map[string]bool{
+				{"1": true}, {"1": true}, {"1": true},
+			}},
+			items:      items(3),
+			wantCalls:  maxAttempts,
+			wantFailed: 1,
+			wantDelays: maxAttempts - 1,
+		},
+		{
+			name: "retries exhausted",
+			client: &mockSns{msgID: "test-message-id", failures: []map[string]bool{
+				{"0": false}, {"0": false}, {"0": false},
+			}},
+			items:      items(2),
+			wantCalls:  maxAttempts,
+			wantFailed: 1,
+			wantDelays: maxAttempts - 1,
+		},
+		{
+			name:       "request throttled",
+			client:     &mockSns{err: awserr.New("Throttled", "Mock SNS throttling", nil)},
+			items:      items(12),
+			wantCalls:  2 * maxAttempts,
+			wantFailed: 12,
+			wantDelays: 2 * (maxAttempts - 1),
+		},
+		{
+			name:       "request error",
+			client:     &mockSns{err: errors.New("Mock SNS error")},
+			items:      items(12),
+			wantCalls:  2,
+			wantFailed: 12,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &mockClock{}
+			topic := Topic{
+				Client: tt.client,
+				ARN:    "arn:mock:sns:topic",
+				Retry:  RetryPolicy{MaxAttempts: maxAttempts, BaseDelay: time.Millisecond, Clock: clock},
+			}
+			got := topic.PublishBatch(tt.items)
+
+			assert := assert.New(t)
+			assert.Len(got.Entries, len(tt.items), "Entries")
+			assert.Len(tt.client.calls, tt.wantCalls, "PublishBatch calls")
+			assert.Len(got.Failed(), tt.wantFailed, "Failed entries")
+			assert.Len(got.Succeeded(), len(tt.items)-tt.wantFailed, "Succeeded entries")
+			assert.Len(clock.delays, tt.wantDelays, "Backoff delays")
+			for _, call := range tt.client.calls {
+				assert.LessOrEqual(len(call), maxBatchSize, "Batch size")
+			}
+			for i, entry := range got.Entries {
+				assert.Equal(tt.items[i].ID, entry.Item.ID, "Entry order")
+				if entry.Err == nil {
+					assert.NotEmpty(entry.MessageID, "MessageId")
+				}
+			}
+			if tt.wantFailed > 0 {
+				assert.Error(got.Err())
+			} else {
+				assert.NoError(got.Err())
+			}
+		})
+	}
+}
+
+func TestTopic_PublishEventBatch(t *testing.T) {
+	client := &mockSns{msgID: "test-message-id"}
+	topic := Topic{Client: client, ARN: "arn:mock:sns:topic"}
+
+	got := topic.PublishEventBatch(ItemDeleted, []Item{{ID: "a"}, {ID: "b"}})
+	assert.NoError(t, got.Err())
+	for _, entry := range client.batch.PublishBatchRequestEntries {
+		assert.Equal(t, string(ItemDeleted), *entry.MessageAttributes[eventAttribute].StringValue, "Event attribute")
+	}
+}
@@ -0,0 +1,393 @@
+package sample
+
+import (
+	"errors"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/nb-samples/aws-serverless-go/internal/filter"
+)
+
+// Global secondary indexes of the items table
+const (
+	IndexByName     = "name-index"     // kind (partition) and name (sort)
+	IndexByLocation = "location-index" // location (partition) and name (sort)
+
+	// kindItem is the partition key prefix of the name index
+	kindItem = "item"
+
+	// nameShards is the number of name index partitions, items are spread by ID hash to avoid a hot partition
+	nameShards = 8
+)
+
+// Returns the name index shard of the item
+func nameShard(itemID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(itemID))
+	return int(h.Sum32() % nameShards)
+}
+
+// Returns the name index partition key of the shard
+func nameShardKey(shard int) string {
+	return kindItem + "#" + strconv.Itoa(shard)
+}
+
+// Query selects items by secondary index attributes
+type Query struct {
+	Location       string           // exact location (any location if empty)
+	NamePrefix     string           // prefix of the name (any name if empty)
+	Tags           []string         // items having all the tags (any if empty)
+	IncludeDeleted bool             // include soft deleted items
+	Filter         filter.Expr      // additional filter with item field paths (none if nil)
+	Sort           []filter.SortKey // order of the page (by name if empty)
+	Fields         []string         // sparse fieldset (all fields if empty)
+}
+
+// Item field paths and their short aliases
+var itemFields = map[string]string{
+	"id":                  "id",
+	"name":                "name",
+	"createdAt":           "createdAt",
+	"updatedAt":           "updatedAt",
+	"version":             "version",
+	"deletedAt":           "deletedAt",
+	"expiresAt":           "expiresAt",
+	"details":             "details",
+	"attributes":          "attributes",
+	"tags":                "tags",
+	"details.description": "details.description",
+	"details.location":    "details.location",
+	"details.quantity":    "details.quantity",
+	"details.reserved":    "details.reserved",
+	"description":         "details.description",
+	"location":            "details.location",
+	"quantity":            "details.quantity",
+	"reserved":            "details.reserved",
+}
+
+// ItemField returns the field path of an item field name or alias (custom attributes included)
+func ItemField(name string) (string, bool) {
+	if key := strings.TrimPrefix(name, "attributes."); key != name {
+		return name, attributeKey.MatchString(key)
+	}
+	path, ok := itemFields[name]
+	return path, ok
+}
+
+// ParseFilter parses a filter expression of item fields (e.g. `quantity > 5 and location eq "A1"`)
+func ParseFilter(s string) (filter.Expr, error) {
+	e, err := filter.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	err = filter.Walk(e, func(c *filter.Compare) error {
+		path, ok := ItemField(c.Field)
+		if !ok {
+			return &filter.SyntaxError{Pos: c.Pos, Msg: "unknown field " + c.Field}
+		}
+		c.Field = path
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Adds flattened attributes of secondary indexes, items without a name are not indexed
+func indexAttributes(item Item, av map[string]*dynamodb.AttributeValue) {
+	if item.Name == "" {
+		return
+	}
+	av["kind"] = &dynamodb.AttributeValue{S: aws.String(nameShardKey(nameShard(item.ID)))}
+	if item.Details.Location != "" && (item.Encryption == nil || !encrypted("details.location")) { // ciphertext can't be queried
+		av["location"] = &dynamodb.AttributeValue{S: aws.String(item.Details.Location)}
+	}
+}
+
+// QueryByName lists a page of items with the name prefix ordered by name
+func (r *Repo) QueryByName(namePrefix string, includeDeleted bool, page Page) ([]Item, string, error) {
+	return r.Query(Query{NamePrefix: namePrefix, IncludeDeleted: includeDeleted}, page)
+}
+
+// QueryByLocation lists a page of items at the location (with optional name prefix) ordered by name
+func (r *Repo) QueryByLocation(location, namePrefix string, includeDeleted bool, page Page) ([]Item, string, error) {
+	if location == "" {
+		return nil, "", errors.New("Missing location")
+	}
+	return r.Query(Query{Location: location, NamePrefix: namePrefix, IncludeDeleted: includeDeleted}, page)
+}
+
+// Query lists a page of items matching the query ordered by name (or sort keys within the page).
+// Filters are applied after the page is read, so a page may be shorter than its limit.
+// Filter terms DynamoDB can't evaluate are applied in memory.
+func (r *Repo) Query(q Query, page Page) ([]Item, string, error) {
+	// prepare query data
+	input := &dynamodb.QueryInput{
+		TableName:                 &r.TableName,
+		ExpressionAttributeNames:  map[string]*string{},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{},
+		Limit:                     page.limit(),
+	}
+	var condition string
+	var residual []filter.Expr
+	location := q.Location
+	if location != "" && (r.NoLocationIndex || r.Encryption != nil && encrypted("details.location")) { // not indexed, filtered in memory
+		residual = append(residual, &filter.Compare{Field: "details.location", Op: filter.OpEq, Value: location})
+		location = ""
+	}
+	if location != "" {
+		input.IndexName = aws.String(IndexByLocation)
+		condition = "#location = :location"
+		input.ExpressionAttributeNames["#location"] = aws.String("location")
+		input.ExpressionAttributeValues[":location"] = &dynamodb.AttributeValue{S: aws.String(location)}
+	} else {
+		input.IndexName = aws.String(IndexByName)
+		condition = "#kind = :kind" // of every shard
+		input.ExpressionAttributeNames["#kind"] = aws.String("kind")
+	}
+	if q.NamePrefix != "" {
+		condition += " AND begins_with(#name, :namePrefix)"
+		input.ExpressionAttributeNames["#name"] = aws.String("name")
+		input.ExpressionAttributeValues[":namePrefix"] = &dynamodb.AttributeValue{S: aws.String(q.NamePrefix)}
+	}
+	input.KeyConditionExpression = aws.String(condition)
+
+	// filter terms are evaluated by DynamoDB where possible
+	b := &filter.Builder{Names: input.ExpressionAttributeNames, Values: input.ExpressionAttributeValues}
+	var conditions []string
+	if !q.IncludeDeleted {
+		conditions = append(conditions, "attribute_not_exists(deletedAt)")
+	}
+	for i, tag := range q.Tags {
+		placeholder := ":tag" + strconv.Itoa(i)
+		conditions = append(conditions, "contains(#tags, "+placeholder+")")
+		input.ExpressionAttributeNames["#tags"] = aws.String("tags")
+		input.ExpressionAttributeValues[placeholder] = &dynamodb.AttributeValue{S: aws.String(tag)}
+	}
+	// expired items are hidden until DynamoDB TTL deletes them
+	conditions = append(conditions, "(attribute_not_exists(#ttl) OR #ttl > :now)")
+	input.ExpressionAttributeNames["#ttl"] = aws.String("ttl")
+	input.ExpressionAttributeValues[":now"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))}
+	for _, term := range filter.Conjuncts(q.Filter) {
+		if filter.Supported(term) && (r.Encryption == nil || !encryptedTerm(term)) {
+			conditions = append(conditions, b.Condition(term))
+		} else {
+			residual = append(residual, term)
+		}
+	}
+	if len(conditions) > 0 {
+		input.FilterExpression = aws.String(strings.Join(conditions, " AND "))
+	}
+
+	// sparse fieldset includes fields evaluated in memory
+	if len(q.Fields) > 0 {
+		paths := append([]string{}, q.Fields...)
+		for _, term := range residual {
+			_ = filter.Walk(term, func(c *filter.Compare) error {
+				paths = append(paths, c.Field)
+				return nil
+			})
+		}
+		for _, key := range q.Sort {
+			paths = append(paths, key.Field)
+		}
+		if offloadable(paths) {
+			paths = append(paths, "payload")
+		}
+		if r.Encryption != nil && encryptable(paths) { // data key is bound to the item ID
+			paths = append(paths, "id", "encryption")
+		}
+		if location == "" { // shard pages are merged by names and continue after item keys
+			paths = append(paths, "id", "name")
+		}
+		input.ProjectionExpression = aws.String(b.Projection(paths))
+	}
+
+	// execute query
+	var found []map[string]*dynamodb.AttributeValue
+	var next string
+	var err error
+	if location == "" {
+		found, next, err = r.queryShards(input, page)
+	} else if input.ExclusiveStartKey, err = page.startKey(keyAttr{name: "id"}, keyAttr{name: "location", value: location}, keyAttr{name: "name"}); err == nil {
+		var res *dynamodb.QueryOutput
+		if res, err = r.query(input); err == nil {
+			found, next = res.Items, nextPageToken(res.LastEvaluatedKey)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	// process query results, projected items are partial so they are not migrated
+	if input.ProjectionExpression == nil {
+		for _, av := range found {
+			if err = r.upgrade(av); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	var items []Item
+	if err = dynamodbattribute.UnmarshalListOfMaps(found, &items); err != nil {
+		log.Println("Failed to unmarshal:", err.Error())
+		return nil, "", err
+	}
+	for i := range items {
+		if err = r.rehydrate(&items[i]); err != nil {
+			return nil, "", err
+		}
+	}
+	if len(residual) > 0 || len(q.Sort) > 0 {
+		items = arrange(items, residual, q.Sort)
+	}
+	return items, next, nil
+}
+
+// Executes a single query of an index
+func (r *Repo) query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	var res *dynamodb.QueryOutput
+	err := r.Retry.Do(r.context(), func() (err error) {
+		res, err = r.Client.Query(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to query items from the repository")
+	}
+	return res, nil
+}
+
+// Queries a page of every shard of the name index concurrently and merges them in the order of names.
+// Every shard continues after its last merged item, so a page reads up to the limit of items per shard.
+func (r *Repo) queryShards(input *dynamodb.QueryInput, page Page) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	cursors, err := page.shardCursors(nameShards)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// execute queries of unfinished shards
+	pages := make([]*dynamodb.QueryOutput, nameShards)
+	errs := make([]error, nameShards)
+	var wg sync.WaitGroup
+	for shard, cursor := range cursors {
+		if cursor.Done {
+			continue
+		}
+		shardInput := *input
+		shardInput.ExclusiveStartKey = cursor.Start
+		shardInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":kind": {S: aws.String(nameShardKey(shard))},
+		}
+		for k, v := range input.ExpressionAttributeValues {
+			shardInput.ExpressionAttributeValues[k] = v
+		}
+		wg.Add(1)
+		go func(shard int, input *dynamodb.QueryInput) {
+			defer wg.Done()
+			pages[shard], errs[shard] = r.query(input)
+		}(shard, &shardInput)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	// merge shard pages by names up to the page limit
+	limit := int(aws.Int64Value(page.limit()))
+	merged := make([]int, nameShards)
+	var items []map[string]*dynamodb.AttributeValue
+	for len(items) < limit {
+		next := -1
+		for shard, res := range pages {
+			if res == nil || merged[shard] == len(res.Items) {
+				continue
+			}
+			if next < 0 || itemName(res.Items[merged[shard]]) < itemName(pages[next].Items[merged[next]]) {
+				next = shard
+			}
+		}
+		if next < 0 {
+			break
+		}
+		items = append(items, pages[next].Items[merged[next]])
+		merged[next]++
+	}
+
+	// shards continue after their last merged item or their page
+	for shard, res := range pages {
+		switch {
+		case res == nil:
+		case merged[shard] == len(res.Items) && len(res.LastEvaluatedKey) == 0:
+			cursors[shard] = shardCursor{Done: true}
+		case merged[shard] == len(res.Items):
+			cursors[shard].Start = res.LastEvaluatedKey
+		case merged[shard] > 0:
+			last := res.Items[merged[shard]-1]
+			cursors[shard].Start = map[string]*dynamodb.AttributeValue{
+				"id":   last["id"],
+				"kind": {S: aws.String(nameShardKey(shard))},
+				"name": last["name"],
+			}
+		}
+	}
+	return items, nextShardsToken(cursors), nil
+}
+
+// Returns the name of a stored item
+func itemName(av map[string]*dynamodb.AttributeValue) string {
+	if name, ok := av["name"]; ok {
+		return aws.StringValue(name.S)
+	}
+	return ""
+}
+
+// Reports whether any of the paths may be offloaded to the payload store
+func offloadable(paths []string) bool {
+	for _, path := range paths {
+		if path == "details" || path == "details.description" || path == "attributes" || strings.HasPrefix(path, "attributes.") {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether any of the paths may be encrypted
+func encryptable(paths []string) bool {
+	for _, path := range paths {
+		if encrypted(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filters items by terms and sorts them by keys in memory
+func arrange(items []Item, terms []filter.Expr, keys []filter.SortKey) []Item {
+	var matched []Item
+	var docs []map[string]interface{}
+	for i := range items {
+		doc := flatten(&items[i])
+		ok := true
+		for _, term := range terms {
+			ok = ok && term.Eval(doc)
+		}
+		if ok {
+			matched = append(matched, items[i])
+			docs = append(docs, doc)
+		}
+	}
+	filter.Sort(docs, keys, func(i, j int) {
+		matched[i], matched[j] = matched[j], matched[i]
+	})
+	return matched
+}
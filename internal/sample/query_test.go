@@ -0,0 +1,239 @@
+package sample
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/nb-samples/aws-serverless-go/internal/filter"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client querying secondary indexes, name index queries return items of the shard
+type mockDdbQuery struct {
+	mockDdb
+	items []Item
+	mu    sync.Mutex
+	input *dynamodb.QueryInput
+	calls int
+}
+
+func (mock *mockDdbQuery) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.input = input
+	mock.calls++
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	output := new(dynamodb.QueryOutput)
+	for _, item := range mock.items {
+		if kind, ok := input.ExpressionAttributeValues[":kind"]; ok && *kind.S != nameShardKey(nameShard(item.ID)) {
+			continue
+		}
+		av, _ := dynamodbattribute.MarshalMap(item)
+		output.Items = append(output.Items, av)
+	}
+	output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"id": {S: aws.String("test-item-id")}}
+	return output, nil
+}
+
+func TestRepo_Query(t *testing.T) {
+	items := []Item{{ID: "test-item-id", Name: "test-item-name", SchemaVersion: ItemMigrations.Latest()}}
+	tests := []struct {
+		name          string
+		query         func(r *Repo) ([]Item, string, error)
+		wantIndex     string
+		wantCondition string
+		wantFilter    bool
+		wantErr       bool
+	}{
+		{
+			name:          "by name prefix",
+			query:         func(r *Repo) ([]Item, string, error) { return r.QueryByName("test", false, Page{}) },
+			wantIndex:     IndexByName,
+			wantCondition: "#kind = :kind AND begins_with(#name, :namePrefix)",
+			wantFilter:    true,
+		},
+		{
+			name:          "all names including deleted",
+			query:         func(r *Repo) ([]Item, string, error) { return r.QueryByName("", true, Page{}) },
+			wantIndex:     IndexByName,
+			wantCondition: "#kind = :kind",
+		},
+		{
+			name:          "by location",
+			query:         func(r *Repo) ([]Item, string, error) { return r.QueryByLocation("A1", "", false, Page{}) },
+			wantIndex:     IndexByLocation,
+			wantCondition: "#location = :location",
+			wantFilter:    true,
+		},
+		{
+			name:          "by location and name prefix",
+			query:         func(r *Repo) ([]Item, string, error) { return r.QueryByLocation("A1", "test", false, Page{}) },
+			wantIndex:     IndexByLocation,
+			wantCondition: "#location = :location AND begins_with(#name, :namePrefix)",
+			wantFilter:    true,
+		},
+		{
+			name:    "missing location",
+			query:   func(r *Repo) ([]Item, string, error) { return r.QueryByLocation("", "test", false, Page{}) },
+			wantErr: true,
+		},
+		{
+			name:    "invalid page token",
+			query:   func(r *Repo) ([]Item, string, error) { return r.QueryByName("", false, Page{Next: "%invalid%"}) },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDdbQuery{items: items}
+			got, next, err := tt.query(&Repo{Client: client, TableName: "mock-table"})
+
+			assert := assert.New(t)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			if assert.NoError(err) {
+				assert.Equal(items, got, "Items")
+				assert.NotEmpty(next, "Next page token")
+				assert.Equal(tt.wantIndex, aws.StringValue(client.input.IndexName), "Index")
+				assert.Equal(tt.wantCondition, aws.StringValue(client.input.KeyConditionExpression), "Key condition")
+				expr := aws.StringValue(client.input.FilterExpression)
+				assert.Equal(tt.wantFilter, strings.Contains(expr, "attribute_not_exists(deletedAt)"), "Soft deleted filter")
+				assert.Contains(expr, "#ttl > :now", "Expired filter")
+			}
+		})
+	}
+
+	_, _, err := (&Repo{Client: &mockDdbQuery{mockDdb: mockDdb{err: errors.New("Mock DynamoDB error")}}}).QueryByName("", false, Page{})
+	assert.Error(t, err, "Failed operation")
+}
+
+// Mock DynamoDB client paging name index shards in the order of names
+type mockDdbShards struct {
+	mockDdb
+	items []Item // ordered by names
+}
+
+func (mock *mockDdbShards) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	output := new(dynamodb.QueryOutput)
+	started := input.ExclusiveStartKey == nil
+	for _, item := range mock.items {
+		if *input.ExpressionAttributeValues[":kind"].S != nameShardKey(nameShard(item.ID)) {
+			continue
+		}
+		if !started {
+			started = item.ID == *input.ExclusiveStartKey["id"].S
+			continue
+		}
+		if int64(len(output.Items)) == *input.Limit {
+			last := output.Items[len(output.Items)-1]
+			output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"id": last["id"], "kind": input.ExpressionAttributeValues[":kind"], "name": last["name"]}
+			break
+		}
+		av, _ := dynamodbattribute.MarshalMap(item)
+		output.Items = append(output.Items, av)
+	}
+	return output, nil
+}
+
+func TestRepo_QueryShards(t *testing.T) {
+	var items []Item
+	for i := 0; i < 30; i++ {
+		items = append(items, Item{ID: fmt.Sprintf("item-%d", i), Name: fmt.Sprintf("name-%02d", i)})
+	}
+	r := &Repo{Client: &mockDdbShards{items: items}, TableName: "mock-table"}
+
+	var names []string
+	page := Page{Limit: 7}
+	for pages := 1; ; pages++ {
+		got, next, err := r.Query(Query{}, page)
+		if !assert.NoError(t, err) || !assert.LessOrEqual(t, len(got), 7, "Page size") {
+			return
+		}
+		for _, item := range got {
+			names = append(names, item.Name)
+		}
+		if next == "" || pages > len(items) {
+			break
+		}
+		page.Next = next
+	}
+
+	var want []string
+	for _, item := range items {
+		want = append(want, item.Name)
+	}
+	assert.Equal(t, want, names, "All items merged in the order of names")
+
+	_, _, err := r.Query(Query{}, Page{Next: nextPageToken(map[string]*dynamodb.AttributeValue{"id": {S: aws.String("item-1")}})})
+	assert.Equal(t, ErrInvalidPageToken, err, "Token of a single index")
+}
+
+func TestIndexAttributes(t *testing.T) {
+	assert := assert.New(t)
+
+	av := map[string]*dynamodb.AttributeValue{}
+	indexAttributes(Item{Name: "test-item-name", Details: Details{Location: "A1"}}, av)
+	assert.Equal(nameShardKey(nameShard("")), aws.StringValue(av["kind"].S), "Name index shard")
+	assert.Equal("A1", aws.StringValue(av["location"].S), "Location index")
+
+	av = map[string]*dynamodb.AttributeValue{}
+	indexAttributes(Item{Name: "test-item-name"}, av)
+	assert.NotContains(av, "location", "Without location")
+
+	av = map[string]*dynamodb.AttributeValue{}
+	indexAttributes(Item{Details: Details{Location: "A1"}}, av)
+	assert.Empty(av, "Without name")
+}
+
+func TestRepo_QueryFilter(t *testing.T) {
+	items := []Item{
+		{ID: "a", Name: "blue widget", Details: Details{Quantity: 1}},
+		{ID: "b", Name: "red gadget", Details: Details{Quantity: 3}},
+		{ID: "c", Name: "red widget", Details: Details{Quantity: 2}},
+	}
+	expr, err := ParseFilter(`quantity ge 1 and name endswith "widget"`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	client := &mockDdbQuery{items: items}
+	r := &Repo{Client: client, TableName: "mock-table"}
+	got, _, err := r.Query(Query{
+		Filter: expr,
+		Sort:   []filter.SortKey{{Field: "details.quantity", Desc: true}},
+		Fields: []string{"id"},
+	}, Page{})
+
+	assert := assert.New(t)
+	if assert.NoError(err) {
+		assert.Equal([]string{"c", "a"}, []string{got[0].ID, got[1].ID}, "Filtered in memory and sorted")
+		assert.Equal("attribute_not_exists(deletedAt) AND (attribute_not_exists(#ttl) OR #ttl > :now) AND #f0.#f1 >= :f1", aws.StringValue(client.input.FilterExpression), "Filter expression")
+		assert.Equal("#f2, #f3, #f0.#f1", aws.StringValue(client.input.ProjectionExpression), "Projection with evaluated fields")
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	expr, err := ParseFilter(`quantity > 5 and location eq "A1"`)
+	if assert.NoError(t, err) {
+		var fields []string
+		_ = filter.Walk(expr, func(c *filter.Compare) error {
+			fields = append(fields, c.Field)
+			return nil
+		})
+		assert.Equal(t, []string{"details.quantity", "details.location"}, fields, "Aliases resolved")
+	}
+
+	_, err = ParseFilter(`quantity > 5 and color eq "red"`)
+	assert.Equal(t, &filter.SyntaxError{Pos: 18, Msg: "unknown field color"}, err)
+}
@@ -1,11 +1,14 @@
 package sample
 
 import (
+	"context"
 	"errors"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
@@ -15,49 +18,270 @@ import (
 
 // Repo provides DynamoDB client capabilities
 type Repo struct {
-	Client    dynamodbiface.DynamoDBAPI
-	TableName string
+	Client               dynamodbiface.DynamoDBAPI
+	TableName            string
+	VersionTableName     string           // revisions of items (not kept if empty)
+	ReservationTableName string           // reservations of item quantities (not enabled if empty)
+	Retention            time.Duration    // soft deleted items and settled reservations are purged by DynamoDB TTL after (kept if zero)
+	Payloads             *PayloadStore    // claim-check store of large items (not offloaded if nil)
+	OffloadThreshold     int              // item size in bytes offloaded to the payload store (DefaultOffloadThreshold if zero)
+	Encryption           *FieldEncryption // encryption of sensitive item fields (stored as plaintext if nil)
+	NoLocationIndex      bool             // location queries filter the name index (location index not created yet)
+	Migrations           Migrations       // upgrades of items stored by previous schema versions on read (ItemMigrations if nil)
+	Retry                RetryPolicy      // retries of throttled operations (single attempt if zero)
+	ctx                  context.Context
 }
 
+// Repository errors
+var (
+	ErrNotFound = errors.New("Resource not found")
+	ErrConflict = errors.New("Resource doesn't exist or was modified concurrently")
+)
+
 // Repository returns a configured DynamoDB client
 func Repository(tableName string) *Repo {
 
-	sess := session.Must(session.NewSession())
+	// retries are controlled by the repository policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
 
 	return &Repo{
 		Client:    dynamodb.New(sess),
 		TableName: tableName,
+		Retry:     DefaultRetryPolicy,
+	}
+}
+
+// WithContext returns a shallow copy of the repository bound to the context (e.g. Lambda deadline)
+func (r *Repo) WithContext(ctx context.Context) *Repo {
+	repo := *r
+	repo.ctx = ctx
+	return &repo
+}
+
+// Returns the bound context or a background one
+func (r *Repo) context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
 	}
+	return context.Background()
 }
 
 // Save an item as a new database resource
 func (r *Repo) Save(item Item) (*Item, error) {
+	item = created(item, time.Now())
+
+	// versioned resources are never overwritten by UPSERT
+	var condition string
+	if r.VersionTableName != "" {
+		condition = "attribute_not_exists(id)"
+	}
+
+	// execute query
+	if err := r.put(item, condition, nil, nil); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Returns the item prepared to be saved as a new resource
+func created(item Item, now time.Time) Item {
 	if item.ID == "" { // generate a resource id
 		item.ID = uuid.New().String()
 	}
-	now := time.Now()     // set timestamp fields
 	item.CreatedAt = &now // reset create timestamp on UPSERT operations
 	item.UpdatedAt = &now // reset update timestamp on every change
+	item.Version = 1      // start revisions
+	item.DeletedAt = nil  // tombstone is managed by Delete and Undelete only
+	item.TTL = item.expiryTTL()
+	item.Details.Reserved = 0 // reserved quantity is managed by reservations only
+	item.Attachments = nil    // attachments are added by uploads only
+	return item
+}
+
+// Update an existing resource, the create timestamp is kept as provided.
+// Version of the item must match the stored one (optimistic locking) and gets incremented.
+func (r *Repo) Update(item Item) (*Item, error) {
+	if item.ID == "" {
+		return nil, errors.New("Missing resource ID")
+	}
+	now := time.Now()     // set timestamp fields
+	item.UpdatedAt = &now // reset update timestamp on every change
+	item.DeletedAt = nil  // soft deleted items are never updated
+	item.TTL = item.expiryTTL()
+
+	// expect the current version (missing for items created before versioning)
+	condition := "attribute_exists(id) AND attribute_not_exists(deletedAt) AND attribute_not_exists(#version)"
+	var values map[string]*dynamodb.AttributeValue
+	if item.Version > 0 {
+		condition = "attribute_not_exists(deletedAt) AND #version = :version"
+		values = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.Itoa(item.Version))},
+		}
+	}
+	item.Version++
+
+	// execute query
+	if err := r.put(item, condition, map[string]*string{"#version": aws.String("version")}, values); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// maxModifyAttempts limits rereads of an item changed concurrently while modified
+const maxModifyAttempts = 3
+
+// Applies the change to the current state of an existing resource and saves it by Update,
+// so the new state is recorded in the version history. The change is reapplied on conflicts.
+func (r *Repo) modify(itemID string, change func(item *Item) error) (*Item, error) {
+	for attempt := 1; ; attempt++ {
+		item, err := r.Get(itemID)
+		if err != nil {
+			return nil, err
+		}
+		if err = change(item); err != nil {
+			return nil, err
+		}
+		updated, err := r.Update(*item)
+		if err != ErrConflict || attempt == maxModifyAttempts {
+			return updated, err
+		}
+	}
+}
 
+// Writes the item and its version record (if versioning is enabled) under the condition
+func (r *Repo) put(item Item, condition string, names map[string]*string, values map[string]*dynamodb.AttributeValue) error {
 	// prepare query data
-	av, err := dynamodbattribute.MarshalMap(item)
+	av, version, err := r.marshal(item)
 	if err != nil {
-		log.Println("Failed to marshal:", err.Error())
-		return nil, err
+		return err
 	}
-	input := &dynamodb.PutItemInput{Item: av, TableName: &r.TableName}
 
 	// execute query
-	if _, err := r.Client.PutItem(input); err != nil {
+	if version == nil {
+		input := &dynamodb.PutItemInput{
+			Item:                      av,
+			TableName:                 &r.TableName,
+			ConditionExpression:       optional(condition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}
+		err = r.Retry.Do(r.context(), func() (err error) {
+			_, err = r.Client.PutItem(input)
+			return
+		})
+	} else {
+		err = r.Transact().
+			Put(r.TableName, av, Expr{Condition: condition, Names: names, Values: values}).
+			Put(r.VersionTableName, version, Expr{Condition: "attribute_not_exists(version)"}).
+			Commit()
+	}
+
+	if conditionFailed(err) {
+		r.discard(av)
+		return ErrConflict
+	} else if err != nil { // the item may be written, so its payload is kept
 		log.Println(err.Error())
-		return nil, errors.New("Failed to save into the repository")
+		return errors.New("Failed to save into the repository")
 	}
+	return nil
+}
 
-	return &item, nil
+// Deletes the offloaded payload of an item which was not written, failures leave it orphaned
+func (r *Repo) discard(av map[string]*dynamodb.AttributeValue) {
+	if r.Payloads == nil || av["payload"] == nil {
+		return
+	}
+	var ref PayloadRef
+	if err := dynamodbattribute.Unmarshal(av["payload"], &ref); err == nil {
+		_ = r.Payloads.WithContext(r.context()).Delete(ref)
+	}
+}
+
+// Marshals the item with its index attributes and its version record (nil if versioning is disabled)
+func (r *Repo) marshal(item Item) (av, version map[string]*dynamodb.AttributeValue, err error) {
+	item.SchemaVersion = r.migrations().Latest()
+	if r.Encryption != nil {
+		if item, err = r.Encryption.WithContext(r.context()).Encrypt(item); err != nil {
+			return nil, nil, err
+		}
+	}
+	if av, err = dynamodbattribute.MarshalMap(item); err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, nil, err
+	}
+	if r.Payloads != nil && ItemSize(av) > r.offloadThreshold() {
+		if item, err = r.Payloads.WithContext(r.context()).Offload(item); err != nil {
+			return nil, nil, err
+		}
+		if av, err = dynamodbattribute.MarshalMap(item); err != nil {
+			log.Println("Failed to marshal:", err.Error())
+			return nil, nil, err
+		}
+	}
+	indexAttributes(item, av)
+	if _, ok := av["details"]; !ok { // quantity adjustments need the document path
+		av["details"] = &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{}}
+	}
+
+	if r.VersionTableName != "" {
+		if version, err = dynamodbattribute.MarshalMap(ItemVersion{ItemID: item.ID, Version: item.Version, Item: item}); err != nil {
+			log.Println("Failed to marshal:", err.Error())
+			return nil, nil, err
+		}
+	}
+	return av, version, nil
+}
+
+// Returns the item size offloaded to the payload store
+func (r *Repo) offloadThreshold() int {
+	if r.OffloadThreshold > 0 {
+		return r.OffloadThreshold
+	}
+	return DefaultOffloadThreshold
+}
+
+// Restores content of the item offloaded to the payload store and decrypts its sensitive fields
+func (r *Repo) rehydrate(item *Item) error {
+	if item.Payload != nil {
+		if r.Payloads == nil {
+			return errors.New("Item payload store is not configured")
+		}
+		if err := r.Payloads.WithContext(r.context()).Rehydrate(item); err != nil {
+			return err
+		}
+	}
+	if item.Encryption != nil {
+		if r.Encryption == nil {
+			return errors.New("Item encryption is not configured")
+		}
+		return r.Encryption.WithContext(r.context()).Decrypt(item)
+	}
+	return nil
 }
 
-// Get an existing resource by ID
+// Reports whether a write failed on its condition check
+func conditionFailed(err error) bool {
+	var txErr *TransactionError
+	if errors.As(err, &txErr) {
+		for _, reason := range txErr.Reasons {
+			if reason.Code == ReasonConditionFailed {
+				return true
+			}
+		}
+		return false
+	}
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// Get an existing resource by ID, soft deleted items are not found
 func (r *Repo) Get(itemID string) (*Item, error) {
+	return r.Lookup(itemID, false)
+}
+
+// Lookup an existing resource by ID, optionally including soft deleted items.
+// Expired items are not found even before DynamoDB TTL deletes them.
+func (r *Repo) Lookup(itemID string, includeDeleted bool) (*Item, error) {
 	if itemID == "" {
 		return nil, errors.New("Missing resource ID")
 	}
@@ -73,47 +297,138 @@ func (r *Repo) Get(itemID string) (*Item, error) {
 	}
 
 	// execute query
-	res, err := r.Client.GetItem(input)
+	var res *dynamodb.GetItemOutput
+	err := r.Retry.Do(r.context(), func() (err error) {
+		res, err = r.Client.GetItem(input)
+		return
+	})
 	if err != nil {
 		log.Println(err.Error())
 		return nil, errors.New("Failed to read item from the repository")
 	} else if res.Item == nil {
-		return nil, errors.New("Resource not found")
+		return nil, ErrNotFound
 	}
 
 	// process query results
+	if err = r.upgrade(res.Item); err != nil {
+		return nil, err
+	}
 	var item Item
 	err = dynamodbattribute.UnmarshalMap(res.Item, &item)
 	if err != nil {
 		log.Println("Failed to unmarshal:", err.Error())
 		return nil, err
 	}
+	if item.DeletedAt != nil && !includeDeleted || item.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	if err = r.rehydrate(&item); err != nil {
+		return nil, err
+	}
 
 	return &item, nil
 }
 
-// Delete an existing resource by ID
-func (r *Repo) Delete(itemID string) error {
+// Delete an existing resource by ID. The item is kept as a tombstone until restored
+// or purged by DynamoDB TTL after the retention period (or at its expiry if earlier).
+func (r *Repo) Delete(itemID string) (*Item, error) {
+	if itemID == "" {
+		return nil, errors.New("Missing resource ID")
+	}
+
+	// prepare query data
+	now := time.Now()
+	deletedAt, err := dynamodbattribute.Marshal(now)
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	update := "SET deletedAt = :deletedAt"
+	values := map[string]*dynamodb.AttributeValue{":deletedAt": deletedAt}
+	condition := "attribute_exists(id) AND attribute_not_exists(deletedAt)"
+	if r.Retention > 0 {
+		// the purge time replaces an expiry only if earlier
+		names := map[string]*string{"#ttl": aws.String("ttl")}
+		ttlValues := map[string]*dynamodb.AttributeValue{
+			":deletedAt": deletedAt,
+			":ttl":       {N: aws.String(strconv.FormatInt(now.Add(r.Retention).Unix(), 10))},
+		}
+		item, err := r.update(itemID, update+", #ttl = :ttl", condition+" AND (attribute_not_exists(#ttl) OR #ttl > :ttl)", names, ttlValues)
+		if err != ErrNotFound {
+			return item, err
+		}
+	}
+
+	// execute query
+	return r.update(itemID, update, condition, nil, values)
+}
+
+// Undelete restores a soft deleted resource by ID before it is purged
+func (r *Repo) Undelete(itemID string) (*Item, error) {
 	if itemID == "" {
-		return errors.New("Missing resource ID")
+		return nil, errors.New("Missing resource ID")
 	}
 
 	// prepare query data
-	input := &dynamodb.DeleteItemInput{
+	updatedAt, err := dynamodbattribute.Marshal(time.Now())
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	values := map[string]*dynamodb.AttributeValue{":updatedAt": updatedAt}
+
+	// execute query
+	names := map[string]*string{"#ttl": aws.String("ttl")}
+	item, err := r.update(itemID, "SET updatedAt = :updatedAt REMOVE deletedAt, #ttl", "attribute_exists(deletedAt)", names, values)
+	if err != nil || item.ExpiresAt == nil {
+		return item, err
+	}
+
+	// the purge time is replaced by the expiry
+	values = map[string]*dynamodb.AttributeValue{":ttl": {N: aws.String(strconv.FormatInt(item.expiryTTL(), 10))}}
+	return r.update(itemID, "SET #ttl = :ttl", "attribute_exists(id) AND attribute_not_exists(deletedAt)", names, values)
+}
+
+// Updates attributes of an existing resource under the condition and returns its new state
+func (r *Repo) update(itemID, update, condition string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (*Item, error) {
+	input := &dynamodb.UpdateItemInput{
 		TableName: &r.TableName,
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
 				S: aws.String(itemID),
 			},
 		},
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeValues: values,
+		ExpressionAttributeNames:  names,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
 	}
 
 	// execute query
-	_, err := r.Client.DeleteItem(input)
-	if err != nil {
+	var res *dynamodb.UpdateItemOutput
+	err := r.Retry.Do(r.context(), func() (err error) {
+		res, err = r.Client.UpdateItem(input)
+		return
+	})
+	if conditionFailed(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
 		log.Println(err.Error())
-		return errors.New("Failed to delete item from the repository")
+		return nil, errors.New("Failed to update item in the repository")
 	}
 
-	return nil
+	// process query results
+	if err = r.upgrade(res.Attributes); err != nil {
+		return nil, err
+	}
+	var item Item
+	if err = dynamodbattribute.UnmarshalMap(res.Attributes, &item); err != nil {
+		log.Println("Failed to unmarshal:", err.Error())
+		return nil, err
+	}
+	if err = r.rehydrate(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
 }
@@ -2,8 +2,11 @@ package sample
 
 import (
 	"errors"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
@@ -28,6 +31,14 @@ func (mock *mockDdb) GetItem(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, e
 	return output, mock.err
 }
 
+func (mock *mockDdb) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	output := new(dynamodb.UpdateItemOutput)
+	if mock.item != nil {
+		output.Attributes, _ = dynamodbattribute.MarshalMap(&mock.item)
+	}
+	return output, mock.err
+}
+
 func (mock *mockDdb) DeleteItem(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
 	return nil, mock.err
 }
@@ -210,6 +221,15 @@ func TestRepo_Delete(t *testing.T) {
 			args:    args{itemID: ""},
 			wantErr: true,
 		},
+		{
+			name: "already deleted",
+			fields: fields{
+				Client:    &mockDdb{err: awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "Mock condition failure", nil)},
+				TableName: "mock-table",
+			},
+			args:    args{itemID: "test-item-id"},
+			wantErr: true,
+		},
 		{
 			name: "failed operation",
 			fields: fields{
@@ -227,7 +247,7 @@ func TestRepo_Delete(t *testing.T) {
 				TableName: tt.fields.TableName,
 			}
 
-			err := r.Delete(tt.args.itemID)
+			_, err := r.Delete(tt.args.itemID)
 
 			assert := assert.New(t)
 			if tt.wantErr {
@@ -239,3 +259,161 @@ func TestRepo_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestRepo_Update(t *testing.T) {
+	createdAt := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		client  dynamodbiface.DynamoDBAPI
+		item    Item
+		wantErr bool
+	}{
+		{
+			name:   "successful operation",
+			client: &mockDdb{},
+			item:   Item{ID: "test-item-id", Name: "test-item-name", CreatedAt: &createdAt},
+		},
+		{
+			name:    "missing ID",
+			client:  &mockDdb{},
+			item:    Item{Name: "test-item-name"},
+			wantErr: true,
+		},
+		{
+			name:    "item not found",
+			client:  &mockDdb{err: awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "Mock condition failure", nil)},
+			item:    Item{ID: "test-item-id"},
+			wantErr: true,
+		},
+		{
+			name:    "failed operation",
+			client:  &mockDdb{err: errors.New("Mock DynamoDB error")},
+			item:    Item{ID: "test-item-id"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Repo{Client: tt.client, TableName: "mock-table"}
+			got, err := r.Update(tt.item)
+			assert := assert.New(t)
+			if tt.wantErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(tt.item.ID, got.ID, "ID")
+				assert.Equal(tt.item.CreatedAt, got.CreatedAt, "CreatedAt")
+				if assert.NotNil(got.UpdatedAt, "UpdatedAt") {
+					assert.True(got.UpdatedAt.After(createdAt), "UpdatedAt")
+				}
+			}
+		})
+	}
+}
+
+// Mock DynamoDB client recording update requests
+type mockDdbUpdates struct {
+	mockDdb
+	input *dynamodb.UpdateItemInput
+}
+
+func (mock *mockDdbUpdates) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	mock.input = input
+	return mock.mockDdb.UpdateItem(input)
+}
+
+func TestRepo_SoftDelete(t *testing.T) {
+	deletedAt := time.Now()
+	tombstone := &Item{ID: "test-item-id", Name: "test-item-name", DeletedAt: &deletedAt}
+
+	assert := assert.New(t)
+	client := &mockDdbUpdates{mockDdb: mockDdb{item: tombstone}}
+	r := &Repo{Client: client, TableName: "mock-table", Retention: 24 * time.Hour}
+
+	got, err := r.Delete("test-item-id")
+	if assert.NoError(err, "Delete") {
+		assert.NotNil(got.DeletedAt, "Tombstone")
+		assert.Contains(*client.input.UpdateExpression, "#ttl = :ttl", "Purge scheduled")
+		ttl, _ := strconv.ParseInt(*client.input.ExpressionAttributeValues[":ttl"].N, 10, 64)
+		assert.InDelta(time.Now().Add(24*time.Hour).Unix(), ttl, 5, "Retention")
+	}
+
+	r.Retention = 0
+	if _, err = r.Delete("test-item-id"); assert.NoError(err, "Delete without retention") {
+		assert.NotContains(*client.input.UpdateExpression, "ttl", "Kept forever")
+	}
+
+	_, err = r.Get("test-item-id")
+	assert.Equal(ErrNotFound, err, "Soft deleted item is not found")
+	got, err = r.Lookup("test-item-id", true)
+	if assert.NoError(err, "Lookup including deleted") {
+		assert.Equal(tombstone.ID, got.ID)
+	}
+
+	client.item = &Item{ID: "test-item-id", Name: "test-item-name"}
+	got, err = r.Undelete("test-item-id")
+	if assert.NoError(err, "Undelete") {
+		assert.Nil(got.DeletedAt, "Tombstone removed")
+		assert.Contains(*client.input.UpdateExpression, "REMOVE deletedAt, #ttl")
+	}
+
+	client.err = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "Mock condition failure", nil)
+	_, err = r.Undelete("test-item-id")
+	assert.Equal(ErrNotFound, err, "Not deleted")
+	_, err = r.Undelete("")
+	assert.Error(err, "Missing ID")
+}
+
+// Mock DynamoDB client failing update conditions with queued outcomes
+type mockDdbConditional struct {
+	mockDdbUpdates
+	failures []bool
+}
+
+func (mock *mockDdbConditional) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	if len(mock.failures) > 0 {
+		fail := mock.failures[0]
+		mock.failures = mock.failures[1:]
+		if fail {
+			mock.input = input
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "Mock condition failure", nil)
+		}
+	}
+	return mock.mockDdbUpdates.UpdateItem(input)
+}
+
+func TestRepo_Expiry(t *testing.T) {
+	assert := assert.New(t)
+	expiresAt := time.Now().Add(time.Hour)
+	r := &Repo{Client: &mockDdb{}, TableName: "mock-table", Retention: 24 * time.Hour}
+
+	got, err := r.Save(Item{Name: "test-item-name", ExpiresAt: &expiresAt})
+	if assert.NoError(err, "Save") {
+		assert.Equal(expiresAt.Unix(), got.TTL, "Expiry TTL")
+	}
+	got, err = r.Save(Item{Name: "test-item-name"})
+	if assert.NoError(err, "Save without expiry") {
+		assert.Zero(got.TTL, "Never expires")
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	r.Client = &mockDdb{item: &Item{ID: "test-item-id", ExpiresAt: &expired, TTL: expired.Unix()}}
+	_, err = r.Get("test-item-id")
+	assert.Equal(ErrNotFound, err, "Expired item is hidden")
+	_, err = r.Lookup("test-item-id", true)
+	assert.Equal(ErrNotFound, err, "Expired item is hidden with deleted ones")
+
+	// expiry earlier than the purge time is kept on delete
+	client := &mockDdbConditional{mockDdbUpdates: mockDdbUpdates{mockDdb: mockDdb{item: &Item{ID: "test-item-id", DeletedAt: &expired}}}, failures: []bool{true}}
+	r.Client = client
+	if _, err = r.Delete("test-item-id"); assert.NoError(err, "Delete") {
+		assert.NotContains(*client.input.UpdateExpression, "#ttl", "Expiry kept")
+	}
+
+	// expiry replaces the purge time on undelete
+	client.item = &Item{ID: "test-item-id", ExpiresAt: &expiresAt}
+	if got, err = r.Undelete("test-item-id"); assert.NoError(err, "Undelete") {
+		assert.Equal("SET #ttl = :ttl", *client.input.UpdateExpression, "Expiry restored")
+		assert.Equal(strconv.FormatInt(expiresAt.Unix(), 10), *client.input.ExpressionAttributeValues[":ttl"].N)
+	}
+}
@@ -0,0 +1,120 @@
+package sample
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// Clock provides current time and timers to the retry policy
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is a real time clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RetryPolicy defines how failed AWS operations are retried
+type RetryPolicy struct {
+	MaxAttempts int            // maximum number of attempts including the first one
+	BaseDelay   time.Duration  // delay before the first retry, doubled for every next one
+	MaxDelay    time.Duration  // upper limit of a single delay (unlimited if zero)
+	Jitter      float64        // fraction of a delay to randomise, from 0 to 1
+	Clock       Clock          // time source (system clock if nil)
+	Rand        func() float64 // random numbers in [0, 1) (math/rand if nil)
+}
+
+// DefaultRetryPolicy is used by configured repository and topic clients
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   50 * time.Millisecond,
+	MaxDelay:    time.Second,
+	Jitter:      0.5,
+}
+
+// errRetry marks partially failed operations which should be retried
+var errRetry = errors.New("Operation partially failed")
+
+// retryableCodes are AWS error codes of transient failures
+var retryableCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true, // DynamoDB
+	"RequestLimitExceeded":                   true, // DynamoDB
+	"ThrottlingException":                    true, // DynamoDB
+	"TransactionConflictException":           true, // DynamoDB
+	"InternalServerError":                    true, // DynamoDB
+	"Throttled":                              true, // SNS
+	"Throttling":                             true, // SNS
+	"InternalError":                          true, // SNS
+	"KMSThrottling":                          true, // SNS
+	"InternalFailure":                        true, // EventBridge
+	"SlowDown":                               true, // S3
+	"ServiceUnavailable":                     true,
+	"RequestTimeout":                         true,
+}
+
+// IsRetryable reports whether the error is transient and the operation may succeed on retry
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, errRetry) {
+		return true
+	}
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		return retryableCodes[aerr.Code()]
+	}
+	return false
+}
+
+// Delay returns a backoff delay before the given retry (starting with 1)
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay == 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		random := rand.Float64
+		if p.Rand != nil {
+			random = p.Rand
+		}
+		delay -= time.Duration(p.Jitter * random() * float64(delay))
+	}
+	return delay
+}
+
+// Do runs the operation until it succeeds or fails with a non-retryable error.
+// Retries stop when attempts are exhausted or the next one would not start before the context deadline.
+func (p RetryPolicy) Do(ctx context.Context, op func() error) error {
+	clock := p.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= p.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+
+		delay := p.Delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && !clock.Now().Add(delay).Before(deadline) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-clock.After(delay):
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package sample
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock clock advancing on every timer without waiting
+type mockClock struct {
+	now    time.Time
+	delays []time.Duration
+}
+
+func (mock *mockClock) Now() time.Time {
+	return mock.now
+}
+
+func (mock *mockClock) After(d time.Duration) <-chan time.Time {
+	mock.delays = append(mock.delays, d)
+	mock.now = mock.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- mock.now
+	return ch
+}
+
+// Mock DynamoDB client failing a number of times before success
+type mockFlakyDdb struct {
+	mockDdb
+	failures []error
+	calls    int
+}
+
+func (mock *mockFlakyDdb) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	mock.calls++
+	if mock.calls <= len(mock.failures) {
+		return nil, mock.failures[mock.calls-1]
+	}
+	return mock.mockDdb.PutItem(input)
+}
+
+var errThrottled = awserr.New("ProvisionedThroughputExceededException", "Mock DynamoDB throttling", nil)
+
+func TestIsRetryable(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(IsRetryable(errThrottled), "DynamoDB throttling")
+	assert.True(IsRetryable(awserr.New("Throttled", "Mock SNS throttling", nil)), "SNS throttling")
+	assert.True(IsRetryable(errRetry), "Partial failure")
+	assert.False(IsRetryable(awserr.New("ValidationException", "Mock validation error", nil)), "Validation error")
+	assert.False(IsRetryable(errors.New("Mock error")), "Generic error")
+	assert.False(IsRetryable(nil), "No error")
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	assert := assert.New(t)
+	assert.Equal(100*time.Millisecond, policy.Delay(1), "First retry")
+	assert.Equal(400*time.Millisecond, policy.Delay(3), "Third retry")
+	assert.Equal(time.Second, policy.Delay(10), "Capped delay")
+
+	policy.Jitter = 0.5
+	policy.Rand = func() float64 { return 0.5 }
+	assert.Equal(75*time.Millisecond, policy.Delay(1), "Jitter")
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   []error
+		deadline   time.Duration
+		wantErr    bool
+		wantCalls  int
+		wantDelays []time.Duration
+	}{
+		{
+			name:      "success",
+			wantCalls: 1,
+		},
+		{
+			name:       "success after throttling",
+			failures:   []error{errThrottled, errThrottled},
+			wantCalls:  3,
+			wantDelays: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+		},
+		{
+			name:       "attempts exhausted",
+			failures:   []error{errThrottled, errThrottled, errThrottled, errThrottled},
+			wantErr:    true,
+			wantCalls:  3,
+			wantDelays: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond},
+		},
+		{
+			name:      "non-retryable error",
+			failures:  []error{errors.New("Mock error")},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:       "deadline exceeded",
+			failures:   []error{errThrottled, errThrottled},
+			deadline:   25 * time.Millisecond,
+			wantErr:    true,
+			wantCalls:  2,
+			wantDelays: []time.Duration{10 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &mockClock{now: time.Now()}
+			policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, Clock: clock}
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, clock.now.Add(tt.deadline))
+				defer cancel()
+			}
+
+			calls := 0
+			err := policy.Do(ctx, func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+
+			assert := assert.New(t)
+			if tt.wantErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+			assert.Equal(tt.wantCalls, calls, "Attempts")
+			assert.Equal(tt.wantDelays, clock.delays, "Delays")
+		})
+	}
+}
+
+func TestRepo_SaveRetry(t *testing.T) {
+	client := &mockFlakyDdb{failures: []error{errThrottled, errThrottled}}
+	repo := &Repo{
+		Client:    client,
+		TableName: "mock-table",
+		Retry:     RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Clock: &mockClock{}},
+	}
+
+	_, err := repo.Save(Item{Name: "test-item-name"})
+
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Equal(3, client.calls, "PutItem calls")
+}
@@ -0,0 +1,291 @@
+package sample
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ScanOptions control a full scan of the items table
+type ScanOptions struct {
+	Segments       int                        // parallel segments of the table (1 if zero)
+	Concurrency    int                        // segments scanned at once (all if zero)
+	ReadCapacity   float64                    // read capacity units consumed per second by all segments (unlimited if zero)
+	PageSize       int                        // items evaluated per request (1 MB pages if zero)
+	IncludeDeleted bool                       // include soft deleted and expired items (e.g. migrations)
+	Checkpoint     *ScanCheckpoint            // progress of a previous scan to resume (scan from the start if nil)
+	OnCheckpoint   func(ScanCheckpoint) error // receives the progress after every processed page (e.g. to persist it)
+	Clock          Clock                      // time source of the rate limit (system clock if nil)
+}
+
+// ScanCheckpoint is the progress of a scan by segment. A resumed scan continues after the pages
+// processed before the checkpoint, so pages processed after it are processed again.
+type ScanCheckpoint struct {
+	Segments []SegmentPosition `json:"segments"`
+}
+
+// SegmentPosition is the progress of a single segment
+type SegmentPosition struct {
+	Next string `json:"next,omitempty"` // token of the next page (first page if empty)
+	Done bool   `json:"done,omitempty"`
+}
+
+// Done reports whether all segments were scanned
+func (c ScanCheckpoint) Done() bool {
+	for _, pos := range c.Segments {
+		if !pos.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns a copy of the checkpoint safe to keep by callbacks
+func (c ScanCheckpoint) copy() ScanCheckpoint {
+	return ScanCheckpoint{Segments: append([]SegmentPosition{}, c.Segments...)}
+}
+
+// Returns the checkpoint the scan starts from
+func (o ScanOptions) start() (ScanCheckpoint, error) {
+	if o.Checkpoint != nil {
+		if len(o.Checkpoint.Segments) == 0 {
+			return ScanCheckpoint{}, errors.New("Invalid scan checkpoint")
+		}
+		if o.Segments > 0 && o.Segments != len(o.Checkpoint.Segments) {
+			return ScanCheckpoint{}, fmt.Errorf("Checkpoint of %d segments can't resume a scan of %d", len(o.Checkpoint.Segments), o.Segments)
+		}
+		return o.Checkpoint.copy(), nil
+	}
+	segments := o.Segments
+	if segments < 1 {
+		segments = 1
+	}
+	return ScanCheckpoint{Segments: make([]SegmentPosition, segments)}, nil
+}
+
+// ScanAll reads all items of the table by parallel segments and returns the progress of the scan.
+// The callback receives pages of items of a segment, pages of different segments concurrently.
+// Scanning stops at the first failed segment or callback, the returned checkpoint resumes it.
+func (r *Repo) ScanAll(opts ScanOptions, fn func(segment int, items []Item) error) (ScanCheckpoint, error) {
+	return r.scan(opts, func(segment int, page []map[string]*dynamodb.AttributeValue) error {
+		for _, av := range page {
+			if err := r.upgrade(av); err != nil {
+				return err
+			}
+		}
+		var items []Item
+		if err := dynamodbattribute.UnmarshalListOfMaps(page, &items); err != nil {
+			log.Println("Failed to unmarshal:", err.Error())
+			return err
+		}
+		for i := range items {
+			if err := r.rehydrate(&items[i]); err != nil {
+				return err
+			}
+		}
+		return fn(segment, items)
+	})
+}
+
+// Scans the items table by parallel segments passing pages of stored items to the callback
+func (r *Repo) scan(opts ScanOptions, fn func(segment int, page []map[string]*dynamodb.AttributeValue) error) (ScanCheckpoint, error) {
+	progress, err := opts.start()
+	if err != nil {
+		return progress, err
+	}
+	workers := opts.Concurrency
+	if workers <= 0 || workers > len(progress.Segments) {
+		workers = len(progress.Segments)
+	}
+	ctx, cancel := context.WithCancel(r.context())
+	defer cancel()
+	repo := r.WithContext(ctx)
+	limiter := newRateLimiter(opts.ReadCapacity, opts.Clock)
+
+	pending := make(chan int, len(progress.Segments))
+	for segment, pos := range progress.Segments {
+		if !pos.Done {
+			pending <- segment
+		}
+	}
+	close(pending)
+
+	var mu sync.Mutex
+	var first error
+	checkpoint := func(segment int, pos SegmentPosition) error {
+		mu.Lock()
+		defer mu.Unlock()
+		progress.Segments[segment] = pos
+		if opts.OnCheckpoint != nil {
+			return opts.OnCheckpoint(progress.copy())
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for segment := range pending {
+				mu.Lock()
+				pos := progress.Segments[segment]
+				mu.Unlock()
+				err := repo.scanSegment(segment, len(progress.Segments), pos, opts, limiter, fn, checkpoint)
+				if err != nil {
+					mu.Lock()
+					if first == nil {
+						first = err
+					}
+					mu.Unlock()
+					cancel() // other segments stop at their next page
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return progress, first
+}
+
+// Scans a single segment of the items table page by page from the position
+func (r *Repo) scanSegment(segment, segments int, pos SegmentPosition, opts ScanOptions, limiter *rateLimiter,
+	fn func(int, []map[string]*dynamodb.AttributeValue) error, checkpoint func(int, SegmentPosition) error) error {
+	startKey, err := Page{Next: pos.Next}.startKey(keyAttr{name: "id"})
+	if err != nil {
+		return err
+	}
+
+	// prepare query data
+	input := &dynamodb.ScanInput{
+		TableName:              &r.TableName,
+		Segment:                aws.Int64(int64(segment)),
+		TotalSegments:          aws.Int64(int64(segments)),
+		ExclusiveStartKey:      startKey,
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	if opts.PageSize > 0 {
+		input.Limit = aws.Int64(int64(opts.PageSize))
+	}
+	if !opts.IncludeDeleted { // expired items are hidden until DynamoDB TTL deletes them
+		input.FilterExpression = aws.String("attribute_not_exists(deletedAt) AND (attribute_not_exists(#ttl) OR #ttl > :now)")
+		input.ExpressionAttributeNames = map[string]*string{"#ttl": aws.String("ttl")}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))},
+		}
+	}
+
+	for {
+		if err := limiter.wait(r.context()); err != nil {
+			return err
+		}
+
+		// execute query
+		var res *dynamodb.ScanOutput
+		err := r.Retry.Do(r.context(), func() (err error) {
+			res, err = r.Client.Scan(input)
+			return
+		})
+		if err != nil {
+			log.Println(err.Error())
+			return errors.New("Failed to scan the repository")
+		}
+		if res.ConsumedCapacity != nil {
+			limiter.take(aws.Float64Value(res.ConsumedCapacity.CapacityUnits))
+		}
+
+		// process query results
+		if len(res.Items) > 0 {
+			if err = fn(segment, res.Items); err != nil {
+				return err
+			}
+		}
+
+		next := nextPageToken(res.LastEvaluatedKey)
+		if err = checkpoint(segment, SegmentPosition{Next: next, Done: next == ""}); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		input.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+}
+
+// rateLimiter is a token bucket of read capacity units shared by segments. Consumed capacity is known
+// after a request only, so the bucket may go into debt which the next requests wait out.
+type rateLimiter struct {
+	rate   float64 // units per second (unlimited if zero)
+	clock  Clock
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Returns a limiter of the rate (units per second)
+func newRateLimiter(rate float64, clock Clock) *rateLimiter {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	l := &rateLimiter{rate: rate, clock: clock, last: clock.Now()}
+	l.tokens = l.burst()
+	return l
+}
+
+// Returns the units available at once, a second of the rate and at least a request
+func (l *rateLimiter) burst() float64 {
+	if l.rate < 1 {
+		return 1
+	}
+	return l.rate
+}
+
+// Waits until a unit is available for the next request
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.rate <= 0 {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		l.refill()
+		tokens := l.tokens
+		l.mu.Unlock()
+		if tokens >= 1 {
+			return nil
+		}
+		delay := time.Duration((1 - tokens) / l.rate * float64(time.Second))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.clock.After(delay):
+		}
+	}
+}
+
+// Takes the consumed units from the bucket
+func (l *rateLimiter) take(units float64) {
+	if l.rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens -= units
+}
+
+// Adds units accrued since the last refill up to the burst
+func (l *rateLimiter) refill() {
+	now := l.clock.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst() {
+		l.tokens = l.burst()
+	}
+	l.last = now
+}
@@ -0,0 +1,162 @@
+package sample
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client scanning items by segments and pages, a unit of capacity per item
+type mockDdbSegments struct {
+	mockDdb
+	items []Item
+	mu    sync.Mutex
+	scans int
+}
+
+func (mock *mockDdbSegments) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	mock.mu.Lock()
+	mock.scans++
+	mock.mu.Unlock()
+
+	var segment []Item
+	for i, item := range mock.items {
+		if int64(i)%*input.TotalSegments == *input.Segment {
+			segment = append(segment, item)
+		}
+	}
+	start, end := 0, len(segment)
+	for i, item := range segment {
+		if input.ExclusiveStartKey != nil && item.ID == *input.ExclusiveStartKey["id"].S {
+			start = i + 1
+		}
+	}
+	if input.Limit != nil && start+int(*input.Limit) < end {
+		end = start + int(*input.Limit)
+	}
+
+	output := &dynamodb.ScanOutput{ConsumedCapacity: &dynamodb.ConsumedCapacity{CapacityUnits: aws.Float64(float64(end - start))}}
+	for _, item := range segment[start:end] {
+		av, _ := dynamodbattribute.MarshalMap(item)
+		output.Items = append(output.Items, av)
+	}
+	if end < len(segment) {
+		output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"id": {S: aws.String(segment[end-1].ID)}}
+	}
+	return output, nil
+}
+
+// Returns a mock client of the number of items
+func newMockDdbSegments(n int) *mockDdbSegments {
+	mock := &mockDdbSegments{}
+	for i := 0; i < n; i++ {
+		mock.items = append(mock.items, Item{ID: fmt.Sprintf("item-%02d", i)})
+	}
+	return mock
+}
+
+func TestRepo_ScanAll(t *testing.T) {
+	client := newMockDdbSegments(10)
+	r := &Repo{Client: client, TableName: "mock-table"}
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	var checkpoints []ScanCheckpoint
+	progress, err := r.ScanAll(ScanOptions{
+		Segments:    3,
+		Concurrency: 2,
+		PageSize:    2,
+		OnCheckpoint: func(c ScanCheckpoint) error {
+			checkpoints = append(checkpoints, c)
+			return nil
+		},
+	}, func(segment int, items []Item) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, item := range items {
+			seen[item.ID]++
+		}
+		return nil
+	})
+
+	assert := assert.New(t)
+	if assert.NoError(err) {
+		assert.Len(seen, 10, "All items")
+		for id, n := range seen {
+			assert.Equal(1, n, id)
+		}
+		assert.True(progress.Done(), "Completed")
+		assert.Len(checkpoints, client.scans, "Checkpoint per page")
+		assert.False(checkpoints[0].Done())
+	}
+}
+
+func TestRepo_ScanAllResume(t *testing.T) {
+	client := newMockDdbSegments(10)
+	r := &Repo{Client: client, TableName: "mock-table"}
+	opts := ScanOptions{Segments: 2, Concurrency: 1, PageSize: 2}
+
+	var seen []string
+	failing := true
+	fn := func(segment int, items []Item) error {
+		for _, item := range items {
+			if item.ID == "item-05" && failing {
+				return errors.New("Mock processing error")
+			}
+		}
+		for _, item := range items {
+			seen = append(seen, item.ID)
+		}
+		return nil
+	}
+
+	assert := assert.New(t)
+	progress, err := r.ScanAll(opts, fn)
+	if !assert.Error(err, "Failed page") {
+		return
+	}
+	assert.True(progress.Segments[0].Done, "Segment completed before")
+	assert.NotEmpty(progress.Segments[1].Next, "Position of the failed segment")
+	assert.Len(seen, 7, "Pages before the failure")
+
+	failing = false
+	opts.Checkpoint = &progress
+	progress, err = r.ScanAll(opts, fn)
+	if assert.NoError(err, "Resumed") {
+		assert.True(progress.Done())
+		var ids []string
+		for _, item := range client.items {
+			ids = append(ids, item.ID)
+		}
+		assert.ElementsMatch(ids, seen, "Remaining items only")
+	}
+
+	opts.Segments = 3
+	_, err = r.ScanAll(opts, fn)
+	assert.Error(err, "Checkpoint of other segments")
+}
+
+func TestRepo_ScanAllRateLimit(t *testing.T) {
+	client := newMockDdbSegments(10)
+	clock := &mockClock{now: time.Now()}
+	r := &Repo{Client: client, TableName: "mock-table"}
+
+	_, err := r.ScanAll(ScanOptions{PageSize: 2, ReadCapacity: 2, Clock: clock}, func(int, []Item) error { return nil })
+
+	assert := assert.New(t)
+	if assert.NoError(err) {
+		var waited time.Duration
+		for _, d := range clock.delays {
+			waited += d
+		}
+		// 10 units at 2 per second after a burst of 2, the last request is not waited out
+		assert.Equal(3500*time.Millisecond, waited)
+	}
+}
@@ -0,0 +1,136 @@
+package sample
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/nb-samples/aws-serverless-go/internal/search"
+)
+
+// SearchBoosts are field weights of the item search, names weigh more than descriptions
+var SearchBoosts = map[string]float64{"name": 2}
+
+// Returns the searchable document of the item
+func searchDocument(item Item) search.Document {
+	return search.Document{
+		ID: item.ID,
+		Fields: map[string]string{
+			"name":        item.Name,
+			"description": item.Details.Description,
+		},
+	}
+}
+
+// TextIndex keeps items searchable by words of their name and description
+type TextIndex struct {
+	Documents *search.Index
+}
+
+// Index adds or replaces the item
+func (ix TextIndex) Index(item Item) error {
+	ix.Documents.Add(searchDocument(item))
+	return nil
+}
+
+// Remove the item
+func (ix TextIndex) Remove(itemID string) error {
+	ix.Documents.Remove(itemID)
+	return nil
+}
+
+// SearchSnapshot provides S3 client capabilities for snapshots of the search index
+type SearchSnapshot struct {
+	Client s3iface.S3API
+	Bucket string
+	Key    string
+	Retry  RetryPolicy // retries of throttled operations (single attempt if zero)
+	ctx    context.Context
+}
+
+// Snapshot returns a configured S3 client for snapshots of the search index
+func Snapshot(bucket, key string) *SearchSnapshot {
+
+	// retries are controlled by the snapshot policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &SearchSnapshot{
+		Client: s3.New(sess),
+		Bucket: bucket,
+		Key:    key,
+		Retry:  DefaultRetryPolicy,
+	}
+}
+
+// WithContext returns a shallow copy of the snapshot bound to the context (e.g. Lambda deadline)
+func (s *SearchSnapshot) WithContext(ctx context.Context) *SearchSnapshot {
+	snapshot := *s
+	snapshot.ctx = ctx
+	return &snapshot
+}
+
+// Returns the bound context or a background one
+func (s *SearchSnapshot) context() context.Context {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	return context.Background()
+}
+
+// Load the search index from the snapshot, the index is empty if there is no snapshot yet
+func (s *SearchSnapshot) Load() (*search.Index, error) {
+	input := &s3.GetObjectInput{Bucket: &s.Bucket, Key: &s.Key}
+
+	// execute query
+	var res *s3.GetObjectOutput
+	err := s.Retry.Do(s.context(), func() (err error) {
+		res, err = s.Client.GetObject(input)
+		return
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return search.New(SearchBoosts), nil
+	} else if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to read search index snapshot")
+	}
+	defer res.Body.Close()
+
+	ix, err := search.Load(res.Body, SearchBoosts)
+	if err != nil {
+		log.Println("Failed to decode:", err.Error())
+		return nil, errors.New("Invalid search index snapshot")
+	}
+	return ix, nil
+}
+
+// Save the search index as the snapshot
+func (s *SearchSnapshot) Save(ix *search.Index) error {
+	var buf bytes.Buffer
+	if err := ix.Save(&buf); err != nil {
+		log.Println("Failed to encode:", err.Error())
+		return err
+	}
+
+	// execute query
+	err := s.Retry.Do(s.context(), func() (err error) {
+		_, err = s.Client.PutObject(&s3.PutObjectInput{
+			Bucket:      &s.Bucket,
+			Key:         &s.Key,
+			Body:        bytes.NewReader(buf.Bytes()),
+			ContentType: aws.String("application/json"),
+		})
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return errors.New("Failed to write search index snapshot")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package sample
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/nb-samples/aws-serverless-go/internal/search"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock S3 client keeping objects in memory
+type mockS3 struct {
+	s3iface.S3API
+	objects map[string][]byte
+	err     error
+}
+
+func (mock *mockS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	b, ok := mock.objects[*input.Key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "Mock missing object", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func (mock *mockS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	b, _ := ioutil.ReadAll(input.Body)
+	mock.objects[*input.Key] = b
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestSearchSnapshot(t *testing.T) {
+	client := &mockS3{objects: map[string][]byte{}}
+	s := &SearchSnapshot{Client: client, Bucket: "mock-bucket", Key: "search/items.json"}
+
+	assert := assert.New(t)
+	ix, err := s.Load()
+	if !assert.NoError(err, "Missing snapshot") {
+		return
+	}
+	assert.Equal(0, ix.Len(), "Empty index")
+
+	text := TextIndex{Documents: ix}
+	assert.NoError(text.Index(Item{ID: "1", Name: "Blue widget", Details: Details{Description: "Small"}}))
+	assert.NoError(text.Index(Item{ID: "2", Name: "Red gadget", Details: Details{Description: "Works with widgets"}}))
+	assert.NoError(text.Remove("2"))
+	assert.NoError(s.Save(ix))
+
+	loaded, err := s.Load()
+	if assert.NoError(err, "Saved snapshot") {
+		hits := loaded.Search("widget", 10)
+		if assert.Len(hits, 1) {
+			assert.Equal("1", hits[0].ID)
+			assert.Equal("Blue <em>widget</em>", hits[0].Snippets["name"])
+		}
+	}
+
+	client.objects["search/items.json"] = []byte("not json")
+	_, err = s.Load()
+	assert.Error(err, "Invalid snapshot")
+
+	client.err = errors.New("Mock S3 error")
+	_, err = s.Load()
+	assert.Error(err, "Failed read")
+	assert.Error(s.Save(search.New(nil)), "Failed write")
+}
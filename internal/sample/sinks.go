@@ -0,0 +1,77 @@
+package sample
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// PublisherSink publishes item lifecycle events of captured changes
+type PublisherSink struct {
+	Publisher Publisher
+	Events    []Event // published events (all if empty)
+}
+
+// Handle publishes the change as an item event
+func (s PublisherSink) Handle(ctx context.Context, change Change) error {
+	event := change.Event()
+	if !s.publishes(event) {
+		return nil
+	}
+	_, err := s.Publisher.PublishEvent(event, *change.Item())
+	return err
+}
+
+// Reports whether the event is published by the sink
+func (s PublisherSink) publishes(event Event) bool {
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return len(s.Events) == 0
+}
+
+// AuditLogSink writes captured changes as JSON lines to the log
+type AuditLogSink struct {
+	Logger *log.Logger // destination (standard logger if nil)
+}
+
+// Handle writes the change to the log, sensitive fields are redacted
+func (s AuditLogSink) Handle(ctx context.Context, change Change) error {
+	for _, image := range []**Item{&change.Old, &change.New} {
+		if *image != nil {
+			item := Redact(**image)
+			*image = &item
+		}
+	}
+	b, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	if s.Logger != nil {
+		s.Logger.Println("CHANGE:", string(b))
+	} else {
+		log.Println("CHANGE:", string(b))
+	}
+	return nil
+}
+
+// SearchIndex is kept up to date with item changes
+type SearchIndex interface {
+	Index(item Item) error
+	Remove(itemID string) error
+}
+
+// SearchIndexSink applies captured changes to a search index
+type SearchIndexSink struct {
+	Index SearchIndex
+}
+
+// Handle indexes new item states and removes deleted items
+func (s SearchIndexSink) Handle(ctx context.Context, change Change) error {
+	if change.Type == ChangeRemove || change.New.DeletedAt != nil {
+		return s.Index.Remove(change.Item().ID)
+	}
+	return s.Index.Index(*change.New)
+}
@@ -0,0 +1,78 @@
+package sample
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock search index recording indexed and removed items
+type mockSearchIndex struct {
+	indexed []string
+	removed []string
+}
+
+func (mock *mockSearchIndex) Index(item Item) error {
+	mock.indexed = append(mock.indexed, item.ID)
+	return nil
+}
+
+func (mock *mockSearchIndex) Remove(itemID string) error {
+	mock.removed = append(mock.removed, itemID)
+	return nil
+}
+
+// Mock publisher recording events
+type mockPublisher struct {
+	events []Event
+	err    error
+}
+
+func (mock *mockPublisher) PublishEvent(event Event, item Item) (string, error) {
+	mock.events = append(mock.events, event)
+	return "test-message-id", mock.err
+}
+
+func TestSinks(t *testing.T) {
+	ctx := context.Background()
+	insert := Change{Type: ChangeInsert, New: &Item{ID: "inserted"}}
+	remove := Change{Type: ChangeRemove, Old: &Item{ID: "removed"}}
+	deletedAt := time.Now()
+	softDelete := Change{Type: ChangeModify, Old: &Item{ID: "deleted"}, New: &Item{ID: "deleted", DeletedAt: &deletedAt}}
+
+	assert := assert.New(t)
+
+	publisher := &mockPublisher{}
+	assert.NoError(PublisherSink{Publisher: publisher}.Handle(ctx, insert))
+	assert.NoError(PublisherSink{Publisher: publisher}.Handle(ctx, remove))
+	assert.Equal([]Event{ItemCreated, ItemDeleted}, publisher.events, "Published events")
+
+	publisher = &mockPublisher{}
+	expire := Change{Type: ChangeRemove, Old: remove.Old, TTL: true}
+	ttl := PublisherSink{Publisher: publisher, Events: []Event{ItemExpired, ItemPurged}}
+	assert.NoError(ttl.Handle(ctx, insert))
+	assert.NoError(ttl.Handle(ctx, expire))
+	assert.Equal([]Event{ItemExpired}, publisher.events, "Published TTL events only")
+
+	index := &mockSearchIndex{}
+	assert.NoError(SearchIndexSink{Index: index}.Handle(ctx, insert))
+	assert.NoError(SearchIndexSink{Index: index}.Handle(ctx, remove))
+	assert.NoError(SearchIndexSink{Index: index}.Handle(ctx, softDelete))
+	assert.Equal([]string{"inserted"}, index.indexed, "Indexed items")
+	assert.Equal([]string{"removed", "deleted"}, index.removed, "Removed items")
+
+	var buf bytes.Buffer
+	assert.NoError(AuditLogSink{Logger: log.New(&buf, "", 0)}.Handle(ctx, remove))
+	assert.Contains(buf.String(), `"type":"REMOVE"`, "Audit log")
+	assert.Contains(buf.String(), `"id":"removed"`, "Audit log")
+
+	buf.Reset()
+	located := Change{Type: ChangeInsert, New: &Item{ID: "located", Details: Details{Location: "secret"}}}
+	assert.NoError(AuditLogSink{Logger: log.New(&buf, "", 0)}.Handle(ctx, located))
+	assert.NotContains(buf.String(), "secret", "Redacted location")
+	assert.Equal("secret", located.New.Details.Location, "Change kept")
+}
@@ -0,0 +1,194 @@
+package sample
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ChangeType classifies a table change
+type ChangeType string
+
+// Table change types of DynamoDB Streams
+const (
+	ChangeInsert ChangeType = "INSERT"
+	ChangeModify ChangeType = "MODIFY"
+	ChangeRemove ChangeType = "REMOVE"
+)
+
+// Change of an item captured from DynamoDB Streams
+type Change struct {
+	Type           ChangeType  `json:"type"`
+	EventID        string      `json:"eventId"`
+	SequenceNumber string      `json:"sequenceNumber"`
+	Time           time.Time   `json:"time"`
+	Old            *Item       `json:"old,omitempty"` // state before MODIFY and REMOVE
+	New            *Item       `json:"new,omitempty"` // state after INSERT and MODIFY
+	TTL            bool        `json:"ttl,omitempty"` // REMOVE by DynamoDB TTL
+	OldPayload     *PayloadRef `json:"-"`             // claim check of the old image content (resolved in Old)
+	NewPayload     *PayloadRef `json:"-"`             // claim check of the new image content (resolved in New)
+}
+
+// ttlPrincipal is the principal of stream records of items deleted by DynamoDB TTL
+const ttlPrincipal = "dynamodb.amazonaws.com"
+
+// Event returns the item lifecycle event of the change
+func (c *Change) Event() Event {
+	switch c.Type {
+	case ChangeInsert:
+		return ItemCreated
+	case ChangeRemove:
+		switch {
+		case c.Old != nil && c.Old.DeletedAt != nil:
+			return ItemPurged
+		case c.TTL:
+			return ItemExpired
+		}
+		return ItemDeleted
+	}
+	// soft delete and restore modify the tombstone
+	switch deleted := c.New != nil && c.New.DeletedAt != nil; {
+	case deleted && (c.Old == nil || c.Old.DeletedAt == nil):
+		return ItemDeleted
+	case !deleted && c.Old != nil && c.Old.DeletedAt != nil:
+		return ItemRestored
+	}
+	return ItemUpdated
+}
+
+// Item returns the latest known state of the item
+func (c *Change) Item() *Item {
+	if c.New != nil {
+		return c.New
+	}
+	return c.Old
+}
+
+// DecodeChange decodes a stream record into an item change
+func DecodeChange(record events.DynamoDBEventRecord) (*Change, error) {
+	change := Change{
+		Type:           ChangeType(record.EventName),
+		EventID:        record.EventID,
+		SequenceNumber: record.Change.SequenceNumber,
+		Time:           record.Change.ApproximateCreationDateTime.Time,
+	}
+	if id := record.UserIdentity; id != nil && id.Type == "Service" && id.PrincipalID == ttlPrincipal {
+		change.TTL = true
+	}
+	switch change.Type {
+	case ChangeInsert, ChangeModify, ChangeRemove:
+	default:
+		return nil, fmt.Errorf("Unsupported change type: %v", record.EventName)
+	}
+
+	var err error
+	if change.Old, err = decodeImage(record.Change.OldImage); err != nil {
+		return nil, err
+	}
+	if change.New, err = decodeImage(record.Change.NewImage); err != nil {
+		return nil, err
+	}
+	if change.Item() == nil {
+		return nil, fmt.Errorf("Missing item images in record %v, check stream view type", record.EventID)
+	}
+	return &change, nil
+}
+
+// Decodes a stream image into an item
+func decodeImage(image map[string]events.DynamoDBAttributeValue) (*Item, error) {
+	if len(image) == 0 {
+		return nil, nil
+	}
+
+	// both types share DynamoDB JSON representation
+	b, err := json.Marshal(image)
+	if err != nil {
+		return nil, err
+	}
+	var av map[string]*dynamodb.AttributeValue
+	if err = json.Unmarshal(b, &av); err != nil {
+		return nil, err
+	}
+
+	if _, err = ItemMigrations.Upgrade(av); err != nil {
+		return nil, err
+	}
+	var item Item
+	if err = dynamodbattribute.UnmarshalMap(av, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// ChangeSink receives captured item changes
+type ChangeSink interface {
+	Handle(ctx context.Context, change Change) error
+}
+
+// ChangeDispatcher delivers stream records to sinks in order
+type ChangeDispatcher struct {
+	Sinks      []ChangeSink
+	Payloads   *PayloadStore    // rehydrates item images offloaded to S3 (kept as claim checks if nil)
+	Encryption *FieldEncryption // decrypts sensitive fields of item images (kept encrypted if nil)
+}
+
+// Dispatch every record of the stream batch to all sinks.
+// Processing stops at the first failed record which is reported as a batch item failure,
+// so the stream resumes from that record and no later change is applied before it (checkpoint).
+func (d ChangeDispatcher) Dispatch(ctx context.Context, e events.DynamoDBEvent) events.DynamoDBEventResponse {
+	var resp events.DynamoDBEventResponse
+	for _, record := range e.Records {
+		if err := d.dispatch(ctx, record); err != nil {
+			log.Println("Failed to process change:", record.EventID, err.Error())
+			resp.BatchItemFailures = []events.DynamoDBBatchItemFailure{
+				{ItemIdentifier: record.Change.SequenceNumber},
+			}
+			break
+		}
+	}
+	return resp
+}
+
+// Dispatches a single record to all sinks
+func (d ChangeDispatcher) dispatch(ctx context.Context, record events.DynamoDBEventRecord) error {
+	change, err := DecodeChange(record)
+	if err != nil {
+		return err
+	}
+	if change.Old != nil {
+		change.OldPayload = change.Old.Payload
+	}
+	if change.New != nil {
+		change.NewPayload = change.New.Payload
+	}
+	if d.Payloads != nil {
+		payloads := d.Payloads.WithContext(ctx)
+		if err := payloads.Rehydrate(change.Old); err != nil {
+			return err
+		}
+		if err := payloads.Rehydrate(change.New); err != nil {
+			return err
+		}
+	}
+	if d.Encryption != nil {
+		enc := d.Encryption.WithContext(ctx)
+		if err := enc.Decrypt(change.Old); err != nil {
+			return err
+		}
+		if err := enc.Decrypt(change.New); err != nil {
+			return err
+		}
+	}
+	for _, sink := range d.Sinks {
+		if err := sink.Handle(ctx, *change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
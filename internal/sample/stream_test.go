@@ -0,0 +1,204 @@
+package sample
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock sink recording changes and failing on an item ID
+type mockSink struct {
+	changes []Change
+	failID  string
+}
+
+func (mock *mockSink) Handle(ctx context.Context, change Change) error {
+	if change.Item().ID == mock.failID {
+		return errors.New("Mock sink error")
+	}
+	mock.changes = append(mock.changes, change)
+	return nil
+}
+
+// Returns a stream image of the item
+func streamImage(id, name string, quantity string) map[string]events.DynamoDBAttributeValue {
+	return map[string]events.DynamoDBAttributeValue{
+		"id":   events.NewStringAttribute(id),
+		"name": events.NewStringAttribute(name),
+		"details": events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+			"location": events.NewStringAttribute("A1"),
+			"quantity": events.NewNumberAttribute(quantity),
+		}),
+	}
+}
+
+// Returns a stream record of the change
+func streamRecord(name, seq string, old, new map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventID:   "event-" + seq,
+		EventName: name,
+		Change: events.DynamoDBStreamRecord{
+			SequenceNumber: seq,
+			OldImage:       old,
+			NewImage:       new,
+		},
+	}
+}
+
+// Marks the stream record as a deletion by DynamoDB TTL
+func ttlRecord(record events.DynamoDBEventRecord) events.DynamoDBEventRecord {
+	record.UserIdentity = &events.DynamoDBUserIdentity{Type: "Service", PrincipalID: "dynamodb.amazonaws.com"}
+	return record
+}
+
+func TestDecodeChange(t *testing.T) {
+	tests := []struct {
+		name      string
+		record    events.DynamoDBEventRecord
+		wantEvent Event
+		wantOld   bool
+		wantNew   bool
+		wantErr   bool
+	}{
+		{
+			name:      "insert",
+			record:    streamRecord("INSERT", "1", nil, streamImage("test-item-id", "new", "5")),
+			wantEvent: ItemCreated,
+			wantNew:   true,
+		},
+		{
+			name:      "modify",
+			record:    streamRecord("MODIFY", "2", streamImage("test-item-id", "old", "5"), streamImage("test-item-id", "new", "3")),
+			wantEvent: ItemUpdated,
+			wantOld:   true,
+			wantNew:   true,
+		},
+		{
+			name:      "remove",
+			record:    streamRecord("REMOVE", "3", streamImage("test-item-id", "old", "5"), nil),
+			wantEvent: ItemDeleted,
+			wantOld:   true,
+		},
+		{
+			name:      "expire",
+			record:    ttlRecord(streamRecord("REMOVE", "5", streamImage("test-item-id", "old", "5"), nil)),
+			wantEvent: ItemExpired,
+			wantOld:   true,
+		},
+		{
+			name:    "keys only",
+			record:  streamRecord("INSERT", "4", nil, nil),
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			record:  streamRecord("UNKNOWN", "5", nil, streamImage("test-item-id", "new", "5")),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeChange(tt.record)
+			assert := assert.New(t)
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			if assert.NoError(err) {
+				assert.Equal(tt.wantEvent, got.Event(), "Event")
+				assert.Equal(tt.record.Change.SequenceNumber, got.SequenceNumber, "SequenceNumber")
+				assert.Equal(tt.wantOld, got.Old != nil, "Old image")
+				assert.Equal(tt.wantNew, got.New != nil, "New image")
+				assert.Equal("test-item-id", got.Item().ID, "ID")
+				if got.New != nil {
+					assert.Equal("new", got.New.Name, "New name")
+					assert.Equal("A1", got.New.Details.Location, "New location")
+				}
+				if got.Old != nil {
+					assert.Equal(5, got.Old.Details.Quantity, "Old quantity")
+				}
+			}
+		})
+	}
+}
+
+func TestChange_Event(t *testing.T) {
+	deletedAt := time.Now()
+	live := &Item{ID: "test-item-id"}
+	tombstone := &Item{ID: "test-item-id", DeletedAt: &deletedAt}
+
+	tests := []struct {
+		name   string
+		change Change
+		want   Event
+	}{
+		{name: "soft delete", change: Change{Type: ChangeModify, Old: live, New: tombstone}, want: ItemDeleted},
+		{name: "restore", change: Change{Type: ChangeModify, Old: tombstone, New: live}, want: ItemRestored},
+		{name: "update", change: Change{Type: ChangeModify, Old: live, New: live}, want: ItemUpdated},
+		{name: "purge", change: Change{Type: ChangeRemove, Old: tombstone}, want: ItemPurged},
+		{name: "hard delete", change: Change{Type: ChangeRemove, Old: live}, want: ItemDeleted},
+		{name: "expire", change: Change{Type: ChangeRemove, Old: live, TTL: true}, want: ItemExpired},
+		{name: "purge by TTL", change: Change{Type: ChangeRemove, Old: tombstone, TTL: true}, want: ItemPurged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.change.Event())
+		})
+	}
+}
+
+func TestChangeDispatcher_Dispatch(t *testing.T) {
+	e := events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("INSERT", "1", nil, streamImage("first", "new", "1")),
+		streamRecord("INSERT", "2", nil, streamImage("failing", "new", "1")),
+		streamRecord("INSERT", "3", nil, streamImage("third", "new", "1")),
+	}}
+
+	assert := assert.New(t)
+
+	sink := &mockSink{}
+	resp := ChangeDispatcher{Sinks: []ChangeSink{sink, AuditLogSink{}}}.Dispatch(context.Background(), e)
+	assert.Empty(resp.BatchItemFailures, "No failures")
+	assert.Len(sink.changes, 3, "All changes")
+
+	sink = &mockSink{failID: "failing"}
+	resp = ChangeDispatcher{Sinks: []ChangeSink{sink}}.Dispatch(context.Background(), e)
+	assert.Equal([]events.DynamoDBBatchItemFailure{{ItemIdentifier: "2"}}, resp.BatchItemFailures, "Checkpoint")
+	assert.Len(sink.changes, 1, "Changes before failure")
+}
+
+func TestChangeDispatcher_Payloads(t *testing.T) {
+	client, local := localClient(t)
+	store := &PayloadStore{Client: client, Bucket: local.bucket}
+	ref, err := store.Put("items/first/1-x.json.gz", []byte(`{"description":"offloaded"}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	old := streamImage("first", "old", "1")
+	old["payload"] = events.NewMapAttribute(map[string]events.DynamoDBAttributeValue{
+		"bucket": events.NewStringAttribute(ref.Bucket),
+		"key":    events.NewStringAttribute(ref.Key),
+		"size":   events.NewNumberAttribute("27"),
+	})
+	e := events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		streamRecord("MODIFY", "1", old, streamImage("first", "new", "1")),
+	}}
+
+	sink := &mockSink{}
+	resp := ChangeDispatcher{Sinks: []ChangeSink{sink}, Payloads: store}.Dispatch(context.Background(), e)
+
+	assert := assert.New(t)
+	assert.Empty(resp.BatchItemFailures, "No failures")
+	if assert.Len(sink.changes, 1) {
+		change := sink.changes[0]
+		assert.Equal("offloaded", change.Old.Details.Description, "Rehydrated old image")
+		assert.Equal(ref, change.OldPayload, "Claim check of the old image")
+		assert.Nil(change.NewPayload, "New image kept in the table")
+	}
+}
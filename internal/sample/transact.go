@@ -0,0 +1,322 @@
+package sample
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// maxTransactItems is the maximum number of operations in a single TransactWriteItems request
+const maxTransactItems = 100
+
+// Cancellation reason codes of transaction operations
+const (
+	ReasonNone            = "None"
+	ReasonConditionFailed = "ConditionalCheckFailed"
+	ReasonConflict        = "TransactionConflict"
+	ReasonThrottled       = "ThrottlingError"
+	ReasonCapacity        = "ProvisionedThroughputExceeded"
+	ReasonValidation      = "ValidationError"
+)
+
+// Expr holds expressions of a transaction operation and their placeholders.
+// Placeholders not referenced by the expressions are dropped, so operations may share them.
+type Expr struct {
+	Update    string // update expression (update operations only)
+	Condition string // condition expression (none if empty, required by condition checks)
+	Names     map[string]*string
+	Values    map[string]*dynamodb.AttributeValue
+}
+
+// CancellationReason is a failure of a single operation of a cancelled transaction
+type CancellationReason struct {
+	Index   int    // position of the operation in the transaction
+	Code    string // reason code (e.g. ConditionalCheckFailed)
+	Message string
+}
+
+func (r CancellationReason) Error() string {
+	if r.Message == "" {
+		return fmt.Sprintf("Operation %d failed: %s", r.Index, r.Code)
+	}
+	return fmt.Sprintf("Operation %d failed: %s (%s)", r.Index, r.Code, r.Message)
+}
+
+// TransactionError is returned when a transaction is cancelled, it lists reasons of failed operations.
+// Condition failures and conflicts match ErrConflict by errors.Is.
+type TransactionError struct {
+	Reasons []CancellationReason
+}
+
+func (e *TransactionError) Error() string {
+	if len(e.Reasons) == 0 {
+		return "Transaction cancelled"
+	}
+	msgs := make([]string, len(e.Reasons))
+	for i, reason := range e.Reasons {
+		msgs[i] = reason.Error()
+	}
+	return "Transaction cancelled: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether the transaction was cancelled by a condition failure or a conflict
+func (e *TransactionError) Is(target error) bool {
+	if target != ErrConflict {
+		return false
+	}
+	for _, reason := range e.Reasons {
+		if reason.Code == ReasonConditionFailed || reason.Code == ReasonConflict {
+			return true
+		}
+	}
+	return false
+}
+
+// Reason returns the cancellation reason of the operation at the index (if it failed)
+func (e *TransactionError) Reason(index int) (CancellationReason, bool) {
+	for _, reason := range e.Reasons {
+		if reason.Index == index {
+			return reason, true
+		}
+	}
+	return CancellationReason{}, false
+}
+
+// ConditionFailed reports whether the condition of the operation at the index failed
+func (e *TransactionError) ConditionFailed(index int) bool {
+	reason, ok := e.Reason(index)
+	return ok && reason.Code == ReasonConditionFailed
+}
+
+// Reports whether all failures are transient, so the transaction may succeed on retry
+func (e *TransactionError) transient() bool {
+	for _, reason := range e.Reasons {
+		switch reason.Code {
+		case ReasonConflict, ReasonThrottled, ReasonCapacity:
+		default:
+			return false
+		}
+	}
+	return len(e.Reasons) > 0
+}
+
+// Decodes a cancelled transaction error (nil for other errors)
+func transactionError(err error) *TransactionError {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() != dynamodb.ErrCodeTransactionCanceledException {
+		return nil
+	}
+	txErr := &TransactionError{}
+	if canceled, ok := aerr.(*dynamodb.TransactionCanceledException); ok {
+		for i, reason := range canceled.CancellationReasons {
+			if code := aws.StringValue(reason.Code); code != ReasonNone {
+				txErr.Reasons = append(txErr.Reasons, CancellationReason{Index: i, Code: code, Message: aws.StringValue(reason.Message)})
+			}
+		}
+	}
+	return txErr
+}
+
+// Transaction builds all-or-nothing writes of up to 100 items across tables.
+// Operations are added by fluent methods and executed by Commit.
+type Transaction struct {
+	Client dynamodbiface.DynamoDBAPI
+	Retry  RetryPolicy // retries of throttled and conflicting transactions (single attempt if zero)
+	items  []*dynamodb.TransactWriteItem
+	err    error // first error of building operations
+	ctx    context.Context
+}
+
+// Transact starts a transaction of the repository client
+func (r *Repo) Transact() *Transaction {
+	return &Transaction{Client: r.Client, Retry: r.Retry, ctx: r.ctx}
+}
+
+// Returns the bound context or a background one
+func (t *Transaction) context() context.Context {
+	if t.ctx != nil {
+		return t.ctx
+	}
+	return context.Background()
+}
+
+// Put writes the item (a struct or marshalled attributes) under the optional condition
+func (t *Transaction) Put(table string, item interface{}, e Expr) *Transaction {
+	av, ok := item.(map[string]*dynamodb.AttributeValue)
+	if !ok {
+		var err error
+		if av, err = dynamodbattribute.MarshalMap(item); err != nil {
+			log.Println("Failed to marshal:", err.Error())
+			t.fail(err)
+			return t
+		}
+	}
+	return t.add(&dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+		TableName:                 aws.String(table),
+		Item:                      av,
+		ConditionExpression:       optional(e.Condition),
+		ExpressionAttributeNames:  e.names(),
+		ExpressionAttributeValues: e.values(),
+	}})
+}
+
+// Update modifies attributes of the item with the key by the update expression under the optional condition
+func (t *Transaction) Update(table string, key map[string]*dynamodb.AttributeValue, e Expr) *Transaction {
+	if e.Update == "" {
+		t.fail(errors.New("Missing update expression"))
+		return t
+	}
+	return t.add(&dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+		TableName:                 aws.String(table),
+		Key:                       key,
+		UpdateExpression:          aws.String(e.Update),
+		ConditionExpression:       optional(e.Condition),
+		ExpressionAttributeNames:  e.names(),
+		ExpressionAttributeValues: e.values(),
+	}})
+}
+
+// Delete removes the item with the key under the optional condition
+func (t *Transaction) Delete(table string, key map[string]*dynamodb.AttributeValue, e Expr) *Transaction {
+	return t.add(&dynamodb.TransactWriteItem{Delete: &dynamodb.Delete{
+		TableName:                 aws.String(table),
+		Key:                       key,
+		ConditionExpression:       optional(e.Condition),
+		ExpressionAttributeNames:  e.names(),
+		ExpressionAttributeValues: e.values(),
+	}})
+}
+
+// Check requires the condition on the item with the key without changing it
+func (t *Transaction) Check(table string, key map[string]*dynamodb.AttributeValue, e Expr) *Transaction {
+	if e.Condition == "" {
+		t.fail(errors.New("Missing condition expression"))
+		return t
+	}
+	return t.add(&dynamodb.TransactWriteItem{ConditionCheck: &dynamodb.ConditionCheck{
+		TableName:                 aws.String(table),
+		Key:                       key,
+		ConditionExpression:       aws.String(e.Condition),
+		ExpressionAttributeNames:  e.names(),
+		ExpressionAttributeValues: e.values(),
+	}})
+}
+
+// Len returns the number of operations
+func (t *Transaction) Len() int {
+	return len(t.items)
+}
+
+// Commit executes all operations atomically. A cancelled transaction returns *TransactionError,
+// it is retried while all failures are transient (conflicts with other transactions or throttling).
+func (t *Transaction) Commit() error {
+	if t.err != nil {
+		return t.err
+	}
+	if len(t.items) == 0 {
+		return nil
+	}
+	if len(t.items) > maxTransactItems {
+		return fmt.Errorf("Transaction exceeds %d operations", maxTransactItems)
+	}
+
+	// execute transaction
+	input := &dynamodb.TransactWriteItemsInput{TransactItems: t.items}
+	var txErr *TransactionError
+	err := t.Retry.Do(t.context(), func() error {
+		_, err := t.Client.TransactWriteItems(input)
+		if txErr = transactionError(err); txErr != nil && txErr.transient() {
+			return errRetry
+		}
+		return err
+	})
+	if txErr != nil {
+		return txErr
+	}
+	return err
+}
+
+// Appends an operation to the transaction
+func (t *Transaction) add(item *dynamodb.TransactWriteItem) *Transaction {
+	t.items = append(t.items, item)
+	return t
+}
+
+// Keeps the first building error, reported by Commit
+func (t *Transaction) fail(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+// Returns names referenced by the expressions (nil if none)
+func (e Expr) names() map[string]*string {
+	var names map[string]*string
+	for placeholder, name := range e.Names {
+		if e.references(placeholder) {
+			if names == nil {
+				names = map[string]*string{}
+			}
+			names[placeholder] = name
+		}
+	}
+	return names
+}
+
+// Returns values referenced by the expressions (nil if none)
+func (e Expr) values() map[string]*dynamodb.AttributeValue {
+	var values map[string]*dynamodb.AttributeValue
+	for placeholder, value := range e.Values {
+		if e.references(placeholder) {
+			if values == nil {
+				values = map[string]*dynamodb.AttributeValue{}
+			}
+			values[placeholder] = value
+		}
+	}
+	return values
+}
+
+// Reports whether the placeholder is a whole token of the update or condition expression
+func (e Expr) references(placeholder string) bool {
+	for _, expr := range []string{e.Update, e.Condition} {
+		for i := strings.Index(expr, placeholder); i >= 0; {
+			end := i + len(placeholder)
+			if end == len(expr) || !isWordChar(expr[end]) {
+				return true
+			}
+			next := strings.Index(expr[end:], placeholder)
+			if next < 0 {
+				break
+			}
+			i = end + next
+		}
+	}
+	return false
+}
+
+// Reports whether the byte may continue a placeholder
+func isWordChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// Returns an optional expression (nil if empty)
+func optional(expr string) *string {
+	if expr == "" {
+		return nil
+	}
+	return aws.String(expr)
+}
+
+// itemKey returns the primary key of an item
+func itemKey(itemID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{"id": {S: aws.String(itemID)}}
+}
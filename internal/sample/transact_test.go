@@ -0,0 +1,144 @@
+package sample
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client recording transactions and failing with queued errors
+type mockDdbTransact struct {
+	dynamodbiface.DynamoDBAPI
+	inputs []*dynamodb.TransactWriteItemsInput
+	errs   []error
+}
+
+func (mock *mockDdbTransact) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	mock.inputs = append(mock.inputs, input)
+	if len(mock.errs) > 0 {
+		err := mock.errs[0]
+		mock.errs = mock.errs[1:]
+		return nil, err
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// Returns a cancelled transaction error with reason codes of its operations
+func mockCanceled(codes ...string) error {
+	reasons := make([]*dynamodb.CancellationReason, len(codes))
+	for i, code := range codes {
+		reasons[i] = &dynamodb.CancellationReason{Code: aws.String(code)}
+	}
+	return &dynamodb.TransactionCanceledException{Message_: aws.String("Mock cancellation"), CancellationReasons: reasons}
+}
+
+func TestTransaction_Commit(t *testing.T) {
+	assert := assert.New(t)
+	client := &mockDdbTransact{}
+	r := &Repo{Client: client, TableName: "mock-table", Retry: mockRetry(3)}
+
+	values := map[string]*dynamodb.AttributeValue{
+		":one":  {N: aws.String("1")},
+		":once": {N: aws.String("2")},
+	}
+	err := r.Transact().
+		Put("mock-table", Item{ID: "first"}, Expr{Condition: "attribute_not_exists(id)"}).
+		Update("mock-table", itemKey("second"), Expr{Update: "ADD #version :one", Names: map[string]*string{"#version": aws.String("version"), "#name": aws.String("name")}, Values: values}).
+		Delete("mock-table", itemKey("third"), Expr{}).
+		Check("mock-table", itemKey("fourth"), Expr{Condition: "#version = :once", Names: map[string]*string{"#version": aws.String("version")}, Values: values}).
+		Commit()
+	if assert.NoError(err) && assert.Len(client.inputs, 1) {
+		items := client.inputs[0].TransactItems
+		assert.Equal("first", *items[0].Put.Item["id"].S, "Marshalled item")
+		update := items[1].Update
+		assert.Len(update.ExpressionAttributeNames, 1, "Unreferenced names dropped")
+		assert.Len(update.ExpressionAttributeValues, 1, "Unreferenced values dropped")
+		assert.NotNil(update.ExpressionAttributeValues[":one"])
+		assert.Nil(items[2].Delete.ConditionExpression, "Unconditional")
+		assert.Len(items[3].ConditionCheck.ExpressionAttributeValues, 1, "Whole placeholder only")
+		assert.NotNil(items[3].ConditionCheck.ExpressionAttributeValues[":once"])
+	}
+
+	assert.NoError(r.Transact().Commit(), "Empty transaction")
+	assert.Error(r.Transact().Update("mock-table", itemKey("first"), Expr{}).Commit(), "Missing update")
+	assert.Error(r.Transact().Check("mock-table", itemKey("first"), Expr{}).Commit(), "Missing condition")
+	tx := r.Transact()
+	for i := 0; i <= maxTransactItems; i++ {
+		tx.Delete("mock-table", itemKey("item"), Expr{})
+	}
+	assert.Error(tx.Commit(), "Too many operations")
+}
+
+func TestTransaction_Cancelled(t *testing.T) {
+	assert := assert.New(t)
+	client := &mockDdbTransact{errs: []error{mockCanceled(ReasonNone, ReasonConflict), mockCanceled(ReasonNone, ReasonConditionFailed)}}
+	r := &Repo{Client: client, TableName: "mock-table", Retry: mockRetry(3)}
+
+	err := r.Transact().
+		Delete("mock-table", itemKey("first"), Expr{}).
+		Delete("mock-table", itemKey("second"), Expr{Condition: "attribute_exists(id)"}).
+		Commit()
+	assert.Len(client.inputs, 2, "Conflict retried")
+
+	var txErr *TransactionError
+	if assert.True(errors.As(err, &txErr), "Typed error") {
+		assert.Equal([]CancellationReason{{Index: 1, Code: ReasonConditionFailed}}, txErr.Reasons, "Failed operations only")
+		assert.True(txErr.ConditionFailed(1))
+		assert.False(txErr.ConditionFailed(0))
+		assert.EqualError(err, "Transaction cancelled: Operation 1 failed: ConditionalCheckFailed")
+	}
+	assert.True(errors.Is(err, ErrConflict), "Matches conflict")
+	assert.True(conditionFailed(err), "Condition failure")
+
+	client.errs = []error{mockCanceled(ReasonValidation)}
+	err = r.Transact().Delete("mock-table", itemKey("first"), Expr{}).Commit()
+	assert.False(errors.Is(err, ErrConflict), "Validation error")
+}
+
+func TestRepo_Transfer(t *testing.T) {
+	assert := assert.New(t)
+	client := &mockDdbInventory{mockDdbUpdates: mockDdbUpdates{mockDdb: mockDdb{item: &Item{ID: "from"}}}}
+	r := &Repo{Client: client, TableName: "mock-table"}
+
+	if assert.NoError(r.Transfer(Transfer{From: "from", To: "to", Quantity: 2})) {
+		from, to := client.transact.TransactItems[0].Update, client.transact.TransactItems[1].Update
+		assert.Equal("from", *from.Key["id"].S)
+		assert.Contains(*from.ConditionExpression, "#details.#quantity >= :plus", "Never negative")
+		assert.Equal("-2", *from.ExpressionAttributeValues[":minus"].N)
+		assert.Equal("to", *to.Key["id"].S)
+		assert.Nil(to.ExpressionAttributeValues[":minus"], "Unreferenced value")
+	}
+	assert.Error(r.Transfer(Transfer{From: "from", To: "from", Quantity: 2}), "Same item")
+	assert.Error(r.Transfer(Transfer{From: "from", To: "to"}), "Zero quantity")
+
+	client.cancel = true
+	assert.Equal(ErrInsufficientQuantity, r.Transfer(Transfer{From: "from", To: "to", Quantity: 5}), "Not enough stock")
+}
+
+func TestRepo_TransferVersioned(t *testing.T) {
+	assert := assert.New(t)
+	client := &mockDdbInventory{mockDdbUpdates: mockDdbUpdates{mockDdb: mockDdb{item: &Item{ID: "from", Version: 2, Details: Details{Quantity: 3}}}}}
+	r := &Repo{Client: client, TableName: "mock-table", VersionTableName: "mock-version-table"}
+
+	if assert.NoError(r.Transfer(Transfer{From: "from", To: "to", Quantity: 2})) && assert.Len(client.transact.TransactItems, 4, "Version records") {
+		for i, quantity := range []int{1, 5} {
+			assert.Contains(*client.transact.TransactItems[i].Update.ConditionExpression, "#version = :version", "Read version expected")
+			put := client.transact.TransactItems[2+i].Put
+			assert.Equal("mock-version-table", *put.TableName)
+			var v ItemVersion
+			if assert.NoError(dynamodbattribute.UnmarshalMap(put.Item, &v)) {
+				assert.Equal(3, v.Version, "Next version")
+				assert.Equal(quantity, v.Item.Details.Quantity, "Moved quantity")
+			}
+		}
+	}
+	assert.Equal(ErrInsufficientQuantity, r.Transfer(Transfer{From: "from", To: "to", Quantity: 5}), "Not enough stock")
+
+	client.cancel = true
+	assert.Equal(ErrConflict, r.Transfer(Transfer{From: "from", To: "to", Quantity: 1}), "Version changed concurrently")
+}
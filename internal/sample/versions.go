@@ -0,0 +1,135 @@
+package sample
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// ItemVersion is an immutable revision of an item
+type ItemVersion struct {
+	ItemID  string `json:"itemId"`
+	Version int    `json:"version"`
+	Item    Item   `json:"item"`
+}
+
+// Versions lists a page of item revisions, the newest first
+func (r *Repo) Versions(itemID string, page Page) ([]ItemVersion, string, error) {
+	if itemID == "" {
+		return nil, "", errors.New("Missing resource ID")
+	}
+	if r.VersionTableName == "" {
+		return nil, "", errors.New("Versioning is not enabled")
+	}
+	startKey, err := page.startKey(keyAttr{name: "itemId", value: itemID}, keyAttr{name: "version", number: true})
+	if err != nil {
+		return nil, "", err
+	}
+
+	// prepare query data
+	input := &dynamodb.QueryInput{
+		TableName:              &r.VersionTableName,
+		KeyConditionExpression: aws.String("itemId = :itemId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":itemId": {S: aws.String(itemID)},
+		},
+		ScanIndexForward:  aws.Bool(false),
+		Limit:             page.limit(),
+		ExclusiveStartKey: startKey,
+	}
+
+	// execute query
+	var res *dynamodb.QueryOutput
+	err = r.Retry.Do(r.context(), func() (err error) {
+		res, err = r.Client.Query(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, "", errors.New("Failed to read item versions")
+	}
+
+	// process query results
+	for _, av := range res.Items {
+		if err = r.upgradeVersion(av); err != nil {
+			return nil, "", err
+		}
+	}
+	var versions []ItemVersion
+	if err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &versions); err != nil {
+		log.Println("Failed to unmarshal:", err.Error())
+		return nil, "", err
+	}
+	for i := range versions {
+		if err = r.rehydrate(&versions[i].Item); err != nil {
+			return nil, "", err
+		}
+	}
+	return versions, nextPageToken(res.LastEvaluatedKey), nil
+}
+
+// Version gets a single revision of the item
+func (r *Repo) Version(itemID string, version int) (*ItemVersion, error) {
+	if itemID == "" {
+		return nil, errors.New("Missing resource ID")
+	}
+	if r.VersionTableName == "" {
+		return nil, errors.New("Versioning is not enabled")
+	}
+
+	// prepare query data
+	input := &dynamodb.GetItemInput{
+		TableName: &r.VersionTableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"itemId":  {S: aws.String(itemID)},
+			"version": {N: aws.String(strconv.Itoa(version))},
+		},
+	}
+
+	// execute query
+	var res *dynamodb.GetItemOutput
+	err := r.Retry.Do(r.context(), func() (err error) {
+		res, err = r.Client.GetItem(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to read item version")
+	} else if res.Item == nil {
+		return nil, ErrNotFound
+	}
+
+	// process query results
+	if err = r.upgradeVersion(res.Item); err != nil {
+		return nil, err
+	}
+	var v ItemVersion
+	if err = dynamodbattribute.UnmarshalMap(res.Item, &v); err != nil {
+		log.Println("Failed to unmarshal:", err.Error())
+		return nil, err
+	}
+	if err = r.rehydrate(&v.Item); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Restore rolls the current item back to the content of a previous version.
+// Restored content is stored as a new revision, so the history is never rewritten.
+func (r *Repo) Restore(current Item, version int) (*Item, error) {
+	v, err := r.Version(current.ID, version)
+	if err != nil {
+		return nil, err
+	}
+	item := v.Item
+	item.ID = current.ID
+	item.CreatedAt = current.CreatedAt
+	item.Version = current.Version
+	item.Details.Reserved = current.Details.Reserved // reservations are not rolled back
+	item.Attachments = current.Attachments           // uploaded content is not versioned
+	return r.Update(item)
+}
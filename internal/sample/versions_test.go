@@ -0,0 +1,170 @@
+package sample
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client keeping items and their versions in memory
+type mockVersionedDdb struct {
+	dynamodbiface.DynamoDBAPI
+	items    map[string]map[string]*dynamodb.AttributeValue
+	versions map[string][]map[string]*dynamodb.AttributeValue
+	err      error
+}
+
+func newMockVersionedDdb() *mockVersionedDdb {
+	return &mockVersionedDdb{
+		items:    map[string]map[string]*dynamodb.AttributeValue{},
+		versions: map[string][]map[string]*dynamodb.AttributeValue{},
+	}
+}
+
+func (mock *mockVersionedDdb) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	item, version := input.TransactItems[0].Put, input.TransactItems[len(input.TransactItems)-1].Put
+	if update := input.TransactItems[0].Update; update != nil {
+		// stock movements are applied as the state of their version record
+		item = &dynamodb.Put{
+			Item:                      version.Item["item"].M,
+			ConditionExpression:       update.ConditionExpression,
+			ExpressionAttributeValues: update.ExpressionAttributeValues,
+		}
+	}
+	id := *item.Item["id"].S
+	existing, exists := mock.items[id]
+
+	// evaluate conditions of the repository
+	ok := true
+	switch condition := aws.StringValue(item.ConditionExpression); {
+	case condition == "attribute_not_exists(id)":
+		ok = !exists
+	case strings.HasSuffix(condition, "attribute_not_exists(#version)"):
+		ok = exists && existing["version"] == nil
+	case strings.HasSuffix(condition, "#version = :version"):
+		ok = exists && existing["version"] != nil && *existing["version"].N == *item.ExpressionAttributeValues[":version"].N
+	}
+	if !ok {
+		return nil, &dynamodb.TransactionCanceledException{
+			Message_:            aws.String("Mock condition failure"),
+			CancellationReasons: []*dynamodb.CancellationReason{{Code: aws.String("ConditionalCheckFailed")}, {Code: aws.String("None")}},
+		}
+	}
+	mock.items[id] = item.Item
+	mock.versions[id] = append(mock.versions[id], version.Item)
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (mock *mockVersionedDdb) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if n := input.Key["version"]; n != nil {
+		for _, v := range mock.versions[*input.Key["itemId"].S] {
+			if *v["version"].N == *n.N {
+				return &dynamodb.GetItemOutput{Item: v}, nil
+			}
+		}
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: mock.items[*input.Key["id"].S]}, nil
+}
+
+func (mock *mockVersionedDdb) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	versions := mock.versions[*input.ExpressionAttributeValues[":itemId"].S]
+	output := new(dynamodb.QueryOutput)
+	for i := len(versions) - 1; i >= 0; i-- {
+		output.Items = append(output.Items, versions[i])
+	}
+	return output, nil
+}
+
+func TestRepo_Versions(t *testing.T) {
+	client := newMockVersionedDdb()
+	r := &Repo{Client: client, TableName: "mock-table", VersionTableName: "mock-version-table"}
+
+	assert := assert.New(t)
+	v1, err := r.Save(Item{Name: "first"})
+	if !assert.NoError(err, "Save") {
+		return
+	}
+	assert.Equal(1, v1.Version, "Initial version")
+
+	v2 := *v1
+	v2.Name = "second"
+	got, err := r.Update(v2)
+	if !assert.NoError(err, "Update") {
+		return
+	}
+	assert.Equal(2, got.Version, "Next version")
+
+	_, err = r.Update(v2)
+	assert.Equal(ErrConflict, err, "Stale version")
+
+	versions, _, err := r.Versions(v1.ID, Page{})
+	if assert.NoError(err, "Versions") && assert.Len(versions, 2) {
+		assert.Equal("second", versions[0].Item.Name, "Newest first")
+		assert.Equal("first", versions[1].Item.Name, "Oldest last")
+	}
+
+	v, err := r.Version(v1.ID, 1)
+	if assert.NoError(err, "Version") {
+		assert.Equal("first", v.Item.Name)
+	}
+	_, err = r.Version(v1.ID, 5)
+	assert.Equal(ErrNotFound, err, "Missing version")
+
+	restored, err := r.Restore(*got, 1)
+	if assert.NoError(err, "Restore") {
+		assert.Equal("first", restored.Name, "Restored content")
+		assert.Equal(3, restored.Version, "Restored as a new version")
+		assert.Equal(v1.CreatedAt.Unix(), restored.CreatedAt.Unix(), "CreatedAt")
+	}
+	versions, _, _ = r.Versions(v1.ID, Page{})
+	assert.Len(versions, 3, "History is kept")
+}
+
+func TestRepo_VersionsFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	r := &Repo{Client: newMockVersionedDdb(), TableName: "mock-table"}
+	_, _, err := r.Versions("test-item-id", Page{})
+	assert.Error(err, "Versioning disabled")
+	_, err = r.Version("test-item-id", 1)
+	assert.Error(err, "Versioning disabled")
+
+	r.VersionTableName = "mock-version-table"
+	_, _, err = r.Versions("", Page{})
+	assert.Error(err, "Missing ID")
+	_, _, err = r.Versions("test-item-id", Page{Next: "%invalid%"})
+	assert.Equal(ErrInvalidPageToken, err, "Invalid page token")
+
+	r.Client = &mockVersionedDdb{err: errors.New("Mock DynamoDB error")}
+	_, err = r.Save(Item{Name: "test-item-name"})
+	assert.Error(err, "Failed operation")
+	assert.NotEqual(ErrConflict, err, "Not a conflict")
+}
+
+func TestConditionFailed(t *testing.T) {
+	item, _ := dynamodbattribute.MarshalMap(Item{ID: "test-item-id", Version: 1})
+	client := newMockVersionedDdb()
+	client.items["test-item-id"] = item
+
+	assert := assert.New(t)
+	r := &Repo{Client: client, TableName: "mock-table", VersionTableName: "mock-version-table"}
+	_, err := r.Save(Item{ID: "test-item-id"})
+	assert.Equal(ErrConflict, err, "Versioned resource is never overwritten")
+	_, err = r.Update(Item{ID: "test-item-id"})
+	assert.Equal(ErrConflict, err, "Unversioned update of a versioned item")
+	got, err := r.Update(Item{ID: "test-item-id", Version: 1})
+	if assert.NoError(err) {
+		assert.Equal(strconv.Itoa(got.Version), *client.items["test-item-id"]["version"].N)
+	}
+}
@@ -0,0 +1,239 @@
+package sample
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+)
+
+// Webhook is a partner endpoint registered for item event callbacks
+type Webhook struct {
+	ID        string     `json:"id,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	Secret    string     `json:"secret,omitempty"` // HMAC-SHA256 signing key
+	Events    []Event    `json:"events,omitempty"` // subscribed events (all if empty)
+	Disabled  bool       `json:"disabled,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// Subscribed reports whether the webhook is active and expects the event
+func (w *Webhook) Subscribed(event Event) bool {
+	if w.Disabled {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookRepo provides DynamoDB client capabilities for webhook registrations
+type WebhookRepo struct {
+	Client    dynamodbiface.DynamoDBAPI
+	TableName string
+	Retry     RetryPolicy // retries of throttled operations (single attempt if zero)
+	ctx       context.Context
+}
+
+// WebhookRepository returns a configured DynamoDB client for webhook registrations
+func WebhookRepository(tableName string) *WebhookRepo {
+
+	// retries are controlled by the repository policy
+	sess := session.Must(session.NewSession(aws.NewConfig().WithMaxRetries(0)))
+
+	return &WebhookRepo{
+		Client:    dynamodb.New(sess),
+		TableName: tableName,
+		Retry:     DefaultRetryPolicy,
+	}
+}
+
+// WithContext returns a shallow copy of the repository bound to the context (e.g. Lambda deadline)
+func (r *WebhookRepo) WithContext(ctx context.Context) *WebhookRepo {
+	repo := *r
+	repo.ctx = ctx
+	return &repo
+}
+
+// Returns the bound context or a background one
+func (r *WebhookRepo) context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// Save a webhook registration, a new one gets generated ID and signing secret
+func (r *WebhookRepo) Save(hook Webhook) (*Webhook, error) {
+	now := time.Now()
+	if hook.ID == "" { // generate a resource id
+		hook.ID = uuid.New().String()
+		hook.CreatedAt = &now
+	}
+	if hook.Secret == "" { // generate a signing secret
+		secret, err := newSecret()
+		if err != nil {
+			log.Println("Failed to generate secret:", err.Error())
+			return nil, err
+		}
+		hook.Secret = secret
+	}
+	hook.UpdatedAt = &now
+
+	// prepare query data
+	av, err := dynamodbattribute.MarshalMap(hook)
+	if err != nil {
+		log.Println("Failed to marshal:", err.Error())
+		return nil, err
+	}
+	input := &dynamodb.PutItemInput{Item: av, TableName: &r.TableName}
+
+	// execute query
+	err = r.Retry.Do(r.context(), func() (err error) {
+		_, err = r.Client.PutItem(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to save webhook into the repository")
+	}
+	return &hook, nil
+}
+
+// Get an existing webhook by ID
+func (r *WebhookRepo) Get(webhookID string) (*Webhook, error) {
+	if webhookID == "" {
+		return nil, errors.New("Missing resource ID")
+	}
+
+	// prepare query data
+	input := &dynamodb.GetItemInput{
+		TableName: &r.TableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(webhookID),
+			},
+		},
+	}
+
+	// execute query
+	var res *dynamodb.GetItemOutput
+	err := r.Retry.Do(r.context(), func() (err error) {
+		res, err = r.Client.GetItem(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return nil, errors.New("Failed to read webhook from the repository")
+	} else if res.Item == nil {
+		return nil, errors.New("Resource not found")
+	}
+
+	// process query results
+	var hook Webhook
+	if err = dynamodbattribute.UnmarshalMap(res.Item, &hook); err != nil {
+		log.Println("Failed to unmarshal:", err.Error())
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// List all webhook registrations
+func (r *WebhookRepo) List() ([]Webhook, error) {
+	var hooks []Webhook
+	input := &dynamodb.ScanInput{TableName: &r.TableName}
+
+	for {
+		// execute query
+		var res *dynamodb.ScanOutput
+		err := r.Retry.Do(r.context(), func() (err error) {
+			res, err = r.Client.Scan(input)
+			return
+		})
+		if err != nil {
+			log.Println(err.Error())
+			return nil, errors.New("Failed to read webhooks from the repository")
+		}
+
+		// process query results
+		var page []Webhook
+		if err = dynamodbattribute.UnmarshalListOfMaps(res.Items, &page); err != nil {
+			log.Println("Failed to unmarshal:", err.Error())
+			return nil, err
+		}
+		hooks = append(hooks, page...)
+
+		if len(res.LastEvaluatedKey) == 0 {
+			return hooks, nil
+		}
+		input.ExclusiveStartKey = res.LastEvaluatedKey
+	}
+}
+
+// Subscribers returns active webhooks subscribed to the event
+func (r *WebhookRepo) Subscribers(event Event) ([]Webhook, error) {
+	hooks, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	var subscribers []Webhook
+	for _, hook := range hooks {
+		if hook.Subscribed(event) {
+			subscribers = append(subscribers, hook)
+		}
+	}
+	return subscribers, nil
+}
+
+// Delete an existing webhook by ID
+func (r *WebhookRepo) Delete(webhookID string) error {
+	if webhookID == "" {
+		return errors.New("Missing resource ID")
+	}
+
+	// prepare query data
+	input := &dynamodb.DeleteItemInput{
+		TableName: &r.TableName,
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(webhookID),
+			},
+		},
+	}
+
+	// execute query
+	err := r.Retry.Do(r.context(), func() (err error) {
+		_, err = r.Client.DeleteItem(input)
+		return
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return errors.New("Failed to delete webhook from the repository")
+	}
+	return nil
+}
+
+// Generates a random signing secret
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
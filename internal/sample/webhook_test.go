@@ -0,0 +1,125 @@
+package sample
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock DynamoDB client scanning webhooks in pages of one
+type mockDdbWebhooks struct {
+	mockDdb
+	hooks []Webhook
+}
+
+func (mock *mockDdbWebhooks) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	if mock.err != nil {
+		return nil, mock.err
+	}
+	// the first page holds a single webhook, the second one holds the rest
+	hooks := mock.hooks[:1]
+	output := new(dynamodb.ScanOutput)
+	if input.ExclusiveStartKey != nil {
+		hooks = mock.hooks[1:]
+	}
+	for _, hook := range hooks {
+		av, _ := dynamodbattribute.MarshalMap(hook)
+		output.Items = append(output.Items, av)
+	}
+	if input.ExclusiveStartKey == nil {
+		output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"id": output.Items[0]["id"]}
+	}
+	return output, nil
+}
+
+func TestWebhook_Subscribed(t *testing.T) {
+	assert := assert.New(t)
+	assert.True((&Webhook{}).Subscribed(ItemCreated), "All events")
+	assert.True((&Webhook{Events: []Event{ItemDeleted}}).Subscribed(ItemDeleted), "Subscribed event")
+	assert.False((&Webhook{Events: []Event{ItemDeleted}}).Subscribed(ItemCreated), "Other event")
+	assert.False((&Webhook{Disabled: true}).Subscribed(ItemCreated), "Disabled")
+}
+
+func TestWebhookRepo_Save(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  *mockDdb
+		hook    Webhook
+		wantErr bool
+	}{
+		{
+			name:   "new registration",
+			client: &mockDdb{},
+			hook:   Webhook{URL: "https://example.com/hook"},
+		},
+		{
+			name:   "existing registration",
+			client: &mockDdb{},
+			hook:   Webhook{ID: "test-webhook-id", URL: "https://example.com/hook", Secret: "test-secret"},
+		},
+		{
+			name:    "failed operation",
+			client:  &mockDdb{err: errors.New("Mock DynamoDB error")},
+			hook:    Webhook{URL: "https://example.com/hook"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &WebhookRepo{Client: tt.client, TableName: "mock-table"}
+			got, err := r.Save(tt.hook)
+
+			assert := assert.New(t)
+			if tt.wantErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.NotEmpty(got.ID, "ID")
+				assert.NotEmpty(got.Secret, "Secret")
+				assert.NotNil(got.UpdatedAt, "UpdatedAt")
+				if tt.hook.ID != "" {
+					assert.Equal(tt.hook.ID, got.ID, "ID")
+					assert.Equal(tt.hook.Secret, got.Secret, "Secret")
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookRepo_Subscribers(t *testing.T) {
+	client := &mockDdbWebhooks{hooks: []Webhook{
+		{ID: "all"},
+		{ID: "deleted", Events: []Event{ItemDeleted}},
+		{ID: "disabled", Disabled: true},
+	}}
+	r := &WebhookRepo{Client: client, TableName: "mock-table"}
+
+	assert := assert.New(t)
+	all, err := r.List()
+	if assert.NoError(err) {
+		assert.Len(all, 3, "All webhooks across pages")
+	}
+	subscribers, err := r.Subscribers(ItemCreated)
+	if assert.NoError(err) && assert.Len(subscribers, 1, "Subscribers") {
+		assert.Equal("all", subscribers[0].ID, "Subscriber")
+	}
+
+	client.err = errors.New("Mock DynamoDB error")
+	_, err = r.Subscribers(ItemCreated)
+	assert.Error(err)
+}
+
+func TestWebhookRepo_Get(t *testing.T) {
+	r := &WebhookRepo{Client: &mockDdb{}, TableName: "mock-table"}
+
+	assert := assert.New(t)
+	_, err := r.Get("")
+	assert.Error(err, "Missing ID")
+	_, err = r.Get("test-webhook-id")
+	assert.Error(err, "Not found")
+	assert.Error(r.Delete(""), "Missing ID")
+	assert.NoError(r.Delete("test-webhook-id"))
+}
@@ -0,0 +1,254 @@
+// Package search is an embeddable full-text search engine: an in-memory inverted index
+// with stemming, BM25 ranking and highlighted snippets, persisted as a JSON snapshot.
+package search
+
+import (
+	"encoding/json"
+	"html"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BM25 ranking parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Snippet size in words
+const (
+	snippetWords  = 20
+	snippetBefore = 5
+)
+
+// Document is a searchable text document
+type Document struct {
+	ID     string            `json:"id"`
+	Fields map[string]string `json:"fields"`
+}
+
+// Hit is a document matching the search query
+type Hit struct {
+	ID       string            `json:"id"`
+	Score    float64           `json:"score"`
+	Snippets map[string]string `json:"snippets,omitempty"` // field text with highlighted <em>terms</em>
+}
+
+// Index is an in-memory inverted index safe for concurrent use
+type Index struct {
+	boosts   map[string]float64
+	mu       sync.RWMutex
+	docs     map[string]Document
+	postings map[string]map[string]float64 // term to weighted frequency per document
+	lengths  map[string]float64            // weighted number of terms per document
+	total    float64                       // weighted number of terms of all documents
+}
+
+// New returns an empty index weighting terms by field boosts (1 for fields without a boost)
+func New(boosts map[string]float64) *Index {
+	return &Index{
+		boosts:   boosts,
+		docs:     make(map[string]Document),
+		postings: make(map[string]map[string]float64),
+		lengths:  make(map[string]float64),
+	}
+}
+
+// Returns the weight of terms of the field
+func (ix *Index) boost(field string) float64 {
+	if boost, ok := ix.boosts[field]; ok {
+		return boost
+	}
+	return 1
+}
+
+// Len returns the number of indexed documents
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Add a document to the index replacing its previous content
+func (ix *Index) Add(doc Document) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(doc.ID)
+
+	for field, text := range doc.Fields {
+		boost := ix.boost(field)
+		for _, t := range Tokenize(text) {
+			if t.Term == "" {
+				continue
+			}
+			if ix.postings[t.Term] == nil {
+				ix.postings[t.Term] = make(map[string]float64)
+			}
+			ix.postings[t.Term][doc.ID] += boost
+			ix.lengths[doc.ID] += boost
+			ix.total += boost
+		}
+	}
+	ix.docs[doc.ID] = doc
+}
+
+// Remove a document from the index
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+// Removes the document, the caller holds the lock
+func (ix *Index) remove(id string) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, text := range doc.Fields {
+		for _, term := range Terms(text) {
+			if postings := ix.postings[term]; postings != nil {
+				delete(postings, id)
+				if len(postings) == 0 {
+					delete(ix.postings, term)
+				}
+			}
+		}
+	}
+	ix.total -= ix.lengths[id]
+	delete(ix.lengths, id)
+	delete(ix.docs, id)
+}
+
+// Search documents matching any of query terms, the most relevant first
+func (ix *Index) Search(query string, limit int) []Hit {
+	terms := Terms(query)
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if len(terms) == 0 || len(ix.docs) == 0 {
+		return nil
+	}
+
+	// BM25 score of every matching document
+	n := float64(len(ix.docs))
+	avg := ix.total / n
+	scores := make(map[string]float64)
+	for _, term := range terms {
+		postings := ix.postings[term]
+		idf := math.Log(1 + (n-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+		for id, tf := range postings {
+			norm := k1 * (1 - b + b*ix.lengths[id]/avg)
+			scores[id] += idf * tf * (k1 + 1) / (tf + norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	matches := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		matches[term] = true
+	}
+	for i := range hits {
+		for field, text := range ix.docs[hits[i].ID].Fields {
+			if snippet, ok := Highlight(text, matches); ok {
+				if hits[i].Snippets == nil {
+					hits[i].Snippets = make(map[string]string)
+				}
+				hits[i].Snippets[field] = snippet
+			}
+		}
+	}
+	return hits
+}
+
+// Highlight returns an HTML escaped snippet of the text around the first matching term
+// with matching words wrapped in <em> tags, ok is false if no term matches.
+func Highlight(text string, matches map[string]bool) (snippet string, ok bool) {
+	tokens := Tokenize(text)
+	first := -1
+	for i, t := range tokens {
+		if matches[t.Term] {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	from := first - snippetBefore
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(tokens) {
+		to = len(tokens)
+	}
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+	pos := tokens[from].Start
+	for _, t := range tokens[from:to] {
+		sb.WriteString(html.EscapeString(text[pos:t.Start]))
+		word := html.EscapeString(text[t.Start:t.End])
+		if matches[t.Term] {
+			word = "<em>" + word + "</em>"
+		}
+		sb.WriteString(word)
+		pos = t.End
+	}
+	if to < len(tokens) {
+		sb.WriteString("…")
+	} else {
+		sb.WriteString(html.EscapeString(text[pos:]))
+	}
+	return sb.String(), true
+}
+
+// Snapshot of the index content, postings are rebuilt on load
+type snapshot struct {
+	Documents []Document `json:"documents"`
+}
+
+// Save writes a snapshot of the index
+func (ix *Index) Save(w io.Writer) error {
+	ix.mu.RLock()
+	s := snapshot{Documents: make([]Document, 0, len(ix.docs))}
+	for _, doc := range ix.docs {
+		s.Documents = append(s.Documents, doc)
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(s.Documents, func(i, j int) bool { return s.Documents[i].ID < s.Documents[j].ID })
+	return json.NewEncoder(w).Encode(s)
+}
+
+// Load reads a snapshot into a new index with the field boosts
+func Load(r io.Reader, boosts map[string]float64) (*Index, error) {
+	var s snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	ix := New(boosts)
+	for _, doc := range s.Documents {
+		ix.Add(doc)
+	}
+	return ix, nil
+}
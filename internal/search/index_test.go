@@ -0,0 +1,84 @@
+package search
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns a test document
+func doc(id, name, description string) Document {
+	return Document{ID: id, Fields: map[string]string{"name": name, "description": description}}
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := New(map[string]float64{"name": 2})
+	ix.Add(doc("1", "Blue widget", "A small widget for boxes"))
+	ix.Add(doc("2", "Red gadget", "Works with blue widgets and batteries"))
+	ix.Add(doc("3", "Battery pack", "Spare batteries stored in the warehouse"))
+
+	assert := assert.New(t)
+	assert.Equal(3, ix.Len())
+
+	hits := ix.Search("widgets", 10)
+	if assert.Len(hits, 2) {
+		assert.Equal("1", hits[0].ID, "Name and description match ranks first")
+		assert.Equal("2", hits[1].ID)
+		assert.Equal("Blue <em>widget</em>", hits[0].Snippets["name"], "Highlighted name")
+		assert.Equal("A small <em>widget</em> for boxes", hits[0].Snippets["description"], "Highlighted description")
+		assert.NotContains(hits[1].Snippets, "name", "No snippet without a match")
+	}
+
+	hits = ix.Search("battery", 1)
+	if assert.Len(hits, 1, "Limit") {
+		assert.Equal("3", hits[0].ID, "Name boost")
+	}
+
+	assert.Empty(ix.Search("the", 10), "Stop words only")
+	assert.Empty(ix.Search("spaceship", 10), "No match")
+
+	ix.Add(doc("1", "Green gizmo", "Nothing in common"))
+	hits = ix.Search("widget", 10)
+	if assert.Len(hits, 1, "Replaced document") {
+		assert.Equal("2", hits[0].ID)
+	}
+
+	ix.Remove("2")
+	ix.Remove("unknown")
+	assert.Empty(ix.Search("widget", 10), "Removed document")
+	assert.Equal(2, ix.Len())
+}
+
+func TestHighlight(t *testing.T) {
+	text := strings.Repeat("lorem ", 10) + "<widget> " + strings.Repeat("ipsum ", 20)
+	snippet, ok := Highlight(text, map[string]bool{"widget": true})
+	if assert.True(t, ok) {
+		assert.True(t, strings.HasPrefix(snippet, "…lorem lorem lorem lorem lorem &lt;<em>widget</em>&gt; ipsum"), snippet)
+		assert.True(t, strings.HasSuffix(snippet, "ipsum…"), snippet)
+	}
+
+	_, ok = Highlight("nothing here", map[string]bool{"widget": true})
+	assert.False(t, ok, "No match")
+}
+
+func TestIndex_Snapshot(t *testing.T) {
+	ix := New(nil)
+	ix.Add(doc("1", "Blue widget", "A small widget"))
+	ix.Add(doc("2", "Red gadget", "Works with widgets"))
+
+	var buf bytes.Buffer
+	assert := assert.New(t)
+	if !assert.NoError(ix.Save(&buf)) {
+		return
+	}
+	loaded, err := Load(&buf, nil)
+	if assert.NoError(err) {
+		assert.Equal(2, loaded.Len())
+		assert.Equal(ix.Search("widget", 10), loaded.Search("widget", 10), "Same results")
+	}
+
+	_, err = Load(strings.NewReader("not json"), nil)
+	assert.Error(err, "Invalid snapshot")
+}
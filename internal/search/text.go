@@ -0,0 +1,103 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Token is a term of a text with its byte offsets
+type Token struct {
+	Term  string // normalised and stemmed word (empty for stop words)
+	Start int
+	End   int
+}
+
+// Words which are too common to be searched
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "with": true,
+}
+
+// Tokenize splits the text into words, stop words are kept with empty terms for highlighting
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			word := strings.ToLower(text[start:end])
+			term := ""
+			if !stopWords[word] {
+				term = Stem(word)
+			}
+			tokens = append(tokens, Token{Term: term, Start: start, End: end})
+			start = -1
+		}
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+		} else {
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// Terms returns distinct searchable terms of the text
+func Terms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, t := range Tokenize(text) {
+		if t.Term != "" && !seen[t.Term] {
+			seen[t.Term] = true
+			terms = append(terms, t.Term)
+		}
+	}
+	return terms
+}
+
+// Stem reduces an English lowercase word to its stem by stripping common inflections,
+// so plural and verb forms (e.g. "boxes", "batteries", "stored", "running") match their base words.
+func Stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "xes") || strings.HasSuffix(word, "ches") || strings.HasSuffix(word, "shes"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us"):
+		word = word[:len(word)-1]
+	}
+	for _, suffix := range []string{"ing", "ed", "ly"} {
+		if stem := strings.TrimSuffix(word, suffix); stem != word && len(stem) >= 3 && hasVowel(stem) {
+			word = undouble(stem)
+			break
+		}
+	}
+	if len(word) > 3 && strings.HasSuffix(word, "e") {
+		word = word[:len(word)-1]
+	}
+	return word
+}
+
+// Reports whether the word has a vowel
+func hasVowel(word string) bool {
+	return strings.ContainsAny(word, "aeiouy")
+}
+
+// Removes a doubled final consonant (e.g. "runn" of "running")
+func undouble(stem string) string {
+	n := len(stem)
+	if n >= 2 && stem[n-1] == stem[n-2] && !strings.ContainsRune("aeioulsz", rune(stem[n-1])) {
+		return stem[:n-1]
+	}
+	return stem
+}
@@ -0,0 +1,40 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"widgets":   "widget",
+		"boxes":     "box",
+		"batteries": "battery",
+		"glasses":   "glass",
+		"status":    "status",
+		"running":   "run",
+		"stored":    "stor",
+		"store":     "stor",
+		"quickly":   "quick",
+		"need":      "need",
+		"red":       "red",
+	}
+	for word, want := range tests {
+		assert.Equal(t, want, Stem(word), word)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("The Blue-widgets, for Café!")
+	assert.Equal(t, []Token{
+		{Term: "", Start: 0, End: 3},
+		{Term: "blu", Start: 4, End: 8},
+		{Term: "widget", Start: 9, End: 16},
+		{Term: "", Start: 18, End: 21},
+		{Term: "café", Start: 22, End: 27},
+	}, tokens)
+
+	assert.Equal(t, []string{"blu", "widget"}, Terms("the blue widgets and a blue widget"), "Distinct terms")
+	assert.Empty(t, Terms("the and of"), "Stop words")
+}
@@ -55,6 +55,14 @@ func NoContent() Response {
 	}
 }
 
+// MultiStatus returns 207 status code with outcomes of multiple operations
+func MultiStatus(body interface{}) Response {
+	return Response{
+		StatusCode: http.StatusMultiStatus,
+		Body:       body,
+	}
+}
+
 /**
 	CLIENT ERROR RESPONSES
 **/
@@ -68,6 +76,15 @@ func BadRequest(message string) Response {
 	}
 }
 
+// Forbidden returns 403 status code
+func Forbidden(message string) Response {
+	status, message := httpStatusAs(http.StatusForbidden, message)
+	return Response{
+		StatusCode: status,
+		Body:       Error{Code: status, Message: message},
+	}
+}
+
 // NotFound returns 404 status code
 func NotFound(message string) Response {
 	status, message := httpStatusAs(http.StatusNotFound, message)
@@ -0,0 +1,128 @@
+package snshttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+)
+
+// SNS HTTP message types
+const (
+	TypeNotification             = "Notification"
+	TypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	TypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+type (
+	// MessageAttribute of the notification
+	MessageAttribute struct {
+		Type  string `json:"Type"`
+		Value string `json:"Value"`
+	}
+
+	// Message is a JSON document posted by SNS to HTTP(S) subscribers
+	Message struct {
+		Type              string                      `json:"Type"`
+		MessageID         string                      `json:"MessageId"`
+		Token             string                      `json:"Token,omitempty"`
+		TopicArn          string                      `json:"TopicArn"`
+		Subject           string                      `json:"Subject,omitempty"`
+		Message           string                      `json:"Message"`
+		Timestamp         string                      `json:"Timestamp"`
+		SignatureVersion  string                      `json:"SignatureVersion"`
+		Signature         string                      `json:"Signature"`
+		SigningCertURL    string                      `json:"SigningCertURL"`
+		SubscribeURL      string                      `json:"SubscribeURL,omitempty"`
+		UnsubscribeURL    string                      `json:"UnsubscribeURL,omitempty"`
+		MessageAttributes map[string]MessageAttribute `json:"MessageAttributes,omitempty"`
+	}
+)
+
+// Parse an SNS HTTP message body
+func Parse(body []byte) (*Message, error) {
+	var m Message
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, err
+	}
+	switch m.Type {
+	case TypeNotification, TypeSubscriptionConfirmation, TypeUnsubscribeConfirmation:
+	default:
+		return nil, fmt.Errorf("Unsupported message type: %v", m.Type)
+	}
+	if m.MessageID == "" || m.TopicArn == "" || m.Signature == "" || m.SigningCertURL == "" {
+		return nil, errors.New("Missing required message fields")
+	}
+	return &m, nil
+}
+
+// StringToSign returns a canonical representation of the message covered by the signature
+func (m *Message) StringToSign() string {
+	var fields [][2]string
+	if m.Type == TypeNotification {
+		fields = [][2]string{
+			{"Message", m.Message},
+			{"MessageId", m.MessageID},
+			{"Subject", m.Subject},
+			{"Timestamp", m.Timestamp},
+			{"TopicArn", m.TopicArn},
+			{"Type", m.Type},
+		}
+	} else {
+		fields = [][2]string{
+			{"Message", m.Message},
+			{"MessageId", m.MessageID},
+			{"SubscribeURL", m.SubscribeURL},
+			{"Timestamp", m.Timestamp},
+			{"Token", m.Token},
+			{"TopicArn", m.TopicArn},
+			{"Type", m.Type},
+		}
+	}
+
+	var b strings.Builder
+	for _, f := range fields {
+		// subject is optional and excluded when absent
+		if f[0] == "Subject" && f[1] == "" {
+			continue
+		}
+		b.WriteString(f[0] + "\n" + f[1] + "\n")
+	}
+	return b.String()
+}
+
+// Event returns the item lifecycle event of the notification
+func (m *Message) Event() sample.Event {
+	if attr, ok := m.MessageAttributes["event"]; ok && attr.Value != "" {
+		return sample.Event(attr.Value)
+	}
+	return sample.ItemCreated
+}
+
+// Resolve replaces a claim check of the notification by the message kept in the payload store
+// (a store is required by claim checks only)
+func (m *Message) Resolve(store *sample.PayloadStore) error {
+	message, err := store.Resolve(m.Message)
+	if err != nil {
+		return err
+	}
+	m.Message = message
+	return nil
+}
+
+// Item decodes the item embedded in the notification, claim checks must be resolved first
+func (m *Message) Item() (*sample.Item, error) {
+	if m.Type != TypeNotification {
+		return nil, fmt.Errorf("Message of type %v doesn't carry an item", m.Type)
+	}
+	if sample.IsClaimCheck(m.Message) {
+		return nil, sample.ErrClaimCheck
+	}
+	var item sample.Item
+	if err := json.Unmarshal([]byte(m.Message), &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
@@ -0,0 +1,109 @@
+package snshttp
+
+import (
+	"testing"
+
+	"github.com/nb-samples/aws-serverless-go/internal/sample"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	notificationJSON = `{
+		"Type": "Notification",
+		"MessageId": "test-message-id",
+		"TopicArn": "arn:aws:sns:us-east-1:123456789012:test-topic",
+		"Subject": "Sample notification message",
+		"Message": "{\"id\":\"test-item-id\",\"name\":\"unit test\"}",
+		"Timestamp": "2020-09-01T00:00:00.000Z",
+		"SignatureVersion": "2",
+		"Signature": "dGVzdA==",
+		"SigningCertURL": "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem",
+		"MessageAttributes": {"event": {"Type": "String", "Value": "item.deleted"}}
+	}`
+	confirmationJSON = `{
+		"Type": "SubscriptionConfirmation",
+		"MessageId": "test-message-id",
+		"Token": "test-token",
+		"TopicArn": "arn:aws:sns:us-east-1:123456789012:test-topic",
+		"Message": "You have chosen to subscribe to the topic.",
+		"SubscribeURL": "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription",
+		"Timestamp": "2020-09-01T00:00:00.000Z",
+		"SignatureVersion": "1",
+		"Signature": "dGVzdA==",
+		"SigningCertURL": "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-test.pem"
+	}`
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{name: "notification", body: notificationJSON, want: TypeNotification},
+		{name: "subscription confirmation", body: confirmationJSON, want: TypeSubscriptionConfirmation},
+		{name: "malformed JSON", body: `"Type":"Notification"`, wantErr: true},
+		{name: "unknown type", body: `{"Type": "Unknown", "MessageId": "test"}`, wantErr: true},
+		{name: "missing signature", body: `{"Type": "Notification", "MessageId": "test", "TopicArn": "test"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.body))
+			assert := assert.New(t)
+			if tt.wantErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(tt.want, got.Type, "Type")
+			}
+		})
+	}
+}
+
+func TestMessage_StringToSign(t *testing.T) {
+	assert := assert.New(t)
+
+	m, _ := Parse([]byte(notificationJSON))
+	assert.Equal("Message\n{\"id\":\"test-item-id\",\"name\":\"unit test\"}\n"+
+		"MessageId\ntest-message-id\n"+
+		"Subject\nSample notification message\n"+
+		"Timestamp\n2020-09-01T00:00:00.000Z\n"+
+		"TopicArn\narn:aws:sns:us-east-1:123456789012:test-topic\n"+
+		"Type\nNotification\n", m.StringToSign(), "Notification")
+
+	m.Subject = ""
+	assert.NotContains(m.StringToSign(), "Subject", "Notification without subject")
+
+	m, _ = Parse([]byte(confirmationJSON))
+	assert.Equal("Message\nYou have chosen to subscribe to the topic.\n"+
+		"MessageId\ntest-message-id\n"+
+		"SubscribeURL\nhttps://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription\n"+
+		"Timestamp\n2020-09-01T00:00:00.000Z\n"+
+		"Token\ntest-token\n"+
+		"TopicArn\narn:aws:sns:us-east-1:123456789012:test-topic\n"+
+		"Type\nSubscriptionConfirmation\n", m.StringToSign(), "Subscription confirmation")
+}
+
+func TestMessage_Item(t *testing.T) {
+	assert := assert.New(t)
+
+	m, _ := Parse([]byte(notificationJSON))
+	item, err := m.Item()
+	if assert.NoError(err) {
+		assert.Equal("test-item-id", item.ID, "ID")
+		assert.Equal("unit test", item.Name, "Name")
+	}
+	assert.Equal(sample.ItemDeleted, m.Event(), "Event")
+
+	m, _ = Parse([]byte(confirmationJSON))
+	_, err = m.Item()
+	assert.Error(err, "Confirmation")
+	assert.Equal(sample.ItemCreated, m.Event(), "Default event")
+
+	m, _ = Parse([]byte(notificationJSON))
+	m.Message = `{"id":"test-item-id","claimCheck":{"bucket":"test-bucket","key":"messages/test.json.gz"}}`
+	_, err = m.Item()
+	assert.Equal(sample.ErrClaimCheck, err, "Unresolved claim check")
+	assert.Error(m.Resolve(nil), "Claim check without store")
+}
@@ -0,0 +1,141 @@
+package snshttp
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// signingHost matches SNS hosts serving signing certificates
+var signingHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// CertificateFetcher retrieves a signing certificate by URL
+type CertificateFetcher interface {
+	Fetch(certURL string) (*x509.Certificate, error)
+}
+
+// CertificateFetcherFunc is an adapter to use a function as a certificate fetcher
+type CertificateFetcherFunc func(certURL string) (*x509.Certificate, error)
+
+// Fetch calls f(certURL)
+func (f CertificateFetcherFunc) Fetch(certURL string) (*x509.Certificate, error) {
+	return f(certURL)
+}
+
+// HTTPFetcher downloads PEM encoded certificates and caches them by URL
+type HTTPFetcher struct {
+	Client *http.Client // HTTP client (default client if nil)
+	mu     sync.Mutex
+	cache  map[string]*x509.Certificate
+}
+
+// Fetch a certificate from the cache or download it
+func (f *HTTPFetcher) Fetch(certURL string) (*x509.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cert, ok := f.cache[certURL]; ok {
+		return cert, nil
+	}
+
+	client := f.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	res, err := client.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to download certificate: HTTP status %v", res.StatusCode)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("Failed to decode certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.cache == nil {
+		f.cache = make(map[string]*x509.Certificate)
+	}
+	f.cache[certURL] = cert
+	return cert, nil
+}
+
+// Verifier validates SNS message signatures
+type Verifier struct {
+	Fetcher CertificateFetcher // signing certificate source (downloaded over HTTPS if nil)
+}
+
+// defaultFetcher is shared across verifiers to reuse downloaded certificates
+var defaultFetcher = &HTTPFetcher{}
+
+// Verify the message signature (SignatureVersion 1 uses SHA1, 2 uses SHA256)
+func (v Verifier) Verify(m *Message) error {
+	var h hash.Hash
+	var alg crypto.Hash
+	switch m.SignatureVersion {
+	case "1":
+		h, alg = sha1.New(), crypto.SHA1
+	case "2":
+		h, alg = sha256.New(), crypto.SHA256
+	default:
+		return fmt.Errorf("Unsupported signature version: %v", m.SignatureVersion)
+	}
+
+	if err := checkCertURL(m.SigningCertURL); err != nil {
+		return err
+	}
+	fetcher := v.Fetcher
+	if fetcher == nil {
+		fetcher = defaultFetcher
+	}
+	cert, err := fetcher.Fetch(m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("Unsupported signing certificate key")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return errors.New("Malformed signature")
+	}
+	h.Write([]byte(m.StringToSign()))
+	if err := rsa.VerifyPKCS1v15(key, alg, h.Sum(nil), signature); err != nil {
+		return errors.New("Invalid signature")
+	}
+	return nil
+}
+
+// Checks the signing certificate is served by SNS over HTTPS
+func checkCertURL(certURL string) error {
+	u, err := url.Parse(certURL)
+	if err != nil || u.Scheme != "https" || !signingHost.MatchString(u.Hostname()) {
+		return fmt.Errorf("Untrusted signing certificate URL: %v", certURL)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package snshttp
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Generates a self-signed certificate and its private key
+func testCertificate(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+// Signs the message according to its signature version
+func sign(t *testing.T, m *Message, key *rsa.PrivateKey) {
+	var digest []byte
+	var alg crypto.Hash
+	if m.SignatureVersion == "1" {
+		sum := sha1.Sum([]byte(m.StringToSign()))
+		digest, alg = sum[:], crypto.SHA1
+	} else {
+		sum := sha256.Sum256([]byte(m.StringToSign()))
+		digest, alg = sum[:], crypto.SHA256
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, alg, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(signature)
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	cert, key := testCertificate(t)
+	_, otherKey := testCertificate(t)
+	fetcher := CertificateFetcherFunc(func(string) (*x509.Certificate, error) { return cert, nil })
+
+	tests := []struct {
+		name    string
+		body    string
+		modify  func(m *Message)
+		fetcher CertificateFetcher
+		wantErr bool
+	}{
+		{
+			name:    "notification signature version 2",
+			body:    notificationJSON,
+			fetcher: fetcher,
+		},
+		{
+			name:    "notification signature version 1",
+			body:    notificationJSON,
+			modify:  func(m *Message) { m.SignatureVersion = "1" },
+			fetcher: fetcher,
+		},
+		{
+			name:    "subscription confirmation",
+			body:    confirmationJSON,
+			fetcher: fetcher,
+		},
+		{
+			name:    "unsubscribe confirmation",
+			body:    confirmationJSON,
+			modify:  func(m *Message) { m.Type = TypeUnsubscribeConfirmation },
+			fetcher: fetcher,
+		},
+		{
+			name:    "tampered message",
+			body:    notificationJSON,
+			modify:  func(m *Message) { sign(t, m, key); m.Message = "{}" },
+			fetcher: fetcher,
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			body:    notificationJSON,
+			modify:  func(m *Message) { sign(t, m, otherKey) },
+			fetcher: fetcher,
+			wantErr: true,
+		},
+		{
+			name:    "unsupported signature version",
+			body:    notificationJSON,
+			modify:  func(m *Message) { m.SignatureVersion = "3" },
+			fetcher: fetcher,
+			wantErr: true,
+		},
+		{
+			name:    "untrusted certificate URL",
+			body:    notificationJSON,
+			modify:  func(m *Message) { m.SigningCertURL = "https://example.com/cert.pem" },
+			fetcher: fetcher,
+			wantErr: true,
+		},
+		{
+			name:    "certificate unavailable",
+			body:    notificationJSON,
+			fetcher: CertificateFetcherFunc(func(string) (*x509.Certificate, error) { return nil, errors.New("Mock fetch error") }),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.modify != nil {
+				tt.modify(m)
+			}
+			if m.Signature == "dGVzdA==" {
+				sign(t, m, key)
+			}
+
+			err = Verifier{Fetcher: tt.fetcher}.Verify(m)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcher_Fetch(t *testing.T) {
+	cert, _ := testCertificate(t)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}))
+	defer server.Close()
+
+	fetcher := &HTTPFetcher{Client: server.Client()}
+	assert := assert.New(t)
+	for i := 0; i < 2; i++ {
+		got, err := fetcher.Fetch(server.URL + "/cert.pem")
+		if assert.NoError(err) {
+			assert.True(cert.Equal(got), "Certificate")
+		}
+	}
+	assert.Equal(1, requests, "Cached certificate")
+
+	_, err := fetcher.Fetch(server.URL + "/other.pem")
+	assert.NoError(err)
+	assert.Equal(2, requests, "Another certificate")
+}